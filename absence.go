@@ -0,0 +1,105 @@
+package lwm
+
+import (
+	"bytes"
+)
+
+// NonMembershipProof proves that a key is not in a tree. The left leaf is the
+// closest leaf before the key, and the right leaf the closest leaf after it.
+// Either leaf is omitted if the key would be first or last in the tree.
+type NonMembershipProof struct {
+	twc      []byte
+	hash     func(data ...[]byte) []byte
+	lLeaf    []byte   // leaf data before key (nil->n/a)
+	rLeaf    []byte   // leaf data after key (nil->n/a)
+	lAp      [][]byte // audit path for lLeaf (nil->n/a)
+	rAp      [][]byte // audit path for rLeaf (nil->n/a)
+	lIndex   int      // Merkle tree index of lLeaf (-1->n/a)
+	treeSize int      // number of leaves in the tree
+
+	leafPrefix, interiorPrefix []byte // nil->default prefixes
+	hashLength                 int    // truncated hash length (0->untruncated)
+	nonceLength                int    // leaf nonce length (0->n/a)
+}
+
+// GetAbsenceProof outputs a proof that key is not in the tree, without prefix
+// expansion. ErrKeyExists is returned if key is present.
+func (wt *WildcardTree) GetAbsenceProof(key string) (NonMembershipProof,
+	error) {
+	np := NonMembershipProof{
+		twc:            wt.mt.twc,
+		hash:           wt.hash(),
+		hashLength:     wt.hashLength,
+		nonceLength:    wt.nonceLength,
+		lIndex:         -1,
+		treeSize:       len(wt.mt.data),
+		leafPrefix:     wt.mt.leafPrefix,
+		interiorPrefix: wt.mt.interiorPrefix,
+	}
+	key = wt.normalizeKey(key)
+	if _, ok := wt.r.Get(key); ok {
+		return np, ErrKeyExists
+	}
+	if np.treeSize == 0 {
+		return np, nil
+	}
+
+	var p Proof
+	wt.absenceProof(&p, key)
+	np.lLeaf, np.rLeaf, np.lAp, np.rAp = p.ll, p.rl, p.lap, p.rap
+	if np.lLeaf != nil {
+		np.lIndex = p.index
+	}
+	return np, nil
+}
+
+// TreeSize outputs the tree size that the proof was generated for. A verifier
+// should check that it matches the expected size of the snapshot.
+func (np NonMembershipProof) TreeSize() int {
+	return np.treeSize
+}
+
+// VerifyAbsence outputs true if the proof shows that key is not in the tree
+// that snapshot commits to
+func (np NonMembershipProof) VerifyAbsence(key string, snapshot []byte) bool {
+	if np.hash == nil || np.treeSize < 0 {
+		return false
+	}
+	// special case: empty tree
+	if np.treeSize == 0 {
+		return np.lLeaf == nil && np.rLeaf == nil && np.lIndex == -1 &&
+			bytes.Equal(snapshot, truncatedHash(np.hash, np.hashLength)(np.twc))
+	}
+	p := Proof{
+		hash:  np.hash,
+		twc:   np.twc,
+		index: np.lIndex,
+		ll:    np.lLeaf,
+		rl:    np.rLeaf,
+		lap:   np.lAp,
+		rap:   np.rAp,
+
+		leafPrefix:     np.leafPrefix,
+		interiorPrefix: np.interiorPrefix,
+		hashLength:     np.hashLength,
+		nonceLength:    np.nonceLength,
+	}
+	// check that leaves are present if expected, and that key is in between
+	if (np.lLeaf == nil) != (np.lIndex == -1) || np.lIndex < -1 {
+		return false
+	}
+	if (np.rLeaf == nil) != (np.lIndex+1 == np.treeSize) {
+		return false
+	}
+	if np.lLeaf != nil && p.leafKey(np.lLeaf) >= key {
+		return false
+	}
+	if np.rLeaf != nil && p.leafKey(np.rLeaf) <= key {
+		return false
+	}
+	// check that the leaves are adjacent in the Merkle tree
+	if np.lLeaf == nil {
+		p.index = 0
+	}
+	return p.Verify(key, Answer{}, np.treeSize, snapshot) == nil
+}
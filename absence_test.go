@@ -0,0 +1,75 @@
+package lwm
+
+import (
+	"testing"
+)
+
+func TestGetAbsenceProof(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, map[string]interface{}{
+		"moc.a": [][]byte{[]byte("a")},
+		"moc.c": [][]byte{[]byte("c")},
+		"moc.e": [][]byte{[]byte("e")},
+	})
+	snapshot := wt.Snapshot()
+	for _, key := range []string{"moc", "moc.b", "moc.d", "moc.f"} {
+		np, err := wt.GetAbsenceProof(key)
+		if err != nil {
+			t.Errorf("key %q: %v", key, err)
+			continue
+		}
+		if np.TreeSize() != wt.Size() {
+			t.Errorf("key %q: got tree size %d, want %d", key, np.TreeSize(),
+				wt.Size())
+		}
+		if !np.VerifyAbsence(key, snapshot) {
+			t.Errorf("key %q: valid absence proof rejected", key)
+		}
+		if np.VerifyAbsence(key, hash([]byte("bad snapshot"))) {
+			t.Errorf("key %q: accepted bad snapshot", key)
+		}
+	}
+
+	// present keys
+	for _, key := range []string{"moc.a", "moc.c", "moc.e"} {
+		if _, err := wt.GetAbsenceProof(key); err != ErrKeyExists {
+			t.Errorf("key %q: got error %v, want %v", key, err, ErrKeyExists)
+		}
+	}
+	for _, table := range []struct {
+		description string
+		key         string
+		absent      string
+	}{
+		{"left leaf is key", "moc.c", "moc.d"},
+		{"right leaf is key", "moc.c", "moc.b"},
+		{"first key", "moc.a", "moc"},
+		{"last key", "moc.e", "moc.f"},
+	} {
+		np, err := wt.GetAbsenceProof(table.absent)
+		if err != nil {
+			t.Fatalf("%s: %v", table.description, err)
+		}
+		if np.VerifyAbsence(table.key, snapshot) {
+			t.Errorf("%s: accepted proof for present key", table.description)
+		}
+	}
+
+	// proof for a present key that is forged from two adjacent leaves
+	np, _ := wt.GetAbsenceProof("moc.b")
+	_, p := wt.Get("moc.c")
+	np.rLeaf, np.rAp = p.RightLeaf(), p.RightAP()
+	np.lLeaf, np.lAp, np.lIndex = p.LeftLeaf(), p.LeftAP(), p.Index()
+	if np.VerifyAbsence("moc.c", snapshot) {
+		t.Errorf("accepted forged proof for present key")
+	}
+
+	// empty tree
+	wt = MustNewWildcardTree(twc, hash, nil)
+	np, err := wt.GetAbsenceProof("moc.a")
+	if err != nil {
+		t.Fatalf("empty tree: %v", err)
+	}
+	if !np.VerifyAbsence("moc.a", wt.Snapshot()) {
+		t.Errorf("empty tree: valid absence proof rejected")
+	}
+}
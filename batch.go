@@ -0,0 +1,74 @@
+package lwm
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// VerifyEntry is a wildcard answer and proof for a given key
+type VerifyEntry struct {
+	Key    string
+	Answer Answer
+	Proof  Proof
+}
+
+// BatchVerify verifies many entries against the same tree size and snapshot.
+// The output contains one error per entry, where nil means that the entry is
+// valid. Entries that use the same twc, prefixes, and hash function share a
+// Merkle tree, where hash functions are compared by their output on a fixed
+// probe since functions are not comparable. A shared tree caches the interior
+// hashes that it computes, such that audit paths that share hashes with an
+// earlier entry are not hashed again. This pays off if the entries are close
+// in the tree, e.g., all names under a TLD.
+func BatchVerify(entries []VerifyEntry, size int, snapshot []byte) []error {
+	type shellID struct {
+		twc, lp, ip string
+		probe       string
+		hashLength  int
+	}
+	probe := []byte("probe")
+	shells := make(map[shellID]*MerkleTree)
+	errs := make([]error, len(entries))
+	for i, e := range entries {
+		if e.Proof.hash == nil {
+			errs[i] = e.Proof.Verify(e.Key, e.Answer, size, snapshot)
+			continue
+		}
+		lp, ip := e.Proof.prefixes()
+		id := shellID{string(e.Proof.twc), string(lp), string(ip),
+			string(e.Proof.hash(probe)), e.Proof.hashLength}
+		mt, ok := shells[id]
+		if !ok {
+			c := &interiorCache{h: e.Proof.hashFunc(), interiorPrefix: ip,
+				m: make(map[string][]byte)}
+			mt = NewMerkleTree(e.Proof.twc, lp, ip, c.hash, nil)
+			shells[id] = mt
+		}
+		errs[i] = e.Proof.verify(mt, e.Key, e.Answer, size, snapshot)
+	}
+	return errs
+}
+
+// interiorCache wraps a hash function and remembers the interior hashes that
+// it outputs, keyed on the hashed children
+type interiorCache struct {
+	h              func(data ...[]byte) []byte
+	interiorPrefix []byte
+	m              map[string][]byte
+	key            []byte // reused buffer for cache keys
+}
+
+// hash outputs h(data...), looking up interior hashes in the cache
+func (c *interiorCache) hash(data ...[]byte) []byte {
+	if len(data) != 3 || !bytes.Equal(data[0], c.interiorPrefix) {
+		return c.h(data...)
+	}
+	c.key = strconv.AppendInt(c.key[:0], int64(len(data[1])), 10)
+	c.key = append(append(append(c.key, ':'), data[1]...), data[2]...)
+	if h, ok := c.m[string(c.key)]; ok {
+		return h
+	}
+	h := c.h(data...)
+	c.m[string(c.key)] = h
+	return h
+}
@@ -0,0 +1,93 @@
+package lwm
+
+import (
+	"fmt"
+	"github.com/golang/example/stringutil"
+	"testing"
+)
+
+func TestBatchVerify(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	snapshot := wt.Snapshot()
+	var entries []VerifyEntry
+	for _, key := range []string{
+		"", "a", "zzz",
+		stringutil.Reverse("foo.com"),
+		stringutil.Reverse("sub0.foo.com"),
+		stringutil.Reverse("qux.se"),
+	} {
+		answer, proof := wt.Get(key)
+		entries = append(entries, VerifyEntry{key, answer, proof})
+	}
+	bad := entries[3]
+	bad.Answer.payload = [][][]byte{{[]byte("bad")}, bad.Answer.payload[1],
+		bad.Answer.payload[2]}
+	entries = append(entries, bad)
+
+	errs := BatchVerify(entries, len(m), snapshot)
+	if len(errs) != len(entries) {
+		t.Fatalf("got %v errors, want %v", len(errs), len(entries))
+	}
+	for i, e := range entries {
+		want := e.Proof.Verify(e.Key, e.Answer, len(m), snapshot)
+		if (errs[i] == nil) != (want == nil) {
+			t.Errorf("entry %d => got %v, want %v", i, errs[i], want)
+		}
+	}
+	if errs[len(errs)-1] == nil {
+		t.Errorf("bad entry accepted")
+	}
+}
+
+func TestBatchVerifyKeyedHash(t *testing.T) {
+	keyed := func(key string) func(data ...[]byte) []byte {
+		return func(data ...[]byte) []byte {
+			return hash(append([][]byte{[]byte(key)}, data...)...)
+		}
+	}
+	a := MustNewWildcardTree(twc, keyed("a"), testData())
+	b := MustNewWildcardTree(twc, keyed("b"), testData())
+	key := stringutil.Reverse("foo.com")
+	var entries []VerifyEntry
+	for _, wt := range []*WildcardTree{b, a} {
+		answer, proof := wt.Get(key)
+		entries = append(entries, VerifyEntry{key, answer, proof})
+	}
+	errs := BatchVerify(entries, a.Size(), a.Snapshot())
+	if errs[0] == nil {
+		t.Errorf("entry of another tree accepted")
+	}
+	if errs[1] != nil {
+		t.Errorf("valid entry rejected: %v", errs[1])
+	}
+}
+
+func BenchmarkBatchVerify(b *testing.B) {
+	m := make(map[string]interface{})
+	for i := 0; i < 10000; i++ {
+		m[fmt.Sprintf("moc.%05d", i)] = [][]byte{[]byte("cert")}
+	}
+	wt := MustNewWildcardTree(twc, hash, m)
+	snapshot := wt.Snapshot()
+	for _, spacing := range []int{1, 10} {
+		var entries []VerifyEntry
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("moc.%05d", i*spacing)
+			answer, proof := wt.Get(key)
+			entries = append(entries, VerifyEntry{key, answer, proof})
+		}
+		b.Run(fmt.Sprintf("Verify/spacing=%d", spacing), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, e := range entries {
+					e.Proof.Verify(e.Key, e.Answer, len(m), snapshot)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("BatchVerify/spacing=%d", spacing), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				BatchVerify(entries, len(m), snapshot)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package lwm
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Binary proof format (all integers are little-endian):
+//
+//	version   1 byte
+//	hash id   1 byte
+//	index     4 bytes, two's complement (-1 for an empty tree)
+//	flags     1 byte, bit i set if component i of {ll, rl, lap, rap} is present
+//	twc       2-byte length + bytes
+//	component 2-byte length + bytes, for each present component in flag order
+//
+// An audit path is encoded as its hashes concatenated, which means that its
+// length must be a multiple of the hash function's output length.
+const (
+	binaryVersion = 1
+
+	flagLeftLeaf  = 1 << 0
+	flagRightLeaf = 1 << 1
+	flagLeftAP    = 1 << 2
+	flagRightAP   = 1 << 3
+)
+
+// hashIdentifiers maps registered hash algorithm names to binary identifiers
+var hashIdentifiers = map[string]uint8{
+	"sha256":      1,
+	"blake2b-256": 2,
+	"sha3-256":    3,
+	"keccak-256":  4,
+	"blake3":      5,
+}
+
+// Marshal outputs a compact binary encoding of the proof. The proof's hash
+// function must be registered by name, and its prefixes must be the defaults.
+func (p Proof) Marshal() ([]byte, error) {
+	name, ok := hashName(p.hash)
+	if !ok {
+		return nil, errors.New("unregistered hash function")
+	}
+	if !p.defaultPrefixes() {
+		return nil, errors.New("custom prefixes cannot be encoded")
+	}
+	if p.hashLength > 0 {
+		return nil, errors.New("truncated hashes cannot be encoded")
+	}
+	if p.nonceLength > 0 {
+		return nil, errors.New("leaf nonces cannot be encoded")
+	}
+	if p.index < math.MinInt32 || p.index > math.MaxInt32 {
+		return nil, fmt.Errorf("index %d does not fit in 32 bits", p.index)
+	}
+
+	n := len(p.hash())
+	for _, h := range append(append([][]byte{}, p.lap...), p.rap...) {
+		if len(h) != n {
+			return nil, errors.New("audit path contains a bad hash length")
+		}
+	}
+
+	var flags uint8
+	var components [][]byte
+	for i, c := range []struct {
+		present bool
+		data    []byte
+	}{
+		{p.ll != nil, p.ll},
+		{p.rl != nil, p.rl},
+		{p.lap != nil, concat(p.lap)},
+		{p.rap != nil, concat(p.rap)},
+	} {
+		if c.present {
+			flags |= 1 << uint(i)
+			components = append(components, c.data)
+		}
+	}
+
+	b := []byte{binaryVersion, hashIdentifiers[name]}
+	b = binary.LittleEndian.AppendUint32(b, uint32(int32(p.index)))
+	b = append(b, flags)
+	for _, c := range append([][]byte{p.twc}, components...) {
+		if len(c) > math.MaxUint16 {
+			return nil, errors.New("proof component is too large")
+		}
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(c)))
+		b = append(b, c...)
+	}
+	return b, nil
+}
+
+// Unmarshal restores a proof from its binary encoding
+func (p *Proof) Unmarshal(b []byte) error {
+	if len(b) < 7 {
+		return errors.New("malformed encoding: too short")
+	}
+	if b[0] != binaryVersion {
+		return fmt.Errorf("unsupported version %d", b[0])
+	}
+	var h func(data ...[]byte) []byte
+	for name, id := range hashIdentifiers {
+		if id == b[1] {
+			h = HashFunctions[name]
+		}
+	}
+	if h == nil {
+		return fmt.Errorf("unknown hash identifier %d", b[1])
+	}
+	index := int(int32(binary.LittleEndian.Uint32(b[2:6])))
+	flags := b[6]
+	if flags&^(flagLeftLeaf|flagRightLeaf|flagLeftAP|flagRightAP) != 0 {
+		return fmt.Errorf("unknown flags %#x", flags)
+	}
+	b = b[7:]
+
+	var twc []byte
+	var err error
+	if twc, b, err = readComponent(b); err != nil {
+		return err
+	}
+	var c [4][]byte
+	for i := range c {
+		if flags&(1<<uint(i)) == 0 {
+			continue
+		}
+		if c[i], b, err = readComponent(b); err != nil {
+			return err
+		}
+	}
+	if len(b) != 0 {
+		return errors.New("malformed encoding: trailing data")
+	}
+
+	var lap, rap [][]byte
+	n := len(h())
+	if flags&flagLeftAP != 0 {
+		if lap, err = splitHashes(c[2], n); err != nil {
+			return err
+		}
+	}
+	if flags&flagRightAP != 0 {
+		if rap, err = splitHashes(c[3], n); err != nil {
+			return err
+		}
+	}
+	*p = Proof{hash: h, twc: twc, index: index, ll: c[0], rl: c[1], lap: lap,
+		rap: rap}
+	return nil
+}
+
+// EncodeBase64URL outputs the binary encoding of the proof as unpadded
+// base64url, which is a valid HTTP header value. The proof's hash function
+// must be the one registered as name, see Marshal.
+func (p Proof) EncodeBase64URL(name string) (string, error) {
+	if registered, ok := hashName(p.hash); !ok || registered != name {
+		return "", fmt.Errorf("proof does not use hash function %q", name)
+	}
+	b, err := p.Marshal()
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeProofBase64URL restores a proof from EncodeBase64URL. The proof's hash
+// function is looked up by name in hashFuncs, so that callers can restrict
+// which hash functions they accept (nil->HashFunctions).
+func DecodeProofBase64URL(s string,
+	hashFuncs map[string]func(...[]byte) []byte) (Proof, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Proof{}, fmt.Errorf("malformed encoding: %v", err)
+	}
+	var p Proof
+	if err := p.Unmarshal(b); err != nil {
+		return Proof{}, err
+	}
+	if hashFuncs == nil {
+		return p, nil
+	}
+	name, _ := hashName(p.hash)
+	if p.hash = hashFuncs[name]; p.hash == nil {
+		return Proof{}, fmt.Errorf("hash function %q is not accepted", name)
+	}
+	return p, nil
+}
+
+// readComponent reads a length-prefixed component, outputting the remainder
+func readComponent(b []byte) ([]byte, []byte, error) {
+	if len(b) < 2 {
+		return nil, nil, errors.New("malformed encoding: missing length")
+	}
+	n := int(binary.LittleEndian.Uint16(b))
+	if len(b) < 2+n {
+		return nil, nil, errors.New("malformed encoding: truncated component")
+	}
+	return append([]byte{}, b[2:2+n]...), b[2+n:], nil
+}
+
+// concat concatenates a list of hashes
+func concat(data [][]byte) []byte {
+	b := []byte{}
+	for _, d := range data {
+		b = append(b, d...)
+	}
+	return b
+}
+
+// splitHashes splits concatenated hashes of length n into a list
+func splitHashes(b []byte, n int) ([][]byte, error) {
+	if len(b)%n != 0 {
+		return nil, errors.New("malformed encoding: bad audit path length")
+	}
+	data := [][]byte{}
+	for ; len(b) > 0; b = b[n:] {
+		data = append(data, b[:n])
+	}
+	return data, nil
+}
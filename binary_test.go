@@ -0,0 +1,216 @@
+package lwm
+
+import (
+	"bytes"
+	"github.com/golang/example/stringutil"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestProofBinary(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	snapshot := wt.Snapshot()
+	for _, key := range []string{
+		"", "a", "zzz",
+		stringutil.Reverse("foo.com"),
+		stringutil.Reverse("sub0.foo.com"),
+		stringutil.Reverse("qux.se"),
+	} {
+		answer, proof := wt.Get(key)
+		b, err := proof.Marshal()
+		if err != nil {
+			t.Errorf("marshal failed for key %v: %v", key, err)
+			continue
+		}
+		var p Proof
+		if err := p.Unmarshal(b); err != nil {
+			t.Errorf("unmarshal failed for key %v: %v", key, err)
+			continue
+		}
+		if err := p.Verify(key, answer, len(m), snapshot); err != nil {
+			t.Errorf("round-tripped proof rejected for key %v: %v", key, err)
+		}
+		if bp, _ := p.Marshal(); !bytes.Equal(b, bp) {
+			t.Errorf("encoding is not stable for key %v", key)
+		}
+	}
+
+	// empty tree: the index must survive as -1
+	wt = MustNewWildcardTree(twc, hash, nil)
+	_, proof := wt.Get("a")
+	b, err := proof.Marshal()
+	if err != nil {
+		t.Fatalf("marshal failed for empty tree: %v", err)
+	}
+	var p Proof
+	if err := p.Unmarshal(b); err != nil {
+		t.Fatalf("unmarshal failed for empty tree: %v", err)
+	}
+	if p.index != -1 {
+		t.Errorf("index => got %v, want %v", p.index, -1)
+	}
+}
+
+func TestProofBinaryOverhead(t *testing.T) {
+	// a full range proof in a tree of depth 32
+	var ap [][]byte
+	for i := 0; i < 32; i++ {
+		ap = append(ap, hash([]byte{byte(i)}))
+	}
+	leaf := append([]byte("moc.elpmaxe"), hash()...)
+	p := Proof{hash: hash, twc: twc, index: 1 << 30, ll: leaf, rl: leaf,
+		lap: ap, rap: ap}
+	b, err := p.Marshal()
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if overhead := len(b) - 2*len(leaf) - 2*32*hashLen; overhead >= 1100 {
+		t.Errorf("overhead => got %v bytes, want less than 1100", overhead)
+	}
+}
+
+func FuzzProofBinary(f *testing.F) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	snapshot := wt.Snapshot()
+	for _, key := range []string{"", "es", "moc.oof", "moc.oof.0bus", "zzz"} {
+		f.Add(key)
+	}
+	f.Fuzz(func(t *testing.T, key string) {
+		answer, proof := wt.Get(key)
+		b, err := proof.Marshal()
+		if err != nil {
+			t.Fatalf("marshal failed for key %q: %v", key, err)
+		}
+		var p Proof
+		if err := p.Unmarshal(b); err != nil {
+			t.Fatalf("unmarshal failed for key %q: %v", key, err)
+		}
+		if err := p.Verify(key, answer, len(m), snapshot); err != nil {
+			t.Fatalf("round-tripped proof rejected for key %q: %v", key, err)
+		}
+	})
+}
+
+func FuzzVerify(f *testing.F) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	snapshot := wt.Snapshot()
+	for _, key := range []string{"", "es", "moc.oof", "moc.oof.0bus", "zzz"} {
+		_, proof := wt.Get(key)
+		b, err := proof.Marshal()
+		if err != nil {
+			f.Fatalf("marshal failed for key %q: %v", key, err)
+		}
+		f.Add(key, b)
+	}
+	f.Fuzz(func(t *testing.T, key string, b []byte) {
+		var p Proof
+		if err := p.Unmarshal(b); err != nil {
+			return
+		}
+		answer, _ := wt.Get(key)
+		p.Verify(key, answer, len(m), snapshot)
+		p.Verify(key, Answer{}, len(m), snapshot)
+	})
+}
+
+func TestProofSize(t *testing.T) {
+	wt := consistencyTree(100)
+	for _, key := range append(wt.Keys(), "", "key", "key050", "zzz") {
+		_, p := wt.Get(key)
+		b, err := p.Marshal()
+		if err != nil {
+			t.Fatalf("key %q => %v", key, err)
+		}
+		if d := len(b) - p.Size(); d < 0 || d > 9 {
+			t.Errorf("key %q => got size %d, encoding is %d bytes", key, p.Size(),
+				len(b))
+		}
+		if got, want := p.AuditPathLen(), len(p.lap)+len(p.rap); got != want {
+			t.Errorf("key %q => got audit path length %d, want %d", key, got, want)
+		}
+	}
+}
+
+func BenchmarkProofSize(b *testing.B) {
+	wt := consistencyTree(1024)
+	keys := wt.Keys()
+	hist := make(map[int]int)
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			_, p := wt.Get(key)
+			if i == 0 {
+				hist[p.Size()]++
+			}
+		}
+	}
+
+	sizes := make([]int, 0, len(hist))
+	for size := range hist {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+	for _, size := range sizes {
+		b.Logf("%5d bytes: %d proofs", size, hist[size])
+	}
+	b.ReportMetric(float64(sizes[0]), "min-bytes")
+	b.ReportMetric(float64(sizes[len(sizes)-1]), "max-bytes")
+}
+
+func TestProofBase64URL(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	key := stringutil.Reverse("foo.com")
+	answer, proof := wt.Get(key)
+	s, err := proof.EncodeBase64URL("sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range s { // token characters of an HTTP header value
+		if !strings.ContainsRune("ABCDEFGHIJKLMNOPQRSTUVWXYZ"+
+			"abcdefghijklmnopqrstuvwxyz0123456789-_", c) {
+			t.Fatalf("encoding contains %q", c)
+		}
+	}
+	for _, hashFuncs := range []map[string]func(...[]byte) []byte{
+		nil, {"sha256": hash},
+	} {
+		p, err := DecodeProofBase64URL(s, hashFuncs)
+		if err != nil {
+			t.Errorf("decode => %v", err)
+			continue
+		}
+		if err := p.Verify(key, answer, wt.Size(), wt.Snapshot()); err != nil {
+			t.Errorf("decoded proof rejected: %v", err)
+		}
+	}
+
+	if _, err := proof.EncodeBase64URL("blake3"); err == nil {
+		t.Errorf("encoded proof with the wrong hash name")
+	}
+	if _, err := DecodeProofBase64URL(s, map[string]func(...[]byte) []byte{
+		"blake3": HashFunctions["blake3"],
+	}); err == nil {
+		t.Errorf("decoded proof with a hash function that is not accepted")
+	}
+	if _, err := DecodeProofBase64URL(s+"=", nil); err == nil {
+		t.Errorf("decoded padded encoding")
+	}
+}
+
+func BenchmarkProofBase64URL(b *testing.B) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	_, proof := wt.Get(stringutil.Reverse("foo.com"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s, err := proof.EncodeBase64URL("sha256")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := DecodeProofBase64URL(s, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+)
+
+// blindedPayload is the payload hash and MIME types of a key in a blinded tree,
+// which NewWildcardTree accepts as a value so that blinded trees can be rebuilt
+type blindedPayload struct {
+	hash      []byte
+	mimeTypes []string // nil->n/a
+}
+
+// storedPayload outputs the payload that the tree stores in its radix tree
+func (wt *WildcardTree) storedPayload(payload [][]byte) [][]byte {
+	if wt.blinded {
+		return nil
+	}
+	return payload
+}
+
+// Reveal outputs an answer that contains key with payload if payload hashes
+// to the leaf of key, i.e., the answer verifies with the proof from GetExact.
+// This allows a blinded tree to confirm payloads that are stored elsewhere,
+// see WithBlindedPayloads. ErrKeyNotFound is returned if key is not present.
+func (wt *WildcardTree) Reveal(key string, payload [][]byte) (Answer, error) {
+	key = wt.normalizeKey(key)
+	v, ok := wt.r.Get(key)
+	if !ok {
+		return Answer{}, ErrKeyNotFound
+	}
+	ph := wt.payloadHash(wt.mt.data[v.(radixValue).index])
+	if !bytes.Equal(wt.mt.hash(payload...), ph) {
+		return Answer{}, errors.New("payload does not match the leaf")
+	}
+	return Answer{subject: []string{key}, payload: [][][]byte{payload}}, nil
+}
@@ -0,0 +1,95 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"github.com/golang/example/stringutil"
+	"testing"
+)
+
+func TestBlindedPayloads(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	bt := MustNewWildcardTree(twc, hash, m, WithBlindedPayloads())
+	if !bytes.Equal(bt.Snapshot(), wt.Snapshot()) {
+		t.Fatalf("blinding changed the snapshot")
+	}
+
+	key := stringutil.Reverse("foo.com")
+	answer, proof := bt.Get(key)
+	if len(answer.Subjects()) != 3 {
+		t.Fatalf("got %d matches, want 3", len(answer.Subjects()))
+	}
+	for i, payload := range answer.Payloads() {
+		if payload != nil {
+			t.Errorf("match %d => got payload %q, want nil", i, payload)
+		}
+	}
+	err := proof.Verify(key, answer, bt.Size(), bt.Snapshot())
+	if verr := (*VerificationError)(nil); !errors.As(err, &verr) ||
+		verr.Kind != KindRootMismatch {
+		t.Errorf("blinded answer => got %v, want root mismatch", err)
+	}
+
+	// revealed payloads verify with the proof of an exact match
+	for _, subject := range answer.Subjects() {
+		revealed, err := bt.Reveal(subject, m[subject].([][]byte))
+		if err != nil {
+			t.Errorf("reveal %q => %v", subject, err)
+			continue
+		}
+		_, exactProof, _ := bt.GetExact(subject)
+		if err := exactProof.Verify(subject, revealed, bt.Size(),
+			bt.Snapshot()); err != nil {
+			t.Errorf("revealed %q => %v", subject, err)
+		}
+	}
+	if _, err := bt.Reveal(key, [][]byte{[]byte("other cert")}); err == nil {
+		t.Errorf("revealed a payload that does not match")
+	}
+	if _, err := bt.Reveal("moc.rab", nil); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("absent key => got %v", err)
+	}
+	if _, err := bt.Marshal("sha256"); err == nil {
+		t.Errorf("marshaled a blinded tree")
+	}
+}
+
+func TestBlindedPayloadsMutations(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	bt := MustNewWildcardTree(twc, hash, testData(), WithBlindedPayloads())
+	for _, tree := range []*WildcardTree{wt, bt} {
+		if err := tree.Add("moc.rab", [][]byte{[]byte("bar.com cert")}); err != nil {
+			t.Fatalf("add failed: %v", err)
+		}
+		if err := tree.Update(stringutil.Reverse("baz.gov"),
+			[][]byte{[]byte("new cert")}); err != nil {
+			t.Fatalf("update failed: %v", err)
+		}
+		tx := tree.Begin()
+		tx.Add("moc.zab", [][]byte{[]byte("baz.com cert")})
+		tx.Remove(stringutil.Reverse("qux.se"))
+		if _, err := tx.Commit(); err != nil {
+			t.Fatalf("commit failed: %v", err)
+		}
+	}
+	if !bytes.Equal(bt.Snapshot(), wt.Snapshot()) {
+		t.Errorf("mutations => blinded snapshot differs")
+	}
+	if a, _ := bt.Get("moc.rab"); a.Payloads()[0] != nil {
+		t.Errorf("added payload is not blinded")
+	}
+
+	for desc, pair := range map[string][2]*WildcardTree{
+		"compact": {wt.Compact(), bt.Compact()},
+		"rotate": {wt.Rotate([]byte("new twc")),
+			bt.Rotate([]byte("new twc"))},
+	} {
+		if !bytes.Equal(pair[1].Snapshot(), pair[0].Snapshot()) {
+			t.Errorf("%s => blinded snapshot differs", desc)
+		}
+		if a, _ := pair[1].Get("moc.rab"); a.Payloads()[0] != nil {
+			t.Errorf("%s => payload is not blinded", desc)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package lwm
+
+import (
+	"fmt"
+	"sort"
+)
+
+// BulkDelete removes all keys with a single rebuild of the Merkle tree, which
+// is faster than calling Remove for each key. Keys that are not present are
+// returned in the order they were given, since partial success is useful.
+// A change event is emitted for each removed key, see OnChange.
+func (wt *WildcardTree) BulkDelete(keys []string) ([]string, error) {
+	old := wt.oldSnapshot()
+	var missing, removedKeys []string
+	removed := make(map[int]bool, len(keys))
+	deleted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		normalized := wt.normalizeKey(key)
+		if deleted[normalized] {
+			continue
+		}
+		v, ok := wt.r.Delete(normalized)
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		deleted[normalized] = true
+		removed[v.(radixValue).index] = true
+		removedKeys = append(removedKeys, normalized)
+	}
+	if len(removed) == 0 {
+		return missing, nil
+	}
+
+	data := make([][]byte, 0, len(wt.mt.data)-len(removed))
+	for i, leaf := range wt.mt.data {
+		if !removed[i] {
+			data = append(data, leaf)
+		}
+	}
+	wt.setData(data)
+	for _, key := range removedKeys {
+		wt.notify(EventRemoved, key, old)
+	}
+	return missing, nil
+}
+
+// BulkAdd inserts all entries with a single rebuild of the Merkle tree, which
+// is faster than calling Add for each entry. Entries are checked like Add, and
+// if an entry is rejected an error is returned and the tree is unchanged.
+// A change event is emitted for each added key, see OnChange.
+func (wt *WildcardTree) BulkAdd(entries []Entry) error {
+	type pending struct {
+		key     string
+		payload [][]byte
+		leaf    []byte
+	}
+	added := make([]pending, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for i, e := range entries {
+		key := wt.normalizeKey(e.Key)
+		err := wt.validateKey(key)
+		if _, ok := wt.r.Get(key); err == nil && (ok || seen[key]) {
+			err = ErrKeyExists
+		}
+		if err == nil {
+			err = wt.checkLimits(key, e.Payload)
+		}
+		var leaf []byte
+		if err == nil {
+			leaf, err = wt.leafData(key, wt.mt.hash(e.Payload...))
+		}
+		if err != nil {
+			return fmt.Errorf("entry %d: %q: %w", i, key, err)
+		}
+		seen[key] = true
+		added = append(added, pending{key, e.Payload, leaf})
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	var keys []string
+	for _, p := range added {
+		keys = append(keys, p.key)
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].key < added[j].key })
+
+	// merge the sorted leaves, indices are assigned by setData
+	data := make([][]byte, 0, len(wt.mt.data)+len(added))
+	i := 0
+	for _, p := range added {
+		for ; i < len(wt.mt.data) && wt.leafKey(wt.mt.data[i]) < p.key; i++ {
+			data = append(data, wt.mt.data[i])
+		}
+		data = append(data, p.leaf)
+		wt.r.Insert(p.key, radixValue{payload: wt.storedPayload(p.payload)})
+	}
+	data = append(data, wt.mt.data[i:]...)
+	old := wt.oldSnapshot()
+	wt.setData(data)
+	for _, key := range keys {
+		wt.notify(EventAdded, key, old)
+	}
+	return nil
+}
+
+// setData replaces the leaves of the Merkle tree with data, which must be in
+// radix order, and updates the leaf index of every key in the radix tree
+func (wt *WildcardTree) setData(data [][]byte) {
+	reindexed := make(map[string]radixValue)
+	i := 0
+	wt.r.WalkPrefix("", func(key string, v interface{}) bool {
+		if rv := v.(radixValue); rv.index != i {
+			rv.index = i
+			reindexed[key] = rv
+		}
+		i++
+		return false
+	})
+	for key, rv := range reindexed {
+		wt.r.Insert(key, rv)
+	}
+	wt.mt.Release()
+	wt.snapshot = nil
+	wt.mt = NewMerkleTree(wt.mt.twc, wt.mt.leafPrefix, wt.mt.interiorPrefix,
+		wt.mt.hash, data)
+}
@@ -0,0 +1,115 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBulkDelete(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	missing, err := wt.BulkDelete([]string{"moc.oof", "none", "vog.zab",
+		"moc.oof", "es.xuq.bus", "other"})
+	if err != nil {
+		t.Fatalf("bulk delete failed: %v", err)
+	}
+	if want := []string{"none", "other"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("got missing keys %v, want %v", missing, want)
+	}
+	m := testData()
+	for _, key := range []string{"moc.oof", "vog.zab", "es.xuq.bus"} {
+		delete(m, key)
+	}
+	checkBulkTree(t, wt, m)
+
+	if missing, err := wt.BulkDelete([]string{"none"}); err != nil ||
+		!reflect.DeepEqual(missing, []string{"none"}) {
+		t.Errorf("got (%v, %v) for a missing key", missing, err)
+	}
+	if _, err := wt.BulkDelete(wt.Keys()); err != nil || wt.Size() != 0 {
+		t.Errorf("failed to delete all keys: %v", err)
+	}
+	checkBulkTree(t, wt, map[string]interface{}{})
+}
+
+func TestBulkAdd(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	var entries []Entry
+	for _, key := range []string{"moc.oof.3bus", "a", "zzz", "moc"} {
+		entries = append(entries, Entry{key, [][]byte{[]byte(key + " cert")}})
+		m[key] = [][]byte{[]byte(key + " cert")}
+	}
+	if err := wt.BulkAdd(entries); err != nil {
+		t.Fatalf("bulk add failed: %v", err)
+	}
+	checkBulkTree(t, wt, m)
+
+	snapshot := wt.Snapshot()
+	for _, table := range []struct {
+		desc    string
+		entries []Entry
+	}{
+		{"existing key", []Entry{{"new", nil}, {"moc.oof", nil}}},
+		{"duplicate key", []Entry{{"new", nil}, {"new", nil}}},
+	} {
+		if err := wt.BulkAdd(table.entries); !errors.Is(err, ErrKeyExists) {
+			t.Errorf("%s => got error %v, want %v", table.desc, err, ErrKeyExists)
+		}
+		if !bytes.Equal(wt.Snapshot(), snapshot) || wt.Contains("new") {
+			t.Errorf("%s => tree was modified", table.desc)
+		}
+	}
+
+	wt = MustNewWildcardTree(twc, hash, nil, WithMaxPayloadCount(1))
+	if err := wt.BulkAdd([]Entry{{"a", nil},
+		{"b", [][]byte{nil, nil}}}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got error %v, want %v", err, ErrLimitExceeded)
+	}
+	if wt.Size() != 0 {
+		t.Errorf("tree was modified")
+	}
+}
+
+// checkBulkTree checks that wt has the same snapshot as a fresh tree for m,
+// and that every key has a valid proof
+func checkBulkTree(t *testing.T, wt *WildcardTree, m map[string]interface{}) {
+	t.Helper()
+	if want := MustNewWildcardTree(twc, hash, m).Snapshot(); !bytes.Equal(
+		wt.Snapshot(), want) {
+		t.Errorf("got snapshot %x, want %x", wt.Snapshot(), want)
+	}
+	for key := range m {
+		a, p := wt.Get(key)
+		if err := p.Verify(key, a, wt.Size(), wt.Snapshot()); err != nil {
+			t.Errorf("proof for %q does not verify: %v", key, err)
+		}
+	}
+}
+
+func BenchmarkBulkDelete(b *testing.B) {
+	var keys []string
+	for i := 0; i < 10000; i += 10 {
+		keys = append(keys, fmt.Sprintf("key%03d", i))
+	}
+	b.Run("Remove", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			c := consistencyTree(10000)
+			b.StartTimer()
+			for _, key := range keys {
+				c.Remove(key)
+			}
+		}
+	})
+	b.Run("BulkDelete", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			c := consistencyTree(10000)
+			b.StartTimer()
+			c.BulkDelete(keys)
+		}
+	})
+}
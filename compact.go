@@ -0,0 +1,207 @@
+package lwm
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+const (
+	// radixNodeSize approximates the go-radix overhead per key: one node, one
+	// leaf node, and one edge in the parent node
+	radixNodeSize = 96
+	sliceSize     = int(unsafe.Sizeof([]byte(nil)))
+)
+
+// MemStats contains estimated memory usage in bytes. The estimates are based
+// on sizes of keys, payloads, and hashes plus approximate per-node overheads.
+type MemStats struct {
+	RadixTree int // radix nodes, keys, and payloads
+	Leaves    int // Merkle tree leaf data
+	HashCache int // cached Merkle tree hashes
+}
+
+// Total outputs the sum of all estimates
+func (ms MemStats) Total() int {
+	return ms.RadixTree + ms.Leaves + ms.HashCache
+}
+
+// Compact outputs a new tree with the same entries, options, and snapshot, but
+// with a freshly built radix tree and hash cache. Payloads are not copied.
+func (wt *WildcardTree) Compact() *WildcardTree {
+	compacted := wt.mustRebuild(wt.mt.twc)
+	compacted.listeners = wt.activeListeners()
+	wt.notify(EventCompacted, "", wt.oldSnapshot())
+	return compacted
+}
+
+// Rotate outputs a new tree with the same entries and options, but with the
+// tree-wide constant newTwc. Every leaf and hence the snapshot changes, which
+// means that proofs generated with the old twc are invalid for the new tree.
+// Payloads are not copied.
+func (wt *WildcardTree) Rotate(newTwc []byte) *WildcardTree {
+	return wt.mustRebuild(cloneBytes(newTwc))
+}
+
+// mustRebuild outputs a new tree with all entries and the tree-wide constant
+// twc. The entries already passed the same checks, so rebuilding cannot fail.
+func (wt *WildcardTree) mustRebuild(twc []byte) *WildcardTree {
+	rebuilt, err := wt.rebuild(twc, wt.toMap())
+	if err != nil {
+		panic("This should never happen")
+	}
+	return rebuilt
+}
+
+// Prune outputs a new tree with the same options, but only with the entries
+// whose keys k satisfy start <= k <= end. The receiver is not modified.
+// Payloads are not copied.
+func (wt *WildcardTree) Prune(start, end string) (*WildcardTree, error) {
+	start, end = wt.normalizeKey(start), wt.normalizeKey(end)
+	if start > end {
+		return nil, fmt.Errorf("start %q is after end %q", start, end)
+	}
+	return wt.prune(func(key string) bool {
+		return start <= key && key <= end
+	})
+}
+
+// PrunePrefix outputs a new tree with the same options, but only with the
+// entries that match the wildcard key prefix. The receiver is not modified.
+// Payloads are not copied.
+func (wt *WildcardTree) PrunePrefix(prefix string) (*WildcardTree, error) {
+	prefix = wt.normalizeKey(prefix)
+	return wt.prune(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// prune outputs a new tree with the entries whose keys are kept
+func (wt *WildcardTree) prune(keep func(key string) bool) (*WildcardTree,
+	error) {
+	m := wt.toMap()
+	for key := range m {
+		if !keep(key) {
+			delete(m, key)
+		}
+	}
+	return wt.rebuild(wt.mt.twc, m)
+}
+
+// Reindex assigns contiguous Merkle tree indices in key order to all entries,
+// rebuilds the leaf data accordingly, and invalidates the hash cache. All
+// mutations keep indices contiguous and ordered, so this only changes the tree
+// if the radix tree and the leaf data went out of sync, e.g., because of a bug.
+// Leaves without a key are dropped, and missing leaves are rebuilt from the
+// stored payloads. In that case Snapshot() changes, and proofs generated
+// before are invalidated. An error is returned and the tree is unchanged if a
+// leaf is missing in a blinded tree, since its payload is not stored.
+func (wt *WildcardTree) Reindex() error {
+	leaves := make(map[string][]byte, len(wt.mt.data))
+	for _, leaf := range wt.mt.data {
+		leaves[wt.leafKey(leaf)] = leaf
+	}
+	data := make([][]byte, 0, wt.r.Len())
+	var err error
+	wt.r.WalkPrefix("", func(key string, v interface{}) bool {
+		leaf, ok := leaves[key]
+		if !ok {
+			if wt.blinded {
+				err = fmt.Errorf("%q: missing leaf of blinded payload", key)
+				return true
+			}
+			payload := v.(radixValue).payload
+			if leaf, err = wt.leafData(key, wt.mt.hash(payload...)); err != nil {
+				return true
+			}
+		}
+		data = append(data, leaf)
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	wt.setData(data)
+	return nil
+}
+
+// toMap outputs all key-value pairs as a map, with payload hashes as values in
+// blinded trees and typed payloads if there are MIME types
+func (wt *WildcardTree) toMap() map[string]interface{} {
+	m := make(map[string]interface{}, wt.Size())
+	wt.r.WalkPrefix("", func(key string, v interface{}) bool {
+		rv := v.(radixValue)
+		switch {
+		case wt.blinded:
+			m[key] = blindedPayload{wt.payloadHash(wt.mt.data[rv.index]),
+				rv.mimeTypes}
+		case rv.mimeTypes != nil:
+			m[key] = TypedPayload{rv.payload, rv.mimeTypes}
+		default:
+			m[key] = rv.payload
+		}
+		return false
+	})
+	return m
+}
+
+// rebuild outputs a new tree with the same options, twc, and the key-value
+// pairs in m, which must be normalized and validated. An error that wraps
+// ErrLimitExceeded is returned if an entry in m exceeds the tree's limits.
+func (wt *WildcardTree) rebuild(twc []byte,
+	m map[string]interface{}) (*WildcardTree, error) {
+	opts := []WildcardTreeOption{WithLeafPrefix(wt.mt.leafPrefix),
+		WithInteriorPrefix(wt.mt.interiorPrefix)}
+	if wt.blinded {
+		opts = append(opts, WithBlindedPayloads())
+	}
+	if wt.hashLength > 0 {
+		opts = append(opts, WithHashLength(wt.hashLength))
+	}
+	if wt.nonce != nil {
+		opts = append(opts, WithLeafNonce(wt.nonce))
+	}
+	if wt.maxKeyLength > 0 {
+		opts = append(opts, WithMaxKeyLength(wt.maxKeyLength))
+	}
+	if wt.maxPayloadSize > 0 {
+		opts = append(opts, WithMaxPayloadSize(wt.maxPayloadSize))
+	}
+	if wt.maxPayloadCount > 0 {
+		opts = append(opts, WithMaxPayloadCount(wt.maxPayloadCount))
+	}
+	rebuilt, err := NewWildcardTree(twc, wt.hash(), m, opts...)
+	if err != nil {
+		return nil, err
+	}
+	rebuilt.normalize = wt.normalize
+	rebuilt.validate = wt.validate
+	return rebuilt, nil
+}
+
+// MemoryUsage outputs estimated memory usage, which callers may use to decide
+// when to compact a tree that has seen many mutations
+func (wt *WildcardTree) MemoryUsage() (ms MemStats) {
+	wt.ForEach(func(key string, payload [][]byte) bool {
+		ms.RadixTree += radixNodeSize + int(unsafe.Sizeof(radixValue{})) +
+			len(key)
+		for _, p := range payload {
+			ms.RadixTree += sliceSize + len(p)
+		}
+		return true
+	})
+	for _, d := range wt.mt.data {
+		ms.Leaves += sliceSize + len(d)
+	}
+	ms.HashCache = wt.mt.cache.memoryUsage()
+	return
+}
+
+// memoryUsage outputs the size of all reachable hash cache nodes
+func (c *hashCache) memoryUsage() int {
+	if c == nil {
+		return 0
+	}
+	return int(unsafe.Sizeof(*c)) + len(c.this) + c.left.memoryUsage() +
+		c.right.memoryUsage()
+}
@@ -0,0 +1,292 @@
+package lwm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData(),
+		WithKeyNormalizer(ReversedLowercaseDomain))
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key%02d.example", i)
+		if err := wt.Add(key, [][]byte{[]byte(key)}); err != nil {
+			t.Fatalf("add %q: %v", key, err)
+		}
+		wt.Snapshot()
+		if i%2 == 0 {
+			if err := wt.Remove(key); err != nil {
+				t.Fatalf("remove %q: %v", key, err)
+			}
+		}
+	}
+	if err := wt.Update("key01.example", [][]byte{[]byte("updated")}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	compact := wt.Compact()
+	if !bytes.Equal(compact.Snapshot(), wt.Snapshot()) {
+		t.Errorf("got snapshot %x, want %x", compact.Snapshot(), wt.Snapshot())
+	}
+	if fmt.Sprint(compact.Entries()) != fmt.Sprint(wt.Entries()) {
+		t.Errorf("got entries %v, want %v", compact.Entries(), wt.Entries())
+	}
+	for _, key := range []string{"example", "key01.example", "foo.com",
+		"missing.example", ""} {
+		a, p := compact.Get(key)
+		if err := p.Verify(ReversedLowercaseDomain(key), a, wt.Size(),
+			wt.Snapshot()); err != nil {
+			t.Errorf("key %q => %v", key, err)
+		}
+		if want, _ := wt.Get(key); fmt.Sprint(a) != fmt.Sprint(want) {
+			t.Errorf("key %q => got answer %v, want %v", key, a, want)
+		}
+	}
+	if !compact.Contains("KEY03.example.") {
+		t.Errorf("key normalizer was not kept")
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	cold := wt.MemoryUsage()
+	if cold.RadixTree == 0 || cold.Leaves == 0 {
+		t.Errorf("got zero estimates: %+v", cold)
+	}
+	wt.Snapshot()
+	warm := wt.MemoryUsage()
+	if warm.HashCache <= cold.HashCache {
+		t.Errorf("populated cache => got %d bytes, want more than %d",
+			warm.HashCache, cold.HashCache)
+	}
+	if warm.Total() != warm.RadixTree+warm.Leaves+warm.HashCache {
+		t.Errorf("bad total %d for %+v", warm.Total(), warm)
+	}
+
+	for _, key := range wt.Keys()[1:] {
+		if err := wt.Remove(key); err != nil {
+			t.Fatalf("remove %q: %v", key, err)
+		}
+	}
+	if got := wt.MemoryUsage(); got.Total() >= cold.Total() {
+		t.Errorf("removed keys => got %d bytes, want less than %d", got.Total(),
+			cold.Total())
+	}
+	if got := MustNewWildcardTree(twc, hash, nil).MemoryUsage(); got.RadixTree !=
+		0 || got.Leaves != 0 {
+		t.Errorf("empty tree => got %+v", got)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	snapshot := wt.Snapshot()
+	newTwc := []byte("new twc")
+	rotated := wt.Rotate(newTwc)
+	newTwc[0] ^= 1 // the rotated tree keeps its own copy
+
+	if got, want := rotated.TWC(), []byte("new twc"); !bytes.Equal(got, want) {
+		t.Errorf("got twc %q, want %q", got, want)
+	}
+	if !bytes.Equal(wt.TWC(), twc) || !bytes.Equal(wt.Snapshot(), snapshot) {
+		t.Errorf("rotation modified the original tree")
+	}
+	if bytes.Equal(rotated.Snapshot(), snapshot) {
+		t.Errorf("rotation did not change the snapshot")
+	}
+	if fmt.Sprint(rotated.Entries()) != fmt.Sprint(wt.Entries()) {
+		t.Errorf("got entries %v, want %v", rotated.Entries(), wt.Entries())
+	}
+
+	for _, key := range []string{"", "moc.oof", "moc.rab"} {
+		a, p := wt.Get(key)
+		if err := p.Verify(key, a, rotated.Size(), rotated.Snapshot()); err == nil {
+			t.Errorf("key %q => pre-rotation proof accepted", key)
+		}
+		a, p = rotated.Get(key)
+		if err := p.Verify(key, a, rotated.Size(), rotated.Snapshot()); err != nil {
+			t.Errorf("key %q => post-rotation proof rejected: %v", key, err)
+		}
+	}
+}
+
+func TestReindex(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key%02d", i)
+		if err := wt.Add(key, [][]byte{[]byte(key)}); err != nil {
+			t.Fatal(err)
+		}
+		if i%3 != 0 {
+			if err := wt.Remove(key); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	fresh := MustNewWildcardTree(twc, hash, wt.toMap())
+	snapshot := wt.Snapshot()
+	mustReindex(t, wt)
+	if !bytes.Equal(wt.Snapshot(), snapshot) {
+		t.Errorf("reindexing contiguous indices changed the snapshot")
+	}
+	if !bytes.Equal(wt.Snapshot(), fresh.Snapshot()) {
+		t.Errorf("got snapshot %x, want %x", wt.Snapshot(), fresh.Snapshot())
+	}
+
+	// leave a gap by removing a key from the radix tree only, which breaks
+	// proofs for keys to the right of the gap until the tree is reindexed
+	removed := "moc.oof.1bus"
+	wt.r.Delete(removed)
+	if a, p := wt.Get("vog"); p.Verify("vog", a, wt.Size()-1,
+		wt.Snapshot()) == nil {
+		t.Errorf("proof verified before reindexing")
+	}
+	mustReindex(t, wt)
+	fresh.Remove(removed)
+	if !bytes.Equal(wt.Snapshot(), fresh.Snapshot()) {
+		t.Errorf("got snapshot %x, want %x", wt.Snapshot(), fresh.Snapshot())
+	}
+	for _, key := range []string{"", "moc.oof", "key", "vog", removed} {
+		a, p := wt.Get(key)
+		if err := p.Verify(key, a, wt.Size(), wt.Snapshot()); err != nil {
+			t.Errorf("key %q => %v", key, err)
+		}
+	}
+
+	// swap the indices of two keys in the radix tree
+	v1, _ := wt.r.Get("moc.oof")
+	v2, _ := wt.r.Get("vog.zab")
+	rv1, rv2 := v1.(radixValue), v2.(radixValue)
+	rv1.index, rv2.index = rv2.index, rv1.index
+	wt.r.Insert("moc.oof", rv1)
+	wt.r.Insert("vog.zab", rv2)
+	if a, p := wt.Get("moc.oof"); p.Verify("moc.oof", a, wt.Size(),
+		wt.Snapshot()) == nil {
+		t.Errorf("proof verified before reindexing")
+	}
+	mustReindex(t, wt)
+	for _, key := range []string{"moc.oof", "vog.zab"} {
+		a, p := wt.Get(key)
+		if err := p.Verify(key, a, wt.Size(), wt.Snapshot()); err != nil {
+			t.Errorf("key %q => %v", key, err)
+		}
+	}
+	if !bytes.Equal(wt.Snapshot(), fresh.Snapshot()) {
+		t.Errorf("got snapshot %x, want %x", wt.Snapshot(), fresh.Snapshot())
+	}
+}
+
+func TestReindexMissingLeaves(t *testing.T) {
+	nonce := func(key string) []byte { return hash([]byte("secret"), []byte(key)) }
+	for _, table := range []struct {
+		desc string
+		opts []WildcardTreeOption
+	}{
+		{"default", nil},
+		{"truncated", []WildcardTreeOption{WithHashLength(16)}},
+		{"nonce", []WildcardTreeOption{WithLeafNonce(nonce)}},
+	} {
+		wt := MustNewWildcardTree(twc, hash, testData(), table.opts...)
+		snapshot := wt.Snapshot()
+
+		// drop the leaves of two keys and add a leaf without a key
+		var data [][]byte
+		for _, leaf := range wt.mt.data {
+			if key := wt.leafKey(leaf); key != "moc.oof" && key != "vog.zab" {
+				data = append(data, leaf)
+			}
+		}
+		extra, _ := wt.leafData("none", hash([]byte("none")))
+		wt.setData(append(data, extra))
+		if bytes.Equal(wt.Snapshot(), snapshot) {
+			t.Fatalf("%s => snapshot did not change", table.desc)
+		}
+		mustReindex(t, wt)
+		if !bytes.Equal(wt.Snapshot(), snapshot) {
+			t.Errorf("%s => got snapshot %x, want %x", table.desc, wt.Snapshot(),
+				snapshot)
+		}
+		for _, key := range []string{"", "moc.oof", "vog.zab", "none"} {
+			a, p := wt.Get(key)
+			if err := p.VerifyWithOptions(key, a, wt.Size(), wt.Snapshot(),
+				WithNonceProvider(nonce)); err != nil {
+				t.Errorf("%s => key %q => %v", table.desc, key, err)
+			}
+		}
+	}
+
+	// blinded payloads are not stored, so missing leaves cannot be rebuilt
+	bt := MustNewWildcardTree(twc, hash, testData(), WithBlindedPayloads())
+	bt.setData(bt.mt.data[1:])
+	snapshot := bt.Snapshot()
+	if err := bt.Reindex(); err == nil {
+		t.Errorf("blinded => reindexed a missing leaf")
+	}
+	if !bytes.Equal(bt.Snapshot(), snapshot) {
+		t.Errorf("blinded => tree was modified")
+	}
+}
+
+func mustReindex(t *testing.T, wt *WildcardTree) {
+	t.Helper()
+	if err := wt.Reindex(); err != nil {
+		t.Fatalf("reindex failed: %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData(),
+		WithKeyNormalizer(strings.ToLower))
+	snapshot := wt.Snapshot()
+	for _, table := range []struct {
+		desc   string
+		prune  func() (*WildcardTree, error)
+		remain []string
+	}{
+		{"range", func() (*WildcardTree, error) {
+			return wt.Prune("es.xuq.bus", "moc.oof.1bus")
+		}, []string{"es.xuq.bus", "moc.oof", "moc.oof.1bus"}},
+		{"normalized range", func() (*WildcardTree, error) {
+			return wt.Prune("MOC", "MOC.OOF")
+		}, []string{"moc.oof"}},
+		{"empty range", func() (*WildcardTree, error) {
+			return wt.Prune("a", "b")
+		}, nil},
+		{"prefix", func() (*WildcardTree, error) {
+			return wt.PrunePrefix("moc.oof")
+		}, []string{"moc.oof", "moc.oof.1bus", "moc.oof.2bus"}},
+		{"empty prefix", func() (*WildcardTree, error) {
+			return wt.PrunePrefix("")
+		}, wt.Keys()},
+	} {
+		pruned, err := table.prune()
+		if err != nil {
+			t.Errorf("%s => %v", table.desc, err)
+			continue
+		}
+		if got := pruned.Keys(); fmt.Sprint(got) != fmt.Sprint(table.remain) {
+			t.Errorf("%s => got keys %v, want %v", table.desc, got, table.remain)
+		}
+		m := make(map[string]interface{})
+		for _, key := range table.remain {
+			m[key] = testData()[key]
+		}
+		if want := MustNewWildcardTree(twc, hash, m).Snapshot(); !bytes.Equal(
+			pruned.Snapshot(), want) {
+			t.Errorf("%s => got snapshot %x, want %x", table.desc,
+				pruned.Snapshot(), want)
+		}
+		if err := pruned.Add("MOC.RAB", nil); err != nil ||
+			!pruned.ContainsExact("moc.rab") {
+			t.Errorf("%s => pruned tree lost its options", table.desc)
+		}
+	}
+	if !bytes.Equal(wt.Snapshot(), snapshot) || wt.Size() != 7 {
+		t.Errorf("pruning modified the original tree")
+	}
+	if _, err := wt.Prune("b", "a"); err == nil {
+		t.Errorf("accepted start after end")
+	}
+}
@@ -0,0 +1,319 @@
+package lwm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// CompressedBatch is a list of proofs where the tree-wide constants, leaf data,
+// and audit path hashes are stored once in a dictionary and referenced by index
+type CompressedBatch struct {
+	dict   [][]byte
+	proofs []compressedProof
+}
+
+type compressedProof struct {
+	hash     func(data ...[]byte) []byte
+	twc      int   // dictionary reference
+	index    int   // first mt index (or where it should be)
+	ll, rl   int   // dictionary references (-1->n/a)
+	lap, rap []int // dictionary references (nil->n/a)
+
+	leafPrefix, interiorPrefix []byte // nil->default prefixes
+	hashLength                 int    // truncated hash length (0->untruncated)
+	nonceLength                int    // leaf nonce length (0->n/a)
+}
+
+// CompressProofs outputs a compressed batch of proofs. Adjacent range proofs
+// typically share leaves and many audit path hashes near the root.
+func CompressProofs(proofs []Proof) CompressedBatch {
+	var cb CompressedBatch
+	refs := make(map[string]int)
+	ref := func(b []byte) int {
+		if b == nil {
+			return -1
+		}
+		if i, ok := refs[string(b)]; ok {
+			return i
+		}
+		refs[string(b)] = len(cb.dict)
+		cb.dict = append(cb.dict, b)
+		return len(cb.dict) - 1
+	}
+	refList := func(data [][]byte) []int {
+		if data == nil {
+			return nil
+		}
+		l := make([]int, len(data))
+		for i, d := range data {
+			l[i] = ref(d)
+		}
+		return l
+	}
+	for _, p := range proofs {
+		cb.proofs = append(cb.proofs, compressedProof{
+			hash:  p.hash,
+			twc:   ref(append([]byte{}, p.twc...)), // nil and empty are equivalent
+			index: p.index,
+			ll:    ref(p.ll),
+			rl:    ref(p.rl),
+			lap:   refList(p.lap),
+			rap:   refList(p.rap),
+
+			leafPrefix:     p.leafPrefix,
+			interiorPrefix: p.interiorPrefix,
+			hashLength:     p.hashLength,
+			nonceLength:    p.nonceLength,
+		})
+	}
+	return cb
+}
+
+// DecompressProofs restores a list of proofs from a compressed batch
+func DecompressProofs(cb CompressedBatch) ([]Proof, error) {
+	get := func(i int) ([]byte, error) {
+		if i < -1 || i >= len(cb.dict) {
+			return nil, fmt.Errorf("bad dictionary reference %d", i)
+		}
+		if i == -1 {
+			return nil, nil
+		}
+		return cb.dict[i], nil
+	}
+	getList := func(l []int) ([][]byte, error) {
+		if l == nil {
+			return nil, nil
+		}
+		data := make([][]byte, len(l))
+		for i, r := range l {
+			d, err := get(r)
+			if err != nil {
+				return nil, err
+			}
+			if d == nil {
+				return nil, errors.New("audit path hash is missing")
+			}
+			data[i] = d
+		}
+		return data, nil
+	}
+
+	proofs := make([]Proof, len(cb.proofs))
+	for i, cp := range cb.proofs {
+		p := Proof{hash: cp.hash, index: cp.index, leafPrefix: cp.leafPrefix,
+			interiorPrefix: cp.interiorPrefix, hashLength: cp.hashLength,
+			nonceLength: cp.nonceLength}
+		var err error
+		if p.twc, err = get(cp.twc); err != nil {
+			return nil, err
+		}
+		if p.ll, err = get(cp.ll); err != nil {
+			return nil, err
+		}
+		if p.rl, err = get(cp.rl); err != nil {
+			return nil, err
+		}
+		if p.lap, err = getList(cp.lap); err != nil {
+			return nil, err
+		}
+		if p.rap, err = getList(cp.rap); err != nil {
+			return nil, err
+		}
+		proofs[i] = p
+	}
+	return proofs, nil
+}
+
+// Compressed batch encoding: a version byte followed by TLV records, i.e., a
+// 1-byte type, a 2-byte little-endian length, and a value. Dictionary records
+// contain raw bytes, and must precede the proof records that reference them.
+// Proof records contain a hash identifier, a 4-byte index, a flag byte like
+// the binary proof format, and uvarint dictionary references for twc, ll, rl,
+// and the length-prefixed audit paths (absent components are omitted).
+const (
+	tlvDictionary = 1
+	tlvProof      = 2
+)
+
+// Marshal outputs a compact binary encoding of the batch. The hash functions
+// of all proofs must be registered by name, and their prefixes must be the
+// defaults.
+func (cb CompressedBatch) Marshal() ([]byte, error) {
+	b := []byte{binaryVersion}
+	for _, d := range cb.dict {
+		var err error
+		if b, err = appendTLV(b, tlvDictionary, d); err != nil {
+			return nil, err
+		}
+	}
+	for _, cp := range cb.proofs {
+		name, ok := hashName(cp.hash)
+		if !ok {
+			return nil, errors.New("unregistered hash function")
+		}
+		p := Proof{leafPrefix: cp.leafPrefix, interiorPrefix: cp.interiorPrefix}
+		if !p.defaultPrefixes() {
+			return nil, errors.New("custom prefixes cannot be encoded")
+		}
+		if cp.hashLength > 0 {
+			return nil, errors.New("truncated hashes cannot be encoded")
+		}
+		if cp.nonceLength > 0 {
+			return nil, errors.New("leaf nonces cannot be encoded")
+		}
+		if cp.index < math.MinInt32 || cp.index > math.MaxInt32 {
+			return nil, fmt.Errorf("index %d does not fit in 32 bits", cp.index)
+		}
+		var flags uint8
+		for i, present := range []bool{cp.ll >= 0, cp.rl >= 0, cp.lap != nil,
+			cp.rap != nil} {
+			if present {
+				flags |= 1 << uint(i)
+			}
+		}
+		v := []byte{hashIdentifiers[name]}
+		v = binary.LittleEndian.AppendUint32(v, uint32(int32(cp.index)))
+		v = append(v, flags)
+		v = binary.AppendUvarint(v, uint64(cp.twc))
+		for _, r := range []int{cp.ll, cp.rl} {
+			if r >= 0 {
+				v = binary.AppendUvarint(v, uint64(r))
+			}
+		}
+		for _, l := range [][]int{cp.lap, cp.rap} {
+			if l != nil {
+				v = binary.AppendUvarint(v, uint64(len(l)))
+				for _, r := range l {
+					v = binary.AppendUvarint(v, uint64(r))
+				}
+			}
+		}
+		var err error
+		if b, err = appendTLV(b, tlvProof, v); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Unmarshal restores a batch from its binary encoding
+func (cb *CompressedBatch) Unmarshal(b []byte) error {
+	if len(b) < 1 {
+		return errors.New("malformed encoding: too short")
+	}
+	if b[0] != binaryVersion {
+		return fmt.Errorf("unsupported version %d", b[0])
+	}
+	var out CompressedBatch
+	for b = b[1:]; len(b) > 0; {
+		if len(b) < 3 {
+			return errors.New("malformed encoding: truncated record")
+		}
+		t, n := b[0], int(binary.LittleEndian.Uint16(b[1:3]))
+		if len(b) < 3+n {
+			return errors.New("malformed encoding: truncated record")
+		}
+		v := b[3 : 3+n]
+		b = b[3+n:]
+		switch t {
+		case tlvDictionary:
+			out.dict = append(out.dict, append([]byte{}, v...))
+		case tlvProof:
+			cp, err := readCompressedProof(v, len(out.dict))
+			if err != nil {
+				return err
+			}
+			out.proofs = append(out.proofs, cp)
+		default:
+			return fmt.Errorf("unknown record type %d", t)
+		}
+	}
+	*cb = out
+	return nil
+}
+
+// readCompressedProof reads a proof record that references a dictionary with
+// n entries
+func readCompressedProof(v []byte, n int) (cp compressedProof, err error) {
+	if len(v) < 6 {
+		return cp, errors.New("malformed encoding: proof record too short")
+	}
+	for name, id := range hashIdentifiers {
+		if id == v[0] {
+			cp.hash = HashFunctions[name]
+		}
+	}
+	if cp.hash == nil {
+		return cp, fmt.Errorf("unknown hash identifier %d", v[0])
+	}
+	cp.index = int(int32(binary.LittleEndian.Uint32(v[1:5])))
+	flags := v[5]
+	if flags&^(flagLeftLeaf|flagRightLeaf|flagLeftAP|flagRightAP) != 0 {
+		return cp, fmt.Errorf("unknown flags %#x", flags)
+	}
+	v = v[6:]
+
+	next := func() (int, error) {
+		r, k := binary.Uvarint(v)
+		if k <= 0 || r >= uint64(n) {
+			return 0, errors.New("malformed encoding: bad reference")
+		}
+		v = v[k:]
+		return int(r), nil
+	}
+	nextList := func() ([]int, error) {
+		m, k := binary.Uvarint(v)
+		if k <= 0 || m > uint64(len(v)) {
+			return nil, errors.New("malformed encoding: bad audit path length")
+		}
+		v = v[k:]
+		l := make([]int, m)
+		for i := range l {
+			if l[i], err = next(); err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	}
+
+	cp.ll, cp.rl = -1, -1
+	if cp.twc, err = next(); err != nil {
+		return
+	}
+	if flags&flagLeftLeaf != 0 {
+		if cp.ll, err = next(); err != nil {
+			return
+		}
+	}
+	if flags&flagRightLeaf != 0 {
+		if cp.rl, err = next(); err != nil {
+			return
+		}
+	}
+	if flags&flagLeftAP != 0 {
+		if cp.lap, err = nextList(); err != nil {
+			return
+		}
+	}
+	if flags&flagRightAP != 0 {
+		if cp.rap, err = nextList(); err != nil {
+			return
+		}
+	}
+	if len(v) != 0 {
+		return cp, errors.New("malformed encoding: trailing data")
+	}
+	return
+}
+
+// appendTLV appends a type-length-value record
+func appendTLV(b []byte, t uint8, v []byte) ([]byte, error) {
+	if len(v) > math.MaxUint16 {
+		return nil, errors.New("record is too large")
+	}
+	b = append(b, t)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(v)))
+	return append(b, v...), nil
+}
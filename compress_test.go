@@ -0,0 +1,98 @@
+package lwm
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestCompressProofs(t *testing.T) {
+	wt, entries := compressTestData(10000, 1000)
+	snapshot := wt.Snapshot()
+	var proofs []Proof
+	size := 0
+	for _, e := range entries {
+		proofs = append(proofs, e.Proof)
+		b, err := e.Proof.Marshal()
+		if err != nil {
+			t.Fatalf("marshal proof failed: %v", err)
+		}
+		size += len(b)
+	}
+
+	cb := CompressProofs(proofs)
+	b, err := cb.Marshal()
+	if err != nil {
+		t.Fatalf("marshal batch failed: %v", err)
+	}
+	reduction := 1 - float64(len(b))/float64(size)
+	t.Logf("size reduction: %.2f", reduction)
+	if reduction < 0.4 {
+		t.Errorf("size reduction => got %.2f, want at least 0.40", reduction)
+	}
+
+	var cbp CompressedBatch
+	if err := cbp.Unmarshal(b); err != nil {
+		t.Fatalf("unmarshal batch failed: %v", err)
+	}
+	proofs, err = DecompressProofs(cbp)
+	if err != nil {
+		t.Fatalf("decompress failed: %v", err)
+	}
+	if len(proofs) != len(entries) {
+		t.Fatalf("got %d proofs, want %d", len(proofs), len(entries))
+	}
+	for i, e := range entries {
+		if err := proofs[i].Verify(e.Key, e.Answer, wt.Size(), snapshot); err != nil {
+			t.Errorf("decompressed proof rejected for key %v: %v", e.Key, err)
+		}
+	}
+
+	// empty-tree proofs carry no leaves or audit paths
+	_, p := MustNewWildcardTree(twc, hash, nil).Get("a")
+	proofs, err = DecompressProofs(CompressProofs([]Proof{p}))
+	if err != nil {
+		t.Fatalf("decompress failed for empty tree: %v", err)
+	}
+	if err := proofs[0].Verify("a", Answer{}, 0, hash(twc)); err != nil {
+		t.Errorf("decompressed proof rejected for empty tree: %v", err)
+	}
+}
+
+func BenchmarkCompressProofs(b *testing.B) {
+	_, entries := compressTestData(10000, 1000)
+	var proofs []Proof
+	for _, e := range entries {
+		proofs = append(proofs, e.Proof)
+	}
+	enc, _ := CompressProofs(proofs).Marshal()
+	b.Run("Encode", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			CompressProofs(proofs).Marshal()
+		}
+	})
+	b.Run("Decode", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var cb CompressedBatch
+			cb.Unmarshal(enc)
+			DecompressProofs(cb)
+		}
+	})
+}
+
+// compressTestData outputs a tree with n leaves, and m answers for random keys
+func compressTestData(n, m int) (*WildcardTree, []VerifyEntry) {
+	data := make(map[string]interface{})
+	for i := 0; i < n; i++ {
+		data[fmt.Sprintf("moc.%05d", i)] = [][]byte{[]byte("cert")}
+	}
+	wt := MustNewWildcardTree(twc, hash, data)
+	rand := rand.New(rand.NewSource(0))
+	var entries []VerifyEntry
+	for i := 0; i < m; i++ {
+		key := fmt.Sprintf("moc.%05d", rand.Intn(n))
+		answer, proof := wt.Get(key)
+		entries = append(entries, VerifyEntry{key, answer, proof})
+	}
+	return wt, entries
+}
@@ -0,0 +1,154 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ConsistencyProof outputs a proof that the first oldSize leaves of the tree
+// are unchanged, following RFC 6962 (§2.1.2). Such a proof only exists if the
+// tree grew by appending keys that are sorted after all previous keys.
+func (wt *WildcardTree) ConsistencyProof(oldSize int) ([][]byte, error) {
+	if oldSize < 0 || oldSize > len(wt.mt.data) {
+		return nil, fmt.Errorf("old size %d is not in [0,%d]", oldSize,
+			len(wt.mt.data))
+	}
+	return wt.mt.ConsistencyAp(oldSize), nil
+}
+
+// VerifyConsistency outputs true if proof shows that a tree of size newSize
+// with root newSnapshot is an append-only extension of a tree of size oldSize
+// with root oldSnapshot. Both trees must use the tree-wide constant twc and
+// the hash function h.
+func VerifyConsistency(oldSnapshot, newSnapshot []byte, oldSize, newSize int,
+	proof [][]byte, twc []byte, h func(data ...[]byte) []byte) bool {
+	if oldSize == 0 { // anything is consistent with the empty tree
+		return newSize >= 0 && len(proof) == 0 &&
+			bytes.Equal(oldSnapshot, h(twc))
+	}
+	mt := NewMerkleTree(twc, leafPrefix, interiorPrefix, h, nil)
+	root, err := mt.MthFromConsistencyAp(oldSnapshot, oldSize, newSize, proof)
+	return err == nil && bytes.Equal(root, newSnapshot)
+}
+
+// ConsistencyAp computes a consistency proof between the first oldSize leaves
+// and all leaves, see RFC 6962 (§2.1.2). The proof is empty if oldSize is zero,
+// the tree size, or out of bounds.
+func (mt *MerkleTree) ConsistencyAp(oldSize int) [][]byte {
+	if oldSize <= 0 || oldSize >= len(mt.data) {
+		return nil
+	}
+	mt.Mth() // cp relies on a populated cache
+	return mt.cp(oldSize, mt.data, mt.cache, true)
+}
+
+// MthFromConsistencyAp recomputes the root hash of a tree of size newSize from
+// the root hash of its first oldSize leaves and a consistency proof. An error
+// is returned if the proof is malformed or inconsistent with oldRoot.
+func (mt *MerkleTree) MthFromConsistencyAp(oldRoot []byte, oldSize,
+	newSize int, proof [][]byte) ([]byte, error) {
+	if oldSize <= 0 || oldSize > newSize {
+		return nil, fmt.Errorf("malformed proof: bad sizes %d->%d", oldSize,
+			newSize)
+	}
+	if oldSize == newSize {
+		if len(proof) != 0 {
+			return nil, errors.New("malformed proof: expected no hashes")
+		}
+		return oldRoot, nil
+	}
+
+	// see RFC 9162 (§2.1.4.2), which describes the same verification algorithm
+	if oldSize&(oldSize-1) == 0 { // old tree is a complete subtree
+		proof = append([][]byte{oldRoot}, proof...)
+	}
+	if len(proof) == 0 {
+		return nil, errors.New("malformed proof: no hashes")
+	}
+	fn, sn := oldSize-1, newSize-1
+	for fn&1 == 1 {
+		fn, sn = fn>>1, sn>>1
+	}
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return nil, errors.New("malformed proof: too many hashes")
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = mt.hash(mt.interiorPrefix, c, fr)
+			sr = mt.hash(mt.interiorPrefix, c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn, sn = fn>>1, sn>>1
+			}
+		} else {
+			sr = mt.hash(mt.interiorPrefix, sr, c)
+		}
+		fn, sn = fn>>1, sn>>1
+	}
+	if sn != 0 {
+		return nil, errors.New("malformed proof: too few hashes")
+	}
+	if !bytes.Equal(fr, oldRoot) {
+		return nil, errors.New("proof is inconsistent with the old root")
+	}
+	return sr, nil
+}
+
+// ChainError is returned by VerifyChain if a link in the chain is invalid
+type ChainError struct {
+	Index int // the link from snapshot Index to snapshot Index+1
+	err   error
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("link %d: %v", e.Index, e.err)
+}
+
+// Unwrap outputs the reason that the link is invalid
+func (e *ChainError) Unwrap() error {
+	return e.err
+}
+
+// VerifyChain outputs nil if every snapshot is consistent with the previous
+// one, where proofs[i] is a consistency proof from snapshot i to i+1. The
+// first snapshot is trusted as genesis, and if its size is zero it must be
+// the empty tree hash. Sizes must be strictly increasing. An error for the
+// first invalid link is returned as a *ChainError.
+func VerifyChain(snapshots [][]byte, sizes []int, proofs [][][]byte,
+	twc []byte, h func(data ...[]byte) []byte) error {
+	if len(snapshots) == 0 {
+		return errors.New("chain is empty")
+	}
+	if len(sizes) != len(snapshots) || len(proofs) != len(snapshots)-1 {
+		return fmt.Errorf("got %d snapshots, %d sizes, and %d proofs",
+			len(snapshots), len(sizes), len(proofs))
+	}
+	if sizes[0] < 0 || (sizes[0] == 0 && !bytes.Equal(snapshots[0], h(twc))) {
+		return errors.New("bad genesis snapshot")
+	}
+
+	mt := NewMerkleTree(twc, leafPrefix, interiorPrefix, h, nil)
+	for i, proof := range proofs {
+		oldSize, newSize := sizes[i], sizes[i+1]
+		if newSize <= oldSize {
+			return &ChainError{i, fmt.Errorf("size %d does not increase to %d",
+				oldSize, newSize)}
+		}
+		if oldSize == 0 { // anything is consistent with the empty tree
+			if len(proof) != 0 {
+				return &ChainError{i, errors.New("expected no hashes")}
+			}
+			continue
+		}
+		root, err := mt.MthFromConsistencyAp(snapshots[i], oldSize, newSize,
+			proof)
+		if err != nil {
+			return &ChainError{i, err}
+		}
+		if !bytes.Equal(root, snapshots[i+1]) {
+			return &ChainError{i, errors.New("new snapshot does not match")}
+		}
+	}
+	return nil
+}
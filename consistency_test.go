@@ -0,0 +1,220 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestConsistencyProofGolden(t *testing.T) {
+	wt := consistencyTree(7)
+	d := wt.mt.data
+	mth := func(data [][]byte) []byte {
+		return NewMerkleTree(twc, leafPrefix, interiorPrefix, hash, data).Mth()
+	}
+
+	// the example tree in RFC 6962 (§2.1.3)
+	for _, table := range []struct {
+		oldSize int
+		proof   [][]byte
+	}{
+		{0, nil},
+		{1, [][]byte{mth(d[1:2]), mth(d[2:4]), mth(d[4:7])}},
+		{3, [][]byte{mth(d[2:3]), mth(d[3:4]), mth(d[0:2]), mth(d[4:7])}},
+		{4, [][]byte{mth(d[4:7])}},
+		{6, [][]byte{mth(d[4:6]), mth(d[6:7]), mth(d[0:4])}},
+		{7, nil},
+	} {
+		proof, err := wt.ConsistencyProof(table.oldSize)
+		if err != nil {
+			t.Errorf("old size %d => %v", table.oldSize, err)
+			continue
+		}
+		if len(proof) != len(table.proof) {
+			t.Errorf("old size %d => got %d hashes, want %d", table.oldSize,
+				len(proof), len(table.proof))
+			continue
+		}
+		for i := range proof {
+			if !bytes.Equal(proof[i], table.proof[i]) {
+				t.Errorf("old size %d => bad hash at position %d", table.oldSize, i)
+			}
+		}
+	}
+
+	if _, err := wt.ConsistencyProof(8); err == nil {
+		t.Errorf("accepted old size larger than tree")
+	}
+}
+
+func TestVerifyConsistency(t *testing.T) {
+	for newSize := 0; newSize <= 32; newSize++ {
+		wt := consistencyTree(newSize)
+		newSnapshot := wt.Snapshot()
+		for oldSize := 0; oldSize <= newSize; oldSize++ {
+			oldSnapshot := consistencyTree(oldSize).Snapshot()
+			proof, err := wt.ConsistencyProof(oldSize)
+			if err != nil {
+				t.Errorf("%d->%d => %v", oldSize, newSize, err)
+				continue
+			}
+			if !VerifyConsistency(oldSnapshot, newSnapshot, oldSize, newSize,
+				proof, twc, hash) {
+				t.Errorf("%d->%d => valid proof rejected", oldSize, newSize)
+			}
+			if oldSize > 0 && VerifyConsistency(hash([]byte("bad")), newSnapshot,
+				oldSize, newSize, proof, twc, hash) {
+				t.Errorf("%d->%d => bad old snapshot accepted", oldSize, newSize)
+			}
+			if 0 < oldSize && oldSize < newSize && VerifyConsistency(oldSnapshot,
+				hash([]byte("bad")), oldSize, newSize, proof, twc, hash) {
+				t.Errorf("%d->%d => bad new snapshot accepted", oldSize, newSize)
+			}
+			if len(proof) > 0 && VerifyConsistency(oldSnapshot, newSnapshot,
+				oldSize, newSize, proof[1:], twc, hash) {
+				t.Errorf("%d->%d => truncated proof accepted", oldSize, newSize)
+			}
+		}
+	}
+}
+
+func TestMthFromConsistencyApVectors(t *testing.T) {
+	// RFC 6962 hashing is equivalent to an empty tree-wide constant, and these
+	// are the test vectors of the Certificate Transparency reference code
+	var data [][]byte
+	for _, leaf := range []string{"", "00", "10", "2021", "3031", "40414243",
+		"5051525354555657", "606162636465666768696a6b6c6d6e6f"} {
+		data = append(data, decode(leaf))
+	}
+	roots := []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+	for i, root := range roots {
+		mt := NewMerkleTree(nil, leafPrefix, interiorPrefix, hash, data[:i+1])
+		if got := mt.Mth(); !bytes.Equal(got, decode(root)) {
+			t.Fatalf("size %d => got root %x, want %s", i+1, got, root)
+		}
+	}
+
+	mt := NewMerkleTree(nil, leafPrefix, interiorPrefix, hash, data)
+	for _, table := range []struct {
+		oldSize, newSize int
+		proof            []string
+	}{
+		{1, 1, nil},
+		{1, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{6, 8, []string{
+			"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 5, []string{
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	} {
+		var proof [][]byte
+		for _, h := range table.proof {
+			proof = append(proof, decode(h))
+		}
+		sub := NewMerkleTree(nil, leafPrefix, interiorPrefix, hash,
+			mt.data[:table.newSize])
+		if got := sub.ConsistencyAp(table.oldSize); fmt.Sprint(got) !=
+			fmt.Sprint(proof) {
+			t.Errorf("%d->%d => bad proof %x", table.oldSize, table.newSize, got)
+		}
+		oldRoot := decode(roots[table.oldSize-1])
+		root, err := mt.MthFromConsistencyAp(oldRoot, table.oldSize,
+			table.newSize, proof)
+		if err != nil {
+			t.Errorf("%d->%d => %v", table.oldSize, table.newSize, err)
+		} else if want := decode(roots[table.newSize-1]); !bytes.Equal(root, want) {
+			t.Errorf("%d->%d => got root %x, want %x", table.oldSize,
+				table.newSize, root, want)
+		}
+		root, err = mt.MthFromConsistencyAp(hash([]byte("bad")), table.oldSize,
+			table.newSize, proof)
+		if err == nil && bytes.Equal(root, decode(roots[table.newSize-1])) {
+			t.Errorf("%d->%d => bad old root accepted", table.oldSize,
+				table.newSize)
+		}
+	}
+}
+
+// consistencyTree outputs a tree with n keys that are sorted in insertion order
+func consistencyTree(n int) *WildcardTree {
+	m := make(map[string]interface{})
+	for i := 0; i < n; i++ {
+		k := fmt.Sprintf("key%03d", i)
+		m[k] = [][]byte{[]byte(k + " cert")}
+	}
+	return MustNewWildcardTree(twc, hash, m)
+}
+
+func TestVerifyChain(t *testing.T) {
+	sizes := []int{0, 3, 4, 9, 16}
+	tip := consistencyTree(sizes[len(sizes)-1])
+	var snapshots [][]byte
+	var proofs [][][]byte
+	for i, size := range sizes {
+		snapshots = append(snapshots, consistencyTree(size).Snapshot())
+		if i > 0 {
+			proof, err := consistencyTree(size).ConsistencyProof(sizes[i-1])
+			if err != nil {
+				t.Fatalf("size %d => %v", size, err)
+			}
+			proofs = append(proofs, proof)
+		}
+	}
+	if !bytes.Equal(snapshots[len(snapshots)-1], tip.Snapshot()) {
+		t.Fatalf("bad chain head")
+	}
+	if err := VerifyChain(snapshots, sizes, proofs, twc, hash); err != nil {
+		t.Errorf("valid chain rejected: %v", err)
+	}
+	if err := VerifyChain(snapshots[2:], sizes[2:], proofs[2:], twc,
+		hash); err != nil {
+		t.Errorf("valid chain without empty genesis rejected: %v", err)
+	}
+
+	// a tampered intermediate snapshot breaks the link into it
+	tampered := append([][]byte{}, snapshots...)
+	tampered[2] = hash([]byte("bad"))
+	err := VerifyChain(tampered, sizes, proofs, twc, hash)
+	var cerr *ChainError
+	if !errors.As(err, &cerr) || cerr.Index != 1 {
+		t.Errorf("tampered snapshot => got error %v, want link 1", err)
+	}
+
+	for _, table := range []struct {
+		desc      string
+		snapshots [][]byte
+		sizes     []int
+		proofs    [][][]byte
+	}{
+		{"empty chain", nil, nil, nil},
+		{"missing proof", snapshots, sizes, proofs[1:]},
+		{"bad genesis", append([][]byte{hash([]byte("bad"))}, snapshots[1:]...),
+			sizes, proofs},
+		{"size does not increase", snapshots[1:3], []int{3, 3}, proofs[1:2]},
+		{"bad proof", snapshots, sizes, append(append([][][]byte{}, proofs[:3]...),
+			proofs[3][1:])},
+	} {
+		if err := VerifyChain(table.snapshots, table.sizes, table.proofs, twc,
+			hash); err == nil {
+			t.Errorf("%s => accepted", table.desc)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package lwm
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CSV tree format (RFC 4180), one row per key:
+//
+//	domain,payload1,payload2,...
+//
+// The domain is in regular order, e.g., "sub.example.com", and is reversed to
+// form the key. Each payload item is standard base64 with padding, and a row
+// must have at least one payload item. There is no header row. For example:
+//
+//	foo.com,Zm9vLmNvbSBjZXJ0MQ==,Zm9vLmNvbSBjZXJ0Mg==
+//	sub.bar.edu,c3ViLmJhci5lZHUgY2VydA==
+
+// NewWildcardTreeFromCSV creates a new wildcard tree from the rows of a CSV
+// file, see the CSV tree format above. Malformed rows and duplicate domains
+// result in an error that includes the line number.
+func NewWildcardTreeFromCSV(r io.Reader, twc []byte,
+	h func(data ...[]byte) []byte) (*WildcardTree, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // payload counts vary, checked below
+	m := make(map[string]interface{})
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err // includes the line number
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: got %d columns, want at least 2",
+				line, len(record))
+		}
+		key := reverse(record[0])
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate domain %q", line, record[0])
+		}
+		payload := make([][]byte, 0, len(record)-1)
+		for i, field := range record[1:] {
+			item, err := base64.StdEncoding.DecodeString(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: column %d: %v", line, i+2, err)
+			}
+			payload = append(payload, item)
+		}
+		m[key] = payload
+	}
+	return NewWildcardTree(twc, h, m)
+}
+
+// WriteCSV writes the tree's key-value pairs in radix order, see the CSV tree
+// format above. Keys without payload and blinded trees cannot be written.
+func (wt *WildcardTree) WriteCSV(w io.Writer) (err error) {
+	if wt.blinded {
+		return errors.New("blinded payloads cannot be written")
+	}
+	cw := csv.NewWriter(w)
+	wt.ForEach(func(key string, payload [][]byte) bool {
+		if len(payload) == 0 {
+			err = fmt.Errorf("key %q has no payload", key)
+			return false
+		}
+		record := []string{reverse(key)}
+		for _, item := range payload {
+			record = append(record, base64.StdEncoding.EncodeToString(item))
+		}
+		err = cw.Write(record)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
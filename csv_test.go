@@ -0,0 +1,79 @@
+package lwm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/golang/example/stringutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	m := testData()
+	var rows []string
+	for key, payload := range m {
+		row := []string{stringutil.Reverse(key)}
+		for _, item := range payload.([][]byte) {
+			row = append(row, base64.StdEncoding.EncodeToString(item))
+		}
+		rows = append(rows, strings.Join(row, ","))
+	}
+	sort.Strings(rows)
+
+	wt, err := NewWildcardTreeFromCSV(strings.NewReader(strings.Join(rows, "\n")),
+		twc, hash)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	want := MustNewWildcardTree(twc, hash, m)
+	if !bytes.Equal(wt.Snapshot(), want.Snapshot()) {
+		t.Errorf("read => got snapshot %x, want %x", wt.Snapshot(),
+			want.Snapshot())
+	}
+	if got := wt.toMap(); !reflect.DeepEqual(got, m) {
+		t.Errorf("read => got %v, want %v", got, m)
+	}
+
+	var buf bytes.Buffer
+	if err := wt.WriteCSV(&buf); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	wtp, err := NewWildcardTreeFromCSV(&buf, twc, hash)
+	if err != nil {
+		t.Fatalf("read after write failed: %v", err)
+	}
+	if !bytes.Equal(wtp.Snapshot(), want.Snapshot()) {
+		t.Errorf("round-trip => got snapshot %x, want %x", wtp.Snapshot(),
+			want.Snapshot())
+	}
+
+	// keys without payload cannot be written
+	empty := MustNewWildcardTree(twc, hash, map[string]interface{}{
+		"moc.oof": [][]byte{},
+	})
+	if err := empty.WriteCSV(&buf); err == nil {
+		t.Errorf("wrote key without payload")
+	}
+}
+
+func TestCSVErrors(t *testing.T) {
+	for _, table := range []struct {
+		desc string
+		csv  string
+		line string
+	}{
+		{"missing payload", "foo.com,YQ==\nbar.com\n", "line 2"},
+		{"bad base64", "foo.com,YQ==\n\nbar.com,YQ==,!!!\n", "line 3"},
+		{"duplicate domain", "foo.com,YQ==\nfoo.com,Yg==\n", "line 2"},
+		{"bad quoting", "foo.com,YQ==\nbar.com,\"YQ==\n", "line 2"},
+	} {
+		_, err := NewWildcardTreeFromCSV(strings.NewReader(table.csv), twc, hash)
+		if err == nil {
+			t.Errorf("%s => accepted", table.desc)
+		} else if !strings.Contains(err.Error(), table.line) {
+			t.Errorf("%s => got error %q, want %s", table.desc, err, table.line)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package lwm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Debug prints the snapshot, every key in radix order with its Merkle tree
+// index and payload hash, and the Merkle tree structure. The output format is
+// intended for debugging and may change at any time.
+func (wt *WildcardTree) Debug(w io.Writer) {
+	fmt.Fprintf(w, "snapshot: %x\n", wt.Snapshot())
+	fmt.Fprintf(w, "size: %d\n", wt.Size())
+	fmt.Fprintf(w, "keys:\n")
+	wt.r.WalkPrefix("", func(key string, v interface{}) bool {
+		rv := v.(radixValue)
+		data := wt.mt.data[rv.index]
+		fmt.Fprintf(w, "  %d %q %x\n", rv.index, key, wt.payloadHash(data))
+		return false
+	})
+	fmt.Fprintf(w, "merkle tree:\n")
+	wt.mt.DebugTree(w)
+}
+
+// DebugTree prints the binary tree structure using cached hashes, with one
+// node per line and the leaf range [i,j) that each node covers. The output
+// format is intended for debugging and may change at any time.
+func (mt *MerkleTree) DebugTree(w io.Writer) {
+	mt.Mth() // debugTree relies on a populated cache
+	if len(mt.data) == 0 {
+		fmt.Fprintf(w, "empty %x\n", mt.cache.this)
+		return
+	}
+	mt.debugTree(w, mt.data, mt.cache, 0, "", "")
+}
+
+// debugTree prints the subtree c for data, where offset is the index of
+// data[0], and prefix and indent are printed before the node and its children
+func (mt *MerkleTree) debugTree(w io.Writer, data [][]byte, c *hashCache,
+	offset int, prefix, indent string) {
+	if len(data) == 1 {
+		fmt.Fprintf(w, "%sleaf [%d] %x data=%s\n", prefix, offset, c.this,
+			hex.EncodeToString(data[0]))
+		return
+	}
+	fmt.Fprintf(w, "%snode [%d,%d) %x\n", prefix, offset, offset+len(data),
+		c.this)
+	k := lpow2s(len(data))
+	mt.debugTree(w, data[:k], c.left, offset, indent+"├── ", indent+"│   ")
+	mt.debugTree(w, data[k:], c.right, offset+k, indent+"└── ", indent+"    ")
+}
@@ -0,0 +1,36 @@
+package lwm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDebug(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	buf := new(bytes.Buffer)
+	wt.Debug(buf)
+	out := buf.String()
+	if out == "" {
+		t.Fatalf("no output")
+	}
+	if want := fmt.Sprintf("snapshot: %x\n", wt.Snapshot()); !strings.HasPrefix(out, want) {
+		t.Errorf("output does not start with %q", want)
+	}
+	if want := fmt.Sprintf("size: %d\n", wt.Size()); !strings.Contains(out, want) {
+		t.Errorf("output does not contain %q", want)
+	}
+	if got, want := strings.Count(out, "leaf ["), wt.Size(); got != want {
+		t.Errorf("got %d leaves, want %d", got, want)
+	}
+	if got, want := strings.Count(out, "node ["), wt.Size()-1; got != want {
+		t.Errorf("got %d interior nodes, want %d", got, want)
+	}
+
+	buf.Reset()
+	MustNewWildcardTree(twc, hash, nil).Debug(buf)
+	if !strings.Contains(buf.String(), "empty") {
+		t.Errorf("empty tree output does not contain %q", "empty")
+	}
+}
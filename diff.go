@@ -0,0 +1,105 @@
+package lwm
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// TreeDiff describes the changes from one tree to another. Added and Updated
+// contain the new payloads, and Removed the old ones. Entries are in radix
+// order.
+type TreeDiff struct {
+	Added   []Entry
+	Removed []Entry
+	Updated []Entry
+}
+
+// Diff outputs the changes from oldTree to newTree, where a key is updated if
+// its payload hash changed. The trees should use the same hash function.
+func Diff(oldTree, newTree *WildcardTree) TreeDiff {
+	var d TreeDiff
+	od, nd := oldTree.mt.data, newTree.mt.data
+	for len(od) > 0 || len(nd) > 0 {
+		var ok, nk string
+		if len(od) > 0 {
+			ok = oldTree.leafKey(od[0])
+		}
+		if len(nd) > 0 {
+			nk = newTree.leafKey(nd[0])
+		}
+		switch {
+		case len(nd) == 0 || (len(od) > 0 && ok < nk):
+			d.Removed = append(d.Removed, oldTree.entry(ok))
+			od = od[1:]
+		case len(od) == 0 || nk < ok:
+			d.Added = append(d.Added, newTree.entry(nk))
+			nd = nd[1:]
+		default:
+			if !bytes.Equal(oldTree.payloadHash(od[0]),
+				newTree.payloadHash(nd[0])) {
+				d.Updated = append(d.Updated, newTree.entry(nk))
+			}
+			od, nd = od[1:], nd[1:]
+		}
+	}
+	return d
+}
+
+// entry outputs the entry of a key that is in the tree
+func (wt *WildcardTree) entry(key string) Entry {
+	v, _ := wt.r.Get(key)
+	return Entry{Key: key, Payload: v.(radixValue).payload}
+}
+
+// entryJSON is the JSON representation of an Entry
+type entryJSON struct {
+	Key     string      `json:"key"`
+	Payload []base64URL `json:"payload"`
+}
+
+// treeDiffJSON is the JSON representation of a TreeDiff
+type treeDiffJSON struct {
+	Added   []entryJSON `json:"added"`
+	Removed []entryJSON `json:"removed"`
+	Updated []entryJSON `json:"updated"`
+}
+
+// MarshalJSON outputs a JSON encoding of the diff. Each payload is encoded as
+// a list of unpadded base64url strings.
+func (d TreeDiff) MarshalJSON() ([]byte, error) {
+	return json.Marshal(treeDiffJSON{
+		Added:   toEntryJSONs(d.Added),
+		Removed: toEntryJSONs(d.Removed),
+		Updated: toEntryJSONs(d.Updated),
+	})
+}
+
+// UnmarshalJSON restores a diff from its JSON encoding
+func (d *TreeDiff) UnmarshalJSON(b []byte) error {
+	var dj treeDiffJSON
+	if err := json.Unmarshal(b, &dj); err != nil {
+		return err
+	}
+	*d = TreeDiff{
+		Added:   fromEntryJSONs(dj.Added),
+		Removed: fromEntryJSONs(dj.Removed),
+		Updated: fromEntryJSONs(dj.Updated),
+	}
+	return nil
+}
+
+func toEntryJSONs(entries []Entry) []entryJSON {
+	out := make([]entryJSON, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, entryJSON{e.Key, toBase64URLs(e.Payload)})
+	}
+	return out
+}
+
+func fromEntryJSONs(entries []entryJSON) []Entry {
+	var out []Entry
+	for _, e := range entries {
+		out = append(out, Entry{e.Key, fromBase64URLs(e.Payload)})
+	}
+	return out
+}
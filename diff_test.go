@@ -0,0 +1,114 @@
+package lwm
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/golang/example/stringutil"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	oldTree := MustNewWildcardTree(twc, hash, testData())
+	newTree := MustNewWildcardTree(twc, hash, testData())
+	added := map[string][][]byte{
+		stringutil.Reverse("aaa.com"): {[]byte("aaa.com cert")},
+		stringutil.Reverse("zzz.se"):  {[]byte("zzz.se cert")},
+	}
+	for key, payload := range added {
+		if err := newTree.Add(key, payload); err != nil {
+			t.Fatalf("add failed: %v", err)
+		}
+	}
+	removed := stringutil.Reverse("sub.bar.edu")
+	if err := newTree.Remove(removed); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	updated := stringutil.Reverse("sub1.foo.com")
+	if err := newTree.Update(updated, [][]byte{[]byte("new cert")}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	d := Diff(oldTree, newTree)
+	if len(d.Added) != len(added) {
+		t.Errorf("got %d added entries, want %d", len(d.Added), len(added))
+	}
+	for _, e := range d.Added {
+		if !reflect.DeepEqual(e.Payload, added[e.Key]) {
+			t.Errorf("added %q => got payload %q", e.Key, e.Payload)
+		}
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Key != removed ||
+		!reflect.DeepEqual(d.Removed[0].Payload, testData()[removed]) {
+		t.Errorf("got removed entries %v", d.Removed)
+	}
+	if len(d.Updated) != 1 || d.Updated[0].Key != updated ||
+		string(d.Updated[0].Payload[0]) != "new cert" {
+		t.Errorf("got updated entries %v", d.Updated)
+	}
+	if d := Diff(oldTree, oldTree); d.Added != nil || d.Removed != nil ||
+		d.Updated != nil {
+		t.Errorf("got changes between equal trees: %v", d)
+	}
+
+	// applying the diff to the old tree yields the new tree
+	applied := MustNewWildcardTree(twc, hash, testData())
+	for _, e := range d.Added {
+		if err := applied.Add(e.Key, e.Payload); err != nil {
+			t.Fatalf("apply add failed: %v", err)
+		}
+	}
+	for _, e := range d.Removed {
+		if err := applied.Remove(e.Key); err != nil {
+			t.Fatalf("apply remove failed: %v", err)
+		}
+	}
+	for _, e := range d.Updated {
+		if err := applied.Update(e.Key, e.Payload); err != nil {
+			t.Fatalf("apply update failed: %v", err)
+		}
+	}
+	if !bytes.Equal(applied.Snapshot(), newTree.Snapshot()) {
+		t.Errorf("applied diff => got snapshot %x, want %x", applied.Snapshot(),
+			newTree.Snapshot())
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	var dp TreeDiff
+	if err := json.Unmarshal(b, &dp); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(dp, d) {
+		t.Errorf("JSON round-trip => got %v, want %v", dp, d)
+	}
+}
+
+func TestDiffAddedAndRemoved(t *testing.T) {
+	m := testData()
+	delete(m, stringutil.Reverse("baz.gov"))
+	m[stringutil.Reverse("new.org")] = [][]byte{[]byte("new.org cert")}
+	oldTree := MustNewWildcardTree(twc, hash, testData())
+	newTree := MustNewWildcardTree(twc, hash, m)
+
+	d := Diff(oldTree, newTree)
+	if len(d.Updated) != 0 {
+		t.Fatalf("got updated entries %v", d.Updated)
+	}
+	for _, e := range d.Added {
+		if err := oldTree.Add(e.Key, e.Payload); err != nil {
+			t.Fatalf("add failed: %v", err)
+		}
+	}
+	for _, e := range d.Removed {
+		if err := oldTree.Remove(e.Key); err != nil {
+			t.Fatalf("remove failed: %v", err)
+		}
+	}
+	if !bytes.Equal(oldTree.Snapshot(), newTree.Snapshot()) {
+		t.Errorf("got snapshot %x, want %x", oldTree.Snapshot(),
+			newTree.Snapshot())
+	}
+}
@@ -0,0 +1,100 @@
+package lwm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// dotHashLen is the number of hash bytes that label a node in DOT output
+const dotHashLen = 8
+
+// ToDot prints the Merkle tree in Graphviz DOT format. Nodes are labeled by
+// the first bytes of their hashes, with interior nodes as ellipses and leaves
+// as rectangles. Highlighted leaves are green, and the nodes that their audit
+// paths consist of are yellow. The output format is intended for debugging and
+// may change at any time.
+func (mt *MerkleTree) ToDot(w io.Writer, highlightLeaves []int) {
+	mt.dot(w, "", highlightLeaves)
+}
+
+// AuditPathDot prints the Merkle tree in Graphviz DOT format, see ToDot, with
+// the leaves that the proof covers highlighted. These are the leaves of the
+// matches in a and the left and right leaves of the proof (if any). The tree
+// mt is the one that the proof was generated from, and key is the graph label.
+func (p Proof) AuditPathDot(w io.Writer, mt *MerkleTree, key string, a Answer) {
+	var leaves []int
+	if lindex, rindex := indices(&p, &a); lindex >= 0 {
+		for i := lindex; i <= rindex; i++ {
+			leaves = append(leaves, i)
+		}
+	}
+	mt.dot(w, key, leaves)
+}
+
+// dot prints the Merkle tree in DOT format with an optional graph label
+func (mt *MerkleTree) dot(w io.Writer, label string, highlightLeaves []int) {
+	mt.Mth() // toDot relies on a populated cache
+	highlight := append([]int{}, highlightLeaves...)
+	sort.Ints(highlight)
+
+	fmt.Fprintf(w, "digraph merkle {\n")
+	if label != "" {
+		fmt.Fprintf(w, "  label=%q;\n", label)
+	}
+	if len(mt.data) == 0 {
+		fmt.Fprintf(w, "  empty [label=\"%s\", shape=ellipse];\n",
+			dotLabel(mt.cache.this))
+	} else {
+		mt.toDot(w, mt.data, mt.cache, 0, highlight, false)
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
+// toDot prints the subtree c for data, where offset is the index of data[0]
+// and sibling is true if the subtree's sibling covers a highlighted leaf
+func (mt *MerkleTree) toDot(w io.Writer, data [][]byte, c *hashCache,
+	offset int, highlight []int, sibling bool) {
+	id := dotID(offset, offset+len(data))
+	shape, color := "ellipse", ""
+	if len(data) == 1 {
+		shape = "box"
+	}
+	switch {
+	case dotCovers(highlight, offset, offset+len(data)):
+		if len(data) == 1 {
+			color = ", style=filled, fillcolor=green"
+		}
+	case sibling:
+		color = ", style=filled, fillcolor=yellow"
+	}
+	fmt.Fprintf(w, "  %s [label=\"%s\", shape=%s%s];\n", id, dotLabel(c.this),
+		shape, color)
+	if len(data) == 1 {
+		return
+	}
+
+	k := lpow2s(len(data))
+	left := dotCovers(highlight, offset, offset+k)
+	right := dotCovers(highlight, offset+k, offset+len(data))
+	fmt.Fprintf(w, "  %s -> %s;\n", id, dotID(offset, offset+k))
+	fmt.Fprintf(w, "  %s -> %s;\n", id, dotID(offset+k, offset+len(data)))
+	mt.toDot(w, data[:k], c.left, offset, highlight, right)
+	mt.toDot(w, data[k:], c.right, offset+k, highlight, left)
+}
+
+// dotID outputs the DOT identifier of the node that covers leaves [i,j)
+func dotID(i, j int) string {
+	return fmt.Sprintf("n%d_%d", i, j)
+}
+
+// dotLabel outputs the DOT label of a node with hash h
+func dotLabel(h []byte) string {
+	return fmt.Sprintf("%x", h[:min(len(h), dotHashLen)])
+}
+
+// dotCovers outputs true if a sorted list of leaf indices has one in [i,j)
+func dotCovers(highlight []int, i, j int) bool {
+	k := sort.SearchInts(highlight, i)
+	return k < len(highlight) && highlight[k] < j
+}
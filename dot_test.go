@@ -0,0 +1,78 @@
+package lwm
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/golang/example/stringutil"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestToDot(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	n := wt.Size()
+	buf := new(bytes.Buffer)
+	wt.mt.ToDot(buf, []int{2})
+	out := buf.String()
+	if !strings.HasPrefix(out, "digraph merkle {\n") || !strings.HasSuffix(out, "}\n") {
+		t.Fatalf("output is not a digraph:\n%s", out)
+	}
+
+	root := regexp.MustCompile(fmt.Sprintf(`n0_%d \[label="%x", shape=ellipse\];`,
+		n, wt.Snapshot()[:dotHashLen]))
+	if !root.MatchString(out) {
+		t.Errorf("missing root node %s", root)
+	}
+	for i, data := range wt.mt.data {
+		h := hash(twc, leafPrefix, data)
+		leaf := regexp.MustCompile(fmt.Sprintf(`n%d_%d \[label="%x", shape=box.*\];`,
+			i, i+1, h[:dotHashLen]))
+		if !leaf.MatchString(out) {
+			t.Errorf("missing leaf node %s", leaf)
+		}
+	}
+	edges := regexp.MustCompile(`(?m)^  n\d+_\d+ -> n\d+_\d+;$`)
+	if got, want := len(edges.FindAllString(out, -1)), 2*(n-1); got != want {
+		t.Errorf("got %d edges, want %d", got, want)
+	}
+	if got := strings.Count(out, "fillcolor=green"); got != 1 {
+		t.Errorf("got %d green nodes, want 1", got)
+	}
+	if got, want := strings.Count(out, "fillcolor=yellow"), len(wt.mt.Ap(2)); got != want {
+		t.Errorf("got %d yellow nodes, want %d", got, want)
+	}
+
+	buf.Reset()
+	MustNewWildcardTree(twc, hash, nil).mt.ToDot(buf, nil)
+	if !strings.Contains(buf.String(), "empty [label=") {
+		t.Errorf("empty tree output does not contain an empty node")
+	}
+}
+
+func TestAuditPathDot(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	key := stringutil.Reverse("foo.com")
+	answer, proof := wt.Get(key)
+	buf := new(bytes.Buffer)
+	proof.AuditPathDot(buf, wt.mt, key, answer)
+	out := buf.String()
+
+	if !regexp.MustCompile(`label="moc\.oof";`).MatchString(out) {
+		t.Errorf("missing graph label")
+	}
+	lindex, rindex := indices(&proof, &answer)
+	if got, want := strings.Count(out, "fillcolor=green"), rindex-lindex+1; got != want {
+		t.Errorf("got %d green nodes, want %d", got, want)
+	}
+	for i := lindex; i <= rindex; i++ {
+		leaf := regexp.MustCompile(fmt.Sprintf(`n%d_%d \[label="[0-9a-f]{%d}", `+
+			`shape=box, style=filled, fillcolor=green\];`, i, i+1, 2*dotHashLen))
+		if !leaf.MatchString(out) {
+			t.Errorf("leaf %d is not highlighted", i)
+		}
+	}
+	if !strings.Contains(out, "fillcolor=yellow") {
+		t.Errorf("no audit path nodes are highlighted")
+	}
+}
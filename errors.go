@@ -0,0 +1,88 @@
+package lwm
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrKeyExists is returned when adding a key that is already in a tree
+	ErrKeyExists = errors.New("key already exists")
+	// ErrKeyNotFound is returned when a key is expected to be in a tree
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrLimitExceeded is wrapped by errors for keys and payloads that exceed
+	// a tree's limits, see WithMaxKeyLength
+	ErrLimitExceeded = errors.New("limit exceeded")
+	// ErrSnapshotNotFound is returned when a snapshot is not in a tree's
+	// history, see HistoricalWildcardTree
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+)
+
+// VerificationErrorKind is a machine-readable reason for rejecting a proof.
+// Kinds are named Kind* rather than Err*, since Err* names are reserved for
+// sentinel errors that can be matched with errors.Is.
+type VerificationErrorKind int
+
+const (
+	// KindMalformedData indicates that the answer or proof is malformed, e.g.,
+	// because of a length mismatch or audit paths that do not fit the tree size
+	KindMalformedData VerificationErrorKind = iota
+	// KindMissingLeftBound indicates that a left leaf is required but absent
+	KindMissingLeftBound
+	// KindMissingRightBound indicates that a right leaf is required but absent
+	KindMissingRightBound
+	// KindLeafOrder indicates that leaves are not ordered with respect to each
+	// other or the queried key
+	KindLeafOrder
+	// KindRootMismatch indicates that the recomputed root hash is not snapshot
+	KindRootMismatch
+	// KindInvalidPayload indicates that a payload validator rejected a payload,
+	// see WithPayloadValidator
+	KindInvalidPayload
+	// KindTreeSizeMismatch indicates that the proven range of leaves does not
+	// fit in a tree of the given size
+	KindTreeSizeMismatch
+)
+
+// String outputs a human-readable name for the kind
+func (k VerificationErrorKind) String() string {
+	switch k {
+	case KindMalformedData:
+		return "malformed data"
+	case KindMissingLeftBound:
+		return "missing left bound"
+	case KindMissingRightBound:
+		return "missing right bound"
+	case KindLeafOrder:
+		return "leaf order"
+	case KindRootMismatch:
+		return "root mismatch"
+	case KindInvalidPayload:
+		return "invalid payload"
+	case KindTreeSizeMismatch:
+		return "tree size mismatch"
+	}
+	return fmt.Sprintf("unknown kind %d", int(k))
+}
+
+// VerificationError is returned by Verify if an answer is rejected
+type VerificationError struct {
+	Kind   VerificationErrorKind
+	Detail string // e.g., the offending keys or the mismatching hashes
+	err    error  // underlying error (nil->n/a)
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("verification failed (%v): %s", e.Kind, e.Detail)
+}
+
+// Unwrap outputs the underlying error, e.g., from a payload validator
+func (e *VerificationError) Unwrap() error {
+	return e.err
+}
+
+// verificationError outputs a new VerificationError of a given kind
+func verificationError(kind VerificationErrorKind, format string,
+	a ...interface{}) error {
+	return &VerificationError{Kind: kind, Detail: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,100 @@
+package lwm
+
+// TreeEventKind is the kind of change that a TreeEvent describes
+type TreeEventKind int
+
+const (
+	// EventAdded indicates that a key was added, see Add
+	EventAdded TreeEventKind = iota
+	// EventRemoved indicates that a key was removed, see Remove
+	EventRemoved
+	// EventUpdated indicates that a key's payload was replaced, see Update
+	EventUpdated
+	// EventCompacted indicates that the tree was compacted, see Compact
+	EventCompacted
+)
+
+func (k TreeEventKind) String() string {
+	switch k {
+	case EventAdded:
+		return "added"
+	case EventRemoved:
+		return "removed"
+	case EventUpdated:
+		return "updated"
+	case EventCompacted:
+		return "compacted"
+	default:
+		return "unknown"
+	}
+}
+
+// TreeEvent describes a successful change of a tree
+type TreeEvent struct {
+	Kind        TreeEventKind
+	Key         string // normalized key (empty if the tree was compacted)
+	OldSnapshot []byte
+	NewSnapshot []byte
+}
+
+// OnChange registers fn to be called after each successful Add, Remove,
+// Update, Compact, transaction commit, and bulk operation. Callbacks are called
+// synchronously in the order they were registered, and they are also
+// registered on trees that are output by Compact. A transaction or bulk
+// operation emits one event per key, all with the snapshots before and after
+// the entire batch. Note that snapshots are computed before and after every
+// change while callbacks are registered.
+//
+// The returned function deregisters fn, including on compacted trees. It
+// replaces a RemoveOnChange(fn) method, which cannot be implemented reliably
+// because functions are not comparable in Go.
+func (wt *WildcardTree) OnChange(fn func(event TreeEvent)) (cancel func()) {
+	l := &listener{fn: fn}
+	wt.listeners = append(wt.listeners, l)
+	return func() {
+		l.cancelled = true
+		for i, other := range wt.listeners {
+			if other == l {
+				wt.listeners = append(wt.listeners[:i:i], wt.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// listener is a registered callback, see OnChange
+type listener struct {
+	fn        func(event TreeEvent)
+	cancelled bool // the callback is also registered on compacted trees
+}
+
+// activeListeners outputs the callbacks that are not cancelled
+func (wt *WildcardTree) activeListeners() []*listener {
+	var active []*listener
+	for _, l := range wt.listeners {
+		if !l.cancelled {
+			active = append(active, l)
+		}
+	}
+	return active
+}
+
+// oldSnapshot outputs the current snapshot if there are callbacks (nil->n/a)
+func (wt *WildcardTree) oldSnapshot() []byte {
+	if len(wt.listeners) == 0 {
+		return nil
+	}
+	return wt.Snapshot()
+}
+
+// notify calls all callbacks with an event for a change of key
+func (wt *WildcardTree) notify(kind TreeEventKind, key string,
+	oldSnapshot []byte) {
+	if len(wt.listeners) == 0 {
+		return
+	}
+	event := TreeEvent{kind, key, oldSnapshot, wt.Snapshot()}
+	for _, l := range wt.activeListeners() {
+		l.fn(event)
+	}
+}
@@ -0,0 +1,139 @@
+package lwm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOnChange(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	var events []TreeEvent
+	record := func(event TreeEvent) {
+		events = append(events, event)
+	}
+	wt.OnChange(record)
+
+	snapshot := wt.Snapshot()
+	if err := wt.Add("moc.rab", [][]byte{[]byte("bar.com cert")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Kind != EventAdded || event.Key != "moc.rab" {
+		t.Errorf("got %v event for %q, want added event for %q", event.Kind,
+			event.Key, "moc.rab")
+	}
+	if !bytes.Equal(event.OldSnapshot, snapshot) {
+		t.Errorf("got old snapshot %x, want %x", event.OldSnapshot, snapshot)
+	}
+	if bytes.Equal(event.OldSnapshot, event.NewSnapshot) ||
+		!bytes.Equal(event.NewSnapshot, wt.Snapshot()) {
+		t.Errorf("bad new snapshot %x", event.NewSnapshot)
+	}
+
+	// failed operations do not trigger events
+	wt.Add("moc.rab", nil)
+	wt.Remove("moc.zab")
+	wt.Update("moc.zab", nil)
+	if len(events) != 1 {
+		t.Fatalf("got %d events after failed operations, want 1", len(events))
+	}
+
+	wt.Update("moc.rab", [][]byte{[]byte("new cert")})
+	wt.Remove("moc.rab")
+	wt.Compact()
+	for i, kind := range []TreeEventKind{EventAdded, EventUpdated, EventRemoved,
+		EventCompacted} {
+		if i >= len(events) || events[i].Kind != kind {
+			t.Fatalf("got events %v, want %v at position %d", events, kind, i)
+		}
+		if i > 0 && !bytes.Equal(events[i].OldSnapshot,
+			events[i-1].NewSnapshot) {
+			t.Errorf("%v event => old snapshot is not the previous one", kind)
+		}
+	}
+	if !bytes.Equal(events[3].NewSnapshot, snapshot) {
+		t.Errorf("compacted event => got snapshot %x, want %x",
+			events[3].NewSnapshot, snapshot)
+	}
+
+	// callbacks from the same function literal are deregistered separately
+	counts := make([]int, 2)
+	var cancels []func()
+	for i := range counts {
+		cancels = append(cancels, wt.OnChange(func(TreeEvent) { counts[i]++ }))
+	}
+	cancel := cancels[0]
+	cancel()
+	cancel() // no-op
+	wt.Add("moc.rab", nil)
+	if len(events) != 5 || counts[0] != 0 || counts[1] != 1 {
+		t.Errorf("got %d, %d, and %d events after deregistration, want 5, 0, 1",
+			len(events), counts[0], counts[1])
+	}
+
+	// callbacks follow compacted trees, and can still be deregistered
+	compacted := wt.Compact()
+	compacted.Remove("moc.rab")
+	if got := events[len(events)-1]; got.Kind != EventRemoved ||
+		got.Key != "moc.rab" {
+		t.Errorf("compacted tree => got event %v", got)
+	}
+	cancels[1]()
+	compacted.Add("moc.rab", nil)
+	if counts[1] != 3 { // added, compacted, removed
+		t.Errorf("compacted tree => got %d events after deregistration, want 3",
+			counts[1])
+	}
+}
+
+func TestOnChangeBatches(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	var events []TreeEvent
+	wt.OnChange(func(event TreeEvent) { events = append(events, event) })
+	check := func(desc string, old []byte, want ...TreeEvent) {
+		t.Helper()
+		if len(events) != len(want) {
+			t.Fatalf("%s => got %d events, want %d", desc, len(events), len(want))
+		}
+		for i, event := range events {
+			if event.Kind != want[i].Kind || event.Key != want[i].Key {
+				t.Errorf("%s => got %v event for %q, want %v for %q", desc,
+					event.Kind, event.Key, want[i].Kind, want[i].Key)
+			}
+			if !bytes.Equal(event.OldSnapshot, old) ||
+				!bytes.Equal(event.NewSnapshot, wt.Snapshot()) {
+				t.Errorf("%s => bad snapshots in event %d", desc, i)
+			}
+		}
+		events = nil
+	}
+
+	old := wt.Snapshot()
+	tx := wt.Begin()
+	tx.Add("moc.rab", nil)
+	tx.Update("moc.rab", [][]byte{[]byte("bar.com cert")})
+	tx.Remove("vog.zab")
+	if _, err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	check("commit", old, TreeEvent{Kind: EventAdded, Key: "moc.rab"},
+		TreeEvent{Kind: EventUpdated, Key: "moc.rab"},
+		TreeEvent{Kind: EventRemoved, Key: "vog.zab"})
+
+	old = wt.Snapshot()
+	if err := wt.BulkAdd([]Entry{{"b", nil}, {"a", nil}}); err != nil {
+		t.Fatal(err)
+	}
+	check("bulk add", old, TreeEvent{Kind: EventAdded, Key: "b"},
+		TreeEvent{Kind: EventAdded, Key: "a"})
+
+	old = wt.Snapshot()
+	if _, err := wt.BulkDelete([]string{"a", "none", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	check("bulk delete", old, TreeEvent{Kind: EventRemoved, Key: "a"},
+		TreeEvent{Kind: EventRemoved, Key: "b"})
+}
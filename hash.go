@@ -0,0 +1,79 @@
+package lwm
+
+import (
+	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+	"reflect"
+)
+
+// HashFunctions maps hash algorithm names to hash functions that can be used
+// with NewWildcardTree and NewMerkleTree. Registered functions must output
+// hashLen bytes, and are used to (de)serialize proofs by name.
+var HashFunctions = map[string]func(data ...[]byte) []byte{
+	"sha256":      SHA256Hash,
+	"blake2b-256": BLAKE2bHash,
+	"sha3-256":    SHA3Hash,
+	"keccak-256":  Keccak256Hash,
+	"blake3":      BLAKE3Hash,
+}
+
+// SHA256Hash concatenates data and outputs a sha256 hash
+func SHA256Hash(data ...[]byte) []byte {
+	return hash(data...)
+}
+
+// BLAKE2bHash concatenates data and outputs a blake2b-256 hash
+func BLAKE2bHash(data ...[]byte) []byte {
+	h, _ := blake2b.New256(nil) // only fails for keys that are too long
+	for i := 0; i < len(data); i++ {
+		h.Write(data[i])
+	}
+	return h.Sum(nil)
+}
+
+// BLAKE3Hash concatenates data and outputs a 32-byte blake3 hash
+func BLAKE3Hash(data ...[]byte) []byte {
+	h := blake3.New()
+	for i := 0; i < len(data); i++ {
+		h.Write(data[i])
+	}
+	return h.Sum(nil)
+}
+
+// SHA3Hash concatenates data and outputs a SHA3-256 hash (FIPS 202). Note that
+// this is not the same as Solidity's keccak256, see Keccak256Hash.
+func SHA3Hash(data ...[]byte) []byte {
+	h := sha3.New256()
+	for i := 0; i < len(data); i++ {
+		h.Write(data[i])
+	}
+	return h.Sum(nil)
+}
+
+// Keccak256Hash concatenates data and outputs a legacy Keccak-256 hash, which
+// matches keccak256 on EVM-compatible chains
+func Keccak256Hash(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for i := 0; i < len(data); i++ {
+		h.Write(data[i])
+	}
+	return h.Sum(nil)
+}
+
+// hashName outputs the registered name of a hash function (if any)
+func hashName(h func(data ...[]byte) []byte) (string, bool) {
+	if h == nil {
+		return "", false
+	}
+	ptr := reflect.ValueOf(h).Pointer()
+	if ptr == reflect.ValueOf(hash).Pointer() {
+		return "sha256", true
+	}
+	for name, f := range HashFunctions {
+		if reflect.ValueOf(f).Pointer() == ptr {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,162 @@
+package lwm
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/golang/example/stringutil"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+	stdhash "hash"
+	"testing"
+)
+
+func TestMthBLAKE2b(t *testing.T) {
+	for _, table := range []struct {
+		data [][]byte
+		root []byte
+	}{
+		{leafData(0), r0()}, {leafData(1), r1()}, {leafData(2), r2()},
+		{leafData(3), r3()}, {leafData(4), r4()}, {leafData(5), r5()},
+		{leafData(6), r6()}, {leafData(7), r7()}, {leafData(8), r8()},
+		{leafData(9), r9()}, {leafData(10), r10()}, {leafData(13), r13()},
+		{leafData(16), r16()}, {leafData(17), r17()}, {leafData(23), r23()},
+	} {
+		mt := NewMerkleTree(testTwc, lp, ip, BLAKE2bHash, table.data)
+		root := mt.Mth()
+		if len(root) != hashLen {
+			t.Errorf("Bad root length => got %v, want %v", len(root), hashLen)
+		}
+		if bytes.Equal(root, table.root) {
+			t.Errorf("BLAKE2b root equals SHA-256 root for %d leaves",
+				len(table.data))
+		}
+		for i := range table.data {
+			rp := mt.MthFromAp(table.data[i], i, len(table.data), mt.Ap(i))
+			if !bytes.Equal(root, rp) {
+				t.Errorf("Bad recomputed root hash =>\ngot:  %v\nwant: %v", rp, root)
+			}
+		}
+	}
+
+	empty := blake2b.Sum256(nil)
+	if root := NewMerkleTree(testTwc, lp, ip, BLAKE2bHash, nil).Mth(); !bytes.Equal(root, empty[:]) {
+		t.Errorf("Bad empty root =>\ngot:  %v\nwant: %v", root, empty)
+	}
+}
+
+func TestSHA3(t *testing.T) {
+	for _, table := range []struct {
+		name  string
+		h     func(data ...[]byte) []byte
+		ref   func() stdhash.Hash
+		empty string // reference hash of the empty string
+	}{
+		{"sha3-256", SHA3Hash, sha3.New256,
+			"a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a"},
+		{"keccak-256", Keccak256Hash, sha3.NewLegacyKeccak256,
+			"c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+	} {
+		ref := func(data ...[]byte) []byte {
+			h := table.ref()
+			for _, d := range data {
+				h.Write(d)
+			}
+			return h.Sum(nil)
+		}
+		if got := table.h(); !bytes.Equal(got, decode(table.empty)) {
+			t.Errorf("%s => got %x for the empty string, want %s", table.name,
+				got, table.empty)
+		}
+
+		// empty tree: the root is h(twc)
+		wt := MustNewWildcardTree(twc, table.h, nil)
+		if got, want := wt.Snapshot(), ref(twc); !bytes.Equal(got, want) {
+			t.Errorf("%s => got empty root %x, want %x", table.name, got, want)
+		}
+
+		// serialize a proof for a single-leaf tree, and recompute its root
+		// from the leaf data using the reference implementation directly
+		m := map[string]interface{}{"moc.oof": [][]byte{[]byte("foo.com cert")}}
+		wt = MustNewWildcardTree(twc, table.h, m)
+		answer, proof := wt.Get("moc.oof")
+		b, err := proof.Marshal()
+		if err != nil {
+			t.Errorf("%s => marshal failed: %v", table.name, err)
+			continue
+		}
+		var p Proof
+		if err := p.Unmarshal(b); err != nil {
+			t.Errorf("%s => unmarshal failed: %v", table.name, err)
+			continue
+		}
+		leaf := append([]byte("moc.oof"), ref([]byte("foo.com cert"))...)
+		root := ref(twc, leafPrefix, leaf)
+		if !bytes.Equal(wt.Snapshot(), root) {
+			t.Errorf("%s => got root %x, want %x", table.name, wt.Snapshot(), root)
+		}
+		if err := p.Verify("moc.oof", answer, 1, root); err != nil {
+			t.Errorf("%s => valid proof rejected: %v", table.name, err)
+		}
+	}
+}
+
+func TestBLAKE3(t *testing.T) {
+	empty := decode("af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262")
+	if got := BLAKE3Hash(); !bytes.Equal(got, empty) {
+		t.Errorf("Bad hash of the empty string =>\ngot:  %x\nwant: %x", got, empty)
+	}
+	if !bytes.Equal(BLAKE3Hash(), BLAKE3Hash()) {
+		t.Errorf("BLAKE3Hash() is not deterministic")
+	}
+	if !bytes.Equal(BLAKE3Hash(), BLAKE3Hash(nil)) {
+		t.Errorf("BLAKE3Hash(nil) does not hash the empty string")
+	}
+	if bytes.Equal(BLAKE3Hash(nil), BLAKE3Hash(twc)) {
+		t.Errorf("BLAKE3Hash does not depend on twc")
+	}
+	if !bytes.Equal(BLAKE3Hash([]byte("ab"), []byte("c")), BLAKE3Hash([]byte("abc"))) {
+		t.Errorf("BLAKE3Hash does not concatenate its input")
+	}
+}
+
+func TestHashFunctions(t *testing.T) {
+	m := testData()
+	key := stringutil.Reverse("foo.com")
+	for name, h := range HashFunctions {
+		wt := MustNewWildcardTree(twc, h, m)
+		snapshot := wt.Snapshot()
+		answer, proof := wt.Get(key)
+		b, err := json.Marshal(proof)
+		if err != nil {
+			t.Errorf("%s => marshal failed: %v", name, err)
+			continue
+		}
+		var p Proof
+		if err := json.Unmarshal(b, &p); err != nil {
+			t.Errorf("%s => unmarshal failed: %v", name, err)
+			continue
+		}
+		if err := p.Verify(key, answer, len(m), snapshot); err != nil {
+			t.Errorf("%s => round-tripped proof rejected: %v", name, err)
+		}
+		if got, _ := hashName(p.hash); got != name {
+			t.Errorf("%s => got hash name %q", name, got)
+		}
+	}
+}
+
+func BenchmarkMthSHA256(b *testing.B) {
+	benchmarkMth(b, SHA256Hash, 10000)
+}
+
+func BenchmarkMthBLAKE3(b *testing.B) {
+	benchmarkMth(b, BLAKE3Hash, 10000)
+}
+
+func benchmarkMth(b *testing.B, h func(data ...[]byte) []byte, n int) {
+	data := leafData(n)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewMerkleTree(testTwc, lp, ip, h, data).Mth()
+	}
+}
@@ -0,0 +1,145 @@
+package lwm
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// HistoricalWildcardTree is a WildcardTree that remembers its past states, such
+// that answers can be looked up as of a past snapshot. The embedded tree can be
+// used as is. A state is recorded on every change event, see OnChange.
+type HistoricalWildcardTree struct {
+	*WildcardTree
+	history []*historyEntry // circular buffer of past states
+	next    int             // index in history of the next past state
+	current *historyEntry   // state after the most recent change event
+}
+
+// historyEntry is a recorded tree state
+type historyEntry struct {
+	snapshot []byte
+	size     int
+	twc      []byte
+	m        map[string]interface{} // see toMap
+	wt       *WildcardTree          // rebuilt from m when needed (nil->n/a)
+}
+
+// WithHistory outputs a HistoricalWildcardTree that wraps wt and remembers up
+// to maxHistory past states in memory. Each state is a copy of the entries,
+// which means that every change is at least linear in the size of the tree.
+func (wt *WildcardTree) WithHistory(maxHistory int) *HistoricalWildcardTree {
+	if maxHistory < 0 {
+		maxHistory = 0
+	}
+	hwt := &HistoricalWildcardTree{
+		WildcardTree: wt,
+		history:      make([]*historyEntry, 0, maxHistory),
+	}
+	hwt.current = hwt.state()
+	wt.OnChange(hwt.record)
+	return hwt
+}
+
+// GetAt outputs a verifiable wildcard answer for key in the tree as it was at
+// snapshot, see Get. The proof is verified against snapshot before it is
+// returned, and SizeAt outputs the size that it should be verified with. An
+// error that wraps ErrSnapshotNotFound is returned if snapshot is unknown.
+func (hwt *HistoricalWildcardTree) GetAt(snapshot []byte, key string) (Answer,
+	Proof, error) {
+	wt, err := hwt.at(snapshot)
+	if err != nil {
+		return Answer{}, Proof{}, err
+	}
+	key = wt.normalizeKey(key)
+	a, p := wt.Get(key)
+	if err := p.Verify(key, a, wt.Size(), snapshot); err != nil {
+		return Answer{}, Proof{}, fmt.Errorf("invalid proof: %w", err)
+	}
+	return a, p, nil
+}
+
+// SizeAt outputs the size of the tree as it was at snapshot. An error that
+// wraps ErrSnapshotNotFound is returned if snapshot is unknown.
+func (hwt *HistoricalWildcardTree) SizeAt(snapshot []byte) (int, error) {
+	if bytes.Equal(hwt.Snapshot(), snapshot) {
+		return hwt.Size(), nil
+	}
+	e := hwt.find(snapshot)
+	if e == nil {
+		return 0, fmt.Errorf("%w: %x", ErrSnapshotNotFound, snapshot)
+	}
+	return e.size, nil
+}
+
+// Snapshots outputs the snapshots that GetAt accepts, from oldest to newest
+func (hwt *HistoricalWildcardTree) Snapshots() [][]byte {
+	var snapshots [][]byte
+	for i := range hwt.history {
+		e := hwt.history[(hwt.next+i)%len(hwt.history)]
+		snapshots = append(snapshots, e.snapshot)
+	}
+	if current := hwt.Snapshot(); !bytes.Equal(hwt.current.snapshot, current) {
+		snapshots = append(snapshots, hwt.current.snapshot)
+	}
+	return append(snapshots, hwt.Snapshot())
+}
+
+// at outputs the tree as it was at snapshot
+func (hwt *HistoricalWildcardTree) at(snapshot []byte) (*WildcardTree, error) {
+	if bytes.Equal(hwt.Snapshot(), snapshot) {
+		return hwt.WildcardTree, nil
+	}
+	e := hwt.find(snapshot)
+	if e == nil {
+		return nil, fmt.Errorf("%w: %x", ErrSnapshotNotFound, snapshot)
+	}
+	if e.wt == nil {
+		var err error
+		if e.wt, err = hwt.rebuild(e.twc, e.m); err != nil {
+			return nil, err
+		}
+	}
+	return e.wt, nil
+}
+
+// find outputs the most recent recorded state with snapshot (nil->not found).
+// The current state is included, since the tree may have changed without an
+// event.
+func (hwt *HistoricalWildcardTree) find(snapshot []byte) *historyEntry {
+	if bytes.Equal(hwt.current.snapshot, snapshot) {
+		return hwt.current
+	}
+	for i := 1; i <= len(hwt.history); i++ {
+		e := hwt.history[(hwt.next-i+len(hwt.history))%len(hwt.history)]
+		if bytes.Equal(e.snapshot, snapshot) {
+			return e
+		}
+	}
+	return nil
+}
+
+// record is called on every change event to remember the previous state
+func (hwt *HistoricalWildcardTree) record(event TreeEvent) {
+	if bytes.Equal(hwt.current.snapshot, event.NewSnapshot) {
+		return // e.g., the tree was compacted
+	}
+	if n := cap(hwt.history); n > 0 {
+		if len(hwt.history) < n {
+			hwt.history = append(hwt.history, hwt.current)
+		} else {
+			hwt.history[hwt.next] = hwt.current
+		}
+		hwt.next = (hwt.next + 1) % n
+	}
+	hwt.current = hwt.state()
+}
+
+// state outputs the current state of the tree
+func (hwt *HistoricalWildcardTree) state() *historyEntry {
+	return &historyEntry{
+		snapshot: cloneBytes(hwt.Snapshot()),
+		size:     hwt.Size(),
+		twc:      hwt.TWC(),
+		m:        hwt.toMap(),
+	}
+}
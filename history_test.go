@@ -0,0 +1,100 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHistoricalWildcardTree(t *testing.T) {
+	hwt := MustNewWildcardTree(twc, hash, testData()).WithHistory(10)
+	states := []map[string]interface{}{testData()}
+	snapshots := [][]byte{hwt.Snapshot()}
+	for i, mutate := range []func() error{
+		func() error { return hwt.Add("moc.oof.3bus", [][]byte{[]byte("3")}) },
+		func() error { return hwt.Update("moc.oof", [][]byte{[]byte("new")}) },
+		func() error { return hwt.Remove("es.xuq") },
+		func() error { return hwt.Add("moc.oof.4bus", [][]byte{[]byte("4")}) },
+		func() error { return hwt.Remove("moc.oof.1bus") },
+	} {
+		if err := mutate(); err != nil {
+			t.Fatalf("mutation %d failed: %v", i, err)
+		}
+		m := make(map[string]interface{})
+		hwt.ForEach(func(key string, payload [][]byte) bool {
+			m[key] = payload
+			return true
+		})
+		states = append(states, m)
+		snapshots = append(snapshots, hwt.Snapshot())
+	}
+	if got := hwt.Snapshots(); !reflect.DeepEqual(got, snapshots) {
+		t.Errorf("got snapshots %x, want %x", got, snapshots)
+	}
+
+	for i, snapshot := range snapshots {
+		want := MustNewWildcardTree(twc, hash, states[i])
+		if !bytes.Equal(want.Snapshot(), snapshot) {
+			t.Fatalf("state %d has snapshot %x, want %x", i, snapshot,
+				want.Snapshot())
+		}
+		size, err := hwt.SizeAt(snapshot)
+		if err != nil || size != want.Size() {
+			t.Errorf("state %d => got size (%d, %v), want %d", i, size, err,
+				want.Size())
+		}
+		for _, key := range []string{"moc.oof", "es.xuq", "moc", "none"} {
+			a, p, err := hwt.GetAt(snapshot, key)
+			if err != nil {
+				t.Errorf("state %d: %q => %v", i, key, err)
+				continue
+			}
+			if err := p.Verify(key, a, size, snapshot); err != nil {
+				t.Errorf("state %d: %q => proof does not verify: %v", i, key, err)
+			}
+			if wa, _ := want.Get(key); !reflect.DeepEqual(a, wa) {
+				t.Errorf("state %d: %q => got answer %v, want %v", i, key, a, wa)
+			}
+		}
+	}
+	if _, _, err := hwt.GetAt(hash([]byte("none")), "moc"); !errors.Is(err,
+		ErrSnapshotNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrSnapshotNotFound)
+	}
+
+	// the oldest states are dropped
+	small := MustNewWildcardTree(twc, hash, testData()).WithHistory(2)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := small.Add(key, nil); err != nil {
+			t.Fatalf("add failed: %v", err)
+		}
+	}
+	if got := len(small.Snapshots()); got != 3 {
+		t.Errorf("got %d snapshots, want 3", got)
+	}
+	if _, err := small.SizeAt(snapshots[0]); !errors.Is(err,
+		ErrSnapshotNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrSnapshotNotFound)
+	}
+
+	// transactions and bulk operations are recorded
+	last := small.Snapshot()
+	tx := small.Begin()
+	tx.Add("d", nil)
+	tx.Add("e", nil)
+	if _, err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	committed := small.Snapshot()
+	if _, err := small.BulkDelete([]string{"a", "b"}); err != nil {
+		t.Fatalf("bulk delete failed: %v", err)
+	}
+	want := [][]byte{last, committed, small.Snapshot()}
+	if got := small.Snapshots(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got snapshots %x, want %x", got, want)
+	}
+	if size, err := small.SizeAt(committed); err != nil || size != 12 {
+		t.Errorf("got size (%d, %v), want 12", size, err)
+	}
+}
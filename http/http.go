@@ -0,0 +1,85 @@
+// Package http serves wildcard answers and proofs as JSON over HTTP
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/rgdd/lwm"
+	nethttp "net/http"
+	"sync"
+)
+
+// Response is the JSON body of a successful proof request. Size is only set
+// if the handler is wrapped by WithSize.
+type Response struct {
+	Answer lwm.Answer `json:"answer"`
+	Proof  lwm.Proof  `json:"proof"`
+	Size   *int       `json:"size,omitempty"`
+}
+
+// ErrorResponse is the JSON body of a failed request
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type sizeKey struct{}
+
+// NewHandler outputs a handler that serves GET /proof?key=<key>, where the key
+// is in reversed order and encoded as unpadded base64url. An empty key matches
+// every key in the tree. The handler serializes look-ups, because the tree is
+// not safe for concurrent use.
+func NewHandler(wt *lwm.WildcardTree) nethttp.Handler {
+	var mu sync.Mutex
+	mux := nethttp.NewServeMux()
+	mux.HandleFunc("/proof", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Method != nethttp.MethodGet {
+			w.Header().Set("Allow", nethttp.MethodGet)
+			writeError(w, nethttp.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		values, ok := r.URL.Query()["key"]
+		if !ok || len(values) != 1 {
+			writeError(w, nethttp.StatusBadRequest, "expected one key parameter")
+			return
+		}
+		key, err := base64.RawURLEncoding.DecodeString(values[0])
+		if err != nil {
+			writeError(w, nethttp.StatusBadRequest, "key is not base64url")
+			return
+		}
+
+		mu.Lock()
+		answer, proof := wt.Get(string(key))
+		mu.Unlock()
+		rsp := Response{Answer: answer, Proof: proof}
+		if size, ok := r.Context().Value(sizeKey{}).(int); ok {
+			rsp.Size = &size
+		}
+		b, err := json.Marshal(rsp)
+		if err != nil {
+			writeError(w, nethttp.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+	return mux
+}
+
+// WithSize attaches a tree size to the request context, which NewHandler
+// includes in its responses such that clients can verify proofs
+func WithSize(next nethttp.Handler, size int) nethttp.Handler {
+	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		ctx := context.WithValue(r.Context(), sizeKey{}, size)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// writeError writes a JSON error body with a given status code
+func writeError(w nethttp.ResponseWriter, code int, msg string) {
+	b, _ := json.Marshal(ErrorResponse{Error: msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang/example/stringutil"
+	"github.com/rgdd/lwm"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	m := map[string]interface{}{
+		stringutil.Reverse("foo.com"):      [][]byte{[]byte("foo.com cert")},
+		stringutil.Reverse("sub.foo.com"):  [][]byte{[]byte("sub.foo.com cert")},
+		stringutil.Reverse("bar.example"):  [][]byte{[]byte("bar.example cert")},
+		stringutil.Reverse("baz.example."): [][]byte{[]byte("baz.example. cert")},
+	}
+	wt := lwm.MustNewWildcardTree([]byte{0xff}, lwm.HashFunctions["sha256"], m)
+	srv := httptest.NewServer(WithSize(NewHandler(wt), wt.Size()))
+	defer srv.Close()
+
+	for _, table := range []struct {
+		desc    string
+		query   string
+		key     string
+		status  int
+		matches int
+	}{
+		{"wildcard match", "?key=" + encode("moc.oof"), "moc.oof", 200, 2},
+		{"exact match", "?key=" + encode("moc.oof.bus"), "moc.oof.bus", 200, 1},
+		{"no match", "?key=" + encode("moc.rab"), "moc.rab", 200, 0},
+		{"empty key", "?key=", "", 200, len(m)},
+		{"missing key", "", "", 400, 0},
+		{"repeated key", "?key=a&key=b", "", 400, 0},
+		{"bad encoding", "?key=***", "", 400, 0},
+	} {
+		rsp, err := nethttp.Get(srv.URL + "/proof" + table.query)
+		if err != nil {
+			t.Fatalf("%s: %v", table.desc, err)
+		}
+		if rsp.StatusCode != table.status {
+			t.Errorf("%s: got status %d, want %d", table.desc, rsp.StatusCode,
+				table.status)
+		}
+		if rsp.StatusCode != 200 {
+			var e ErrorResponse
+			if err := json.NewDecoder(rsp.Body).Decode(&e); err != nil || e.Error == "" {
+				t.Errorf("%s: bad error body: %v", table.desc, err)
+			}
+			rsp.Body.Close()
+			continue
+		}
+
+		var body Response
+		err = json.NewDecoder(rsp.Body).Decode(&body)
+		rsp.Body.Close()
+		if err != nil {
+			t.Errorf("%s: bad body: %v", table.desc, err)
+			continue
+		}
+		if got := len(body.Answer.Subjects()); got != table.matches {
+			t.Errorf("%s: got %d matches, want %d", table.desc, got, table.matches)
+		}
+		if body.Size == nil {
+			t.Errorf("%s: missing size", table.desc)
+			continue
+		}
+		if err := body.Proof.Verify(table.key, body.Answer, *body.Size,
+			wt.Snapshot()); err != nil {
+			t.Errorf("%s: valid proof rejected: %v", table.desc, err)
+		}
+	}
+
+	// other methods
+	rsp, err := nethttp.Post(srv.URL+"/proof?key=", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != nethttp.StatusMethodNotAllowed {
+		t.Errorf("post: got status %d, want %d", rsp.StatusCode,
+			nethttp.StatusMethodNotAllowed)
+	}
+
+	// proofs that cannot be encoded, i.e., unregistered hash functions
+	h := func(data ...[]byte) []byte { return lwm.HashFunctions["sha256"](data...) }
+	rec := httptest.NewRecorder()
+	NewHandler(lwm.MustNewWildcardTree(nil, h, m)).ServeHTTP(rec,
+		httptest.NewRequest("GET", "/proof?key=", nil))
+	if rec.Code != nethttp.StatusInternalServerError {
+		t.Errorf("unencodable proof: got status %d, want %d", rec.Code,
+			nethttp.StatusInternalServerError)
+	}
+}
+
+func encode(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
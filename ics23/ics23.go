@@ -0,0 +1,256 @@
+// Package ics23 converts wildcard tree proofs to the ICS23 proof format that
+// is used by the Cosmos SDK and IBC. Only trees that use SHA-256 are supported.
+//
+// An ICS23 leaf is hashed as the spec's leaf prefix followed by the key and
+// the value, which matches a wildcard tree leaf if the prefix is the tree-wide
+// constant followed by the leaf prefix and the value is the payload hash.
+package ics23
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	ics23 "github.com/confio/ics23/go"
+	"github.com/rgdd/lwm"
+	"math"
+	"math/big"
+)
+
+const hashLen = sha256.Size
+
+var (
+	leafPrefix     = []byte{0x00}
+	interiorPrefix = []byte{0x01}
+)
+
+// ProofSpec outputs an ICS23 proof specification for a wildcard tree with
+// tree-wide constant twc. ICS23 accepts leaf prefixes that extend the spec's
+// prefix, which means that a verifier must also check that the prefix of an
+// existence proof's leaf is exactly the spec's prefix. Otherwise key bytes may
+// be moved into the prefix.
+func ProofSpec(twc []byte) *ics23.ProofSpec {
+	return &ics23.ProofSpec{
+		LeafSpec: leafOp(twc),
+		InnerSpec: &ics23.InnerSpec{
+			ChildOrder:      []int32{0, 1},
+			ChildSize:       hashLen,
+			MinPrefixLength: int32(len(interiorPrefix)),
+			MaxPrefixLength: int32(len(interiorPrefix)),
+			Hash:            ics23.HashOp_SHA256,
+		},
+	}
+}
+
+// Value outputs the ICS23 value of a payload, i.e., its hash
+func Value(payload [][]byte) []byte {
+	h := sha256.New()
+	for _, p := range payload {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// ToICS23ExistenceProof converts the i:th match of a verified answer into an
+// ICS23 existence proof. The tree size is needed to place the match in the
+// Merkle tree, since it is not part of a proof.
+func ToICS23ExistenceProof(a lwm.Answer, p lwm.Proof, i, size int) (
+	*ics23.ExistenceProof, error) {
+	subjects, payloads := a.Subjects(), a.Payloads()
+	if i < 0 || i >= len(subjects) || len(subjects) != len(payloads) {
+		return nil, fmt.Errorf("no match with index %d", i)
+	}
+	leaves := make([][]byte, 0, len(subjects)+2)
+	if ll := p.LeftLeaf(); ll != nil {
+		leaves = append(leaves, ll)
+	}
+	for j, subject := range subjects {
+		leaves = append(leaves, append([]byte(subject), Value(payloads[j])...))
+	}
+	if rl := p.RightLeaf(); rl != nil {
+		leaves = append(leaves, rl)
+	}
+	index := p.Index() + i
+	if p.LeftLeaf() != nil {
+		index++
+	}
+	return existenceProof(p, leaves, index, size)
+}
+
+// ToICS23NonExistenceProof converts a verified proof of an empty wildcard
+// answer into an ICS23 non-existence proof for key
+func ToICS23NonExistenceProof(key string, p lwm.Proof, size int) (
+	*ics23.NonExistenceProof, error) {
+	ll, rl := p.LeftLeaf(), p.RightLeaf()
+	if ll == nil && rl == nil {
+		return nil, errors.New("proof has no neighbors")
+	}
+	leaves := [][]byte{}
+	for _, leaf := range [][]byte{ll, rl} {
+		if leaf != nil {
+			leaves = append(leaves, leaf)
+		}
+	}
+
+	nep := &ics23.NonExistenceProof{Key: []byte(key)}
+	var err error
+	index := p.Index()
+	if ll != nil {
+		if nep.Left, err = existenceProof(p, leaves, index, size); err != nil {
+			return nil, err
+		}
+		index++
+	}
+	if rl != nil {
+		if nep.Right, err = existenceProof(p, leaves, index, size); err != nil {
+			return nil, err
+		}
+	}
+	return nep, nil
+}
+
+// existenceProof outputs an ICS23 existence proof for the leaf with index m,
+// where leaves is the consecutive range of leaf data covered by p
+func existenceProof(p lwm.Proof, leaves [][]byte, m, size int) (
+	*ics23.ExistenceProof, error) {
+	if !bytes.Equal(p.LeafPrefix(), leafPrefix) ||
+		!bytes.Equal(p.InteriorPrefix(), interiorPrefix) {
+		return nil, errors.New("proof does not use the default prefixes")
+	}
+	if p.HashLength() != 0 {
+		return nil, errors.New("proof uses truncated hashes")
+	}
+	if p.NonceLength() != 0 {
+		return nil, errors.New("proof uses leaf nonces")
+	}
+	lindex := p.Index()
+	rindex := lindex + len(leaves) - 1
+	if lindex < 0 || m < lindex || m > rindex || rindex >= size {
+		return nil, fmt.Errorf("leaf index %d is not in [%d,%d] of size %d",
+			m, lindex, rindex, size)
+	}
+	for _, leaf := range leaves {
+		if len(leaf) <= hashLen {
+			return nil, errors.New("leaf data is too short")
+		}
+	}
+
+	// sibling hashes outside of the range are provided by the audit paths
+	known := make(map[[2]int][]byte)
+	for _, ap := range []struct {
+		m    int
+		path [][]byte
+	}{
+		{lindex, p.LeftAP()},
+		{rindex, p.RightAP()},
+	} {
+		siblings := path(ap.m, 0, size)
+		if ap.path == nil {
+			continue
+		}
+		if len(siblings) != len(ap.path) {
+			return nil, errors.New("audit path does not fit the tree size")
+		}
+		for j, s := range siblings {
+			known[s] = ap.path[j]
+		}
+	}
+	r := &rangeTree{known: known, leaves: leaves, lindex: lindex,
+		twc: p.TWC()}
+
+	key, _ := lwm.LeafKey(leaves[m-lindex])
+	value, _ := lwm.LeafHash(leaves[m-lindex])
+	ep := &ics23.ExistenceProof{
+		Key:   []byte(key),
+		Value: value,
+		Leaf:  leafOp(p.TWC()),
+	}
+	for _, s := range path(m, 0, size) {
+		h, err := r.hash(s[0], s[1])
+		if err != nil {
+			return nil, err
+		}
+		op := &ics23.InnerOp{Hash: ics23.HashOp_SHA256}
+		if s[0] > m { // sibling is to the right
+			op.Prefix = clone(interiorPrefix)
+			op.Suffix = h
+		} else {
+			op.Prefix = append(clone(interiorPrefix), h...)
+		}
+		ep.Path = append(ep.Path, op)
+	}
+	return ep, nil
+}
+
+// path outputs the leaf ranges [lo,hi) of all siblings on the path from leaf
+// m to the root of the subtree [lo,hi), ordered from the leaf
+func path(m, lo, hi int) [][2]int {
+	if hi-lo <= 1 {
+		return nil
+	}
+	k := lo + lpow2s(hi-lo)
+	if m < k {
+		return append(path(m, lo, k), [2]int{k, hi})
+	}
+	return append(path(m, k, hi), [2]int{lo, k})
+}
+
+// rangeTree computes node hashes from a consecutive range of leaves and the
+// hashes of subtrees that are outside of the range
+type rangeTree struct {
+	known  map[[2]int][]byte
+	leaves [][]byte
+	lindex int
+	twc    []byte
+}
+
+// hash outputs the hash of the subtree that covers leaves [lo,hi)
+func (r *rangeTree) hash(lo, hi int) ([]byte, error) {
+	if h, ok := r.known[[2]int{lo, hi}]; ok {
+		return h, nil
+	}
+	rindex := r.lindex + len(r.leaves)
+	if hi <= r.lindex || lo >= rindex {
+		return nil, fmt.Errorf("missing hash for leaves [%d,%d)", lo, hi)
+	}
+	if hi-lo == 1 {
+		return sha(r.twc, leafPrefix, r.leaves[lo-r.lindex]), nil
+	}
+	k := lo + lpow2s(hi-lo)
+	left, err := r.hash(lo, k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := r.hash(k, hi)
+	if err != nil {
+		return nil, err
+	}
+	return sha(interiorPrefix, left, right), nil
+}
+
+// leafOp outputs the ICS23 leaf operation for a tree-wide constant
+func leafOp(twc []byte) *ics23.LeafOp {
+	return &ics23.LeafOp{
+		Hash:         ics23.HashOp_SHA256,
+		PrehashKey:   ics23.HashOp_NO_HASH,
+		PrehashValue: ics23.HashOp_NO_HASH,
+		Length:       ics23.LengthOp_NO_PREFIX,
+		Prefix:       append(clone(twc), leafPrefix...),
+	}
+}
+
+// sha outputs the SHA-256 hash of data concatenated
+func sha(data ...[]byte) []byte {
+	return Value(data)
+}
+
+// clone outputs a copy of b without spare capacity, since ICS23 appends to
+// prefixes when hashing
+func clone(b []byte) []byte {
+	return bytes.Clone(b)[:len(b):len(b)]
+}
+
+// lpow2s outputs the largest power of 2 smaller than n
+func lpow2s(n int) int {
+	return int(math.Pow(2, float64(big.NewInt(int64(n-1)).BitLen()-1)))
+}
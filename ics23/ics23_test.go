@@ -0,0 +1,100 @@
+package ics23
+
+import (
+	"fmt"
+	ics23 "github.com/confio/ics23/go"
+	"github.com/golang/example/stringutil"
+	"github.com/rgdd/lwm"
+	"testing"
+)
+
+var twc = []byte{0xff}
+
+func TestToICS23ExistenceProof(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		m := make(map[string]interface{})
+		for i := 0; i < n; i++ {
+			m[fmt.Sprintf("key%02d", i)] = [][]byte{[]byte(fmt.Sprintf("cert%d", i))}
+		}
+		wt := lwm.MustNewWildcardTree(twc, lwm.HashFunctions["sha256"], m)
+		spec, root := ProofSpec(twc), wt.Snapshot()
+		for key, payload := range m {
+			a, p, _ := wt.GetExact(key)
+			ep, err := ToICS23ExistenceProof(a, p, 0, n)
+			if err != nil {
+				t.Errorf("size %d, key %q: %v", n, key, err)
+				continue
+			}
+			value := Value(payload.([][]byte))
+			if !ics23.VerifyMembership(spec, root, exist(ep), []byte(key), value) {
+				t.Errorf("size %d, key %q: membership rejected", n, key)
+			}
+			if ics23.VerifyMembership(spec, root, exist(ep), []byte(key), Value(nil)) {
+				t.Errorf("size %d, key %q: bad value accepted", n, key)
+			}
+		}
+	}
+
+	// every match of a wildcard answer
+	wt := lwm.MustNewWildcardTree(twc, lwm.HashFunctions["sha256"], testData())
+	a, p := wt.Get(stringutil.Reverse("foo.com"))
+	for i, subject := range a.Subjects() {
+		ep, err := ToICS23ExistenceProof(a, p, i, wt.Size())
+		if err != nil {
+			t.Errorf("%q: %v", subject, err)
+			continue
+		}
+		if !ics23.VerifyMembership(ProofSpec(twc), wt.Snapshot(), exist(ep),
+			[]byte(subject), Value(a.Payloads()[i])) {
+			t.Errorf("%q: membership rejected", subject)
+		}
+	}
+	if _, err := ToICS23ExistenceProof(a, p, len(a.Subjects()), wt.Size()); err == nil {
+		t.Errorf("accepted out-of-range match index")
+	}
+}
+
+func TestToICS23NonExistenceProof(t *testing.T) {
+	wt := lwm.MustNewWildcardTree(twc, lwm.HashFunctions["sha256"], testData())
+	spec, root := ProofSpec(twc), wt.Snapshot()
+	for _, key := range []string{"a", "moc.oof.0bus", "moc.oof.3bus", "zzz"} {
+		_, p := wt.Get(key)
+		nep, err := ToICS23NonExistenceProof(key, p, wt.Size())
+		if err != nil {
+			t.Errorf("%q: %v", key, err)
+			continue
+		}
+		if !ics23.VerifyNonMembership(spec, root, nonExist(nep), []byte(key)) {
+			t.Errorf("%q: non-membership rejected", key)
+		}
+	}
+
+	// a present key is not between the neighbors of an absent key
+	_, p := wt.Get("moc.oof.0bus")
+	nep, err := ToICS23NonExistenceProof("moc.oof.1bus", p, wt.Size())
+	if err != nil {
+		t.Fatalf("conversion failed: %v", err)
+	}
+	if ics23.VerifyNonMembership(spec, root, nonExist(nep), []byte("moc.oof.1bus")) {
+		t.Errorf("non-membership accepted for present key")
+	}
+}
+
+func exist(ep *ics23.ExistenceProof) *ics23.CommitmentProof {
+	return &ics23.CommitmentProof{Proof: &ics23.CommitmentProof_Exist{Exist: ep}}
+}
+
+func nonExist(nep *ics23.NonExistenceProof) *ics23.CommitmentProof {
+	return &ics23.CommitmentProof{
+		Proof: &ics23.CommitmentProof_Nonexist{Nonexist: nep},
+	}
+}
+
+func testData() map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, name := range []string{"foo.com", "sub1.foo.com", "sub2.foo.com",
+		"sub.bar.edu", "baz.gov", "qux.se", "sub.qux.se"} {
+		m[stringutil.Reverse(name)] = [][]byte{[]byte(name + " cert")}
+	}
+	return m
+}
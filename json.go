@@ -0,0 +1,164 @@
+package lwm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// proofJSON is the JSON representation of a Proof
+type proofJSON struct {
+	HashAlgorithm string      `json:"hash_algorithm"`
+	TWC           base64URL   `json:"twc"`
+	Index         int         `json:"index"`
+	LeftLeaf      base64URL   `json:"left_leaf"`
+	RightLeaf     base64URL   `json:"right_leaf"`
+	LeftAP        []base64URL `json:"left_ap"`
+	RightAP       []base64URL `json:"right_ap"`
+	HashLength    int         `json:"hash_length,omitempty"`  // 0->untruncated
+	NonceLength   int         `json:"nonce_length,omitempty"` // 0->n/a
+}
+
+// MarshalJSON outputs a JSON encoding of the proof. Byte slices are encoded as
+// unpadded base64url strings, and absent components as null. The proof's hash
+// function must be registered by name, and its prefixes must be the defaults.
+// The hash length is only included if hashes are truncated, and the nonce
+// length if leaves have nonces.
+func (p Proof) MarshalJSON() ([]byte, error) {
+	name, ok := hashName(p.hash)
+	if !ok {
+		return nil, fmt.Errorf("unregistered hash function")
+	}
+	if !p.defaultPrefixes() {
+		return nil, fmt.Errorf("custom prefixes cannot be encoded")
+	}
+	return json.Marshal(proofJSON{
+		HashAlgorithm: name,
+		TWC:           p.twc,
+		Index:         p.index,
+		LeftLeaf:      p.ll,
+		RightLeaf:     p.rl,
+		LeftAP:        toBase64URLs(p.lap),
+		RightAP:       toBase64URLs(p.rap),
+		HashLength:    p.hashLength,
+		NonceLength:   p.nonceLength,
+	})
+}
+
+// UnmarshalJSON restores a proof from its JSON encoding, looking up the hash
+// function by name. An error is returned for unknown hash algorithms.
+func (p *Proof) UnmarshalJSON(b []byte) error {
+	var pj proofJSON
+	if err := json.Unmarshal(b, &pj); err != nil {
+		return err
+	}
+	h, ok := HashFunctions[pj.HashAlgorithm]
+	if !ok {
+		return fmt.Errorf("unknown hash algorithm %q", pj.HashAlgorithm)
+	}
+	if n := len(h()); pj.HashLength < 0 || pj.HashLength >= n {
+		return fmt.Errorf("hash length %d is not in [1,%d)", pj.HashLength, n)
+	}
+	if pj.NonceLength < 0 {
+		return fmt.Errorf("negative nonce length %d", pj.NonceLength)
+	}
+	*p = Proof{
+		hash:  h,
+		twc:   pj.TWC,
+		index: pj.Index,
+		ll:    pj.LeftLeaf,
+		rl:    pj.RightLeaf,
+		lap:   fromBase64URLs(pj.LeftAP),
+		rap:   fromBase64URLs(pj.RightAP),
+
+		hashLength:  pj.HashLength,
+		nonceLength: pj.NonceLength,
+	}
+	return nil
+}
+
+// answerJSON is the JSON representation of an Answer
+type answerJSON struct {
+	Subject []string      `json:"subject"`
+	Payload [][]base64URL `json:"payload"`
+}
+
+// MarshalJSON outputs a JSON encoding of the answer. Each payload is encoded
+// as a list of unpadded base64url strings.
+func (a Answer) MarshalJSON() ([]byte, error) {
+	aj := answerJSON{Subject: a.subject}
+	for _, p := range a.payload {
+		aj.Payload = append(aj.Payload, toBase64URLs(p))
+	}
+	return json.Marshal(aj)
+}
+
+// UnmarshalJSON restores an answer from its JSON encoding. An error is returned
+// if the number of subjects and payloads differ.
+func (a *Answer) UnmarshalJSON(b []byte) error {
+	var aj answerJSON
+	if err := json.Unmarshal(b, &aj); err != nil {
+		return err
+	}
+	if len(aj.Subject) != len(aj.Payload) {
+		return fmt.Errorf("got %d subjects but %d payloads", len(aj.Subject),
+			len(aj.Payload))
+	}
+	*a = Answer{subject: aj.Subject}
+	for _, p := range aj.Payload {
+		a.payload = append(a.payload, fromBase64URLs(p))
+	}
+	return nil
+}
+
+// base64URL is a byte slice that is JSON-encoded as an unpadded base64url
+// string, or as null if it is nil
+type base64URL []byte
+
+func (b base64URL) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *base64URL) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == nil {
+		*b = nil
+		return nil
+	}
+	d, err := base64.RawURLEncoding.DecodeString(*s)
+	if err != nil {
+		return err
+	}
+	*b = append(base64URL{}, d...) // non-nil, even if empty
+	return nil
+}
+
+// toBase64URLs converts a list of byte slices, preserving nil
+func toBase64URLs(data [][]byte) []base64URL {
+	if data == nil {
+		return nil
+	}
+	out := make([]base64URL, len(data))
+	for i, d := range data {
+		out[i] = d
+	}
+	return out
+}
+
+// fromBase64URLs converts a list of base64URL values, preserving nil
+func fromBase64URLs(data []base64URL) [][]byte {
+	if data == nil {
+		return nil
+	}
+	out := make([][]byte, len(data))
+	for i, d := range data {
+		out[i] = d
+	}
+	return out
+}
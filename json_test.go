@@ -0,0 +1,107 @@
+package lwm
+
+import (
+	"encoding/json"
+	"github.com/golang/example/stringutil"
+	"testing"
+)
+
+func TestProofJSON(t *testing.T) {
+	for _, table := range []struct {
+		m    map[string]interface{}
+		keys []string
+	}{
+		{nil, []string{"a"}},
+		{map[string]interface{}{"b": [][]byte{[]byte("b cert")}},
+			[]string{"a", "b", "c"}},
+		{testData(), []string{
+			stringutil.Reverse("foo.com"),
+			stringutil.Reverse("sub0.foo.com"),
+			stringutil.Reverse("bar.se"),
+			stringutil.Reverse("foo.zzz"),
+		}},
+	} {
+		wt := MustNewWildcardTree(twc, hash, table.m)
+		snapshot := wt.Snapshot()
+		for _, key := range table.keys {
+			answer, proof := wt.Get(key)
+			b, err := json.Marshal(proof)
+			if err != nil {
+				t.Errorf("marshal failed for key %v: %v", key, err)
+				continue
+			}
+			var p Proof
+			if err := json.Unmarshal(b, &p); err != nil {
+				t.Errorf("unmarshal failed for key %v: %v", key, err)
+				continue
+			}
+			if p.index != proof.index {
+				t.Errorf("index => got %v, want %v", p.index, proof.index)
+			}
+			if (p.ll == nil) != (proof.ll == nil) || (p.rl == nil) != (proof.rl == nil) {
+				t.Errorf("leaf presence changed for key %v", key)
+			}
+			if err := p.Verify(key, answer, len(table.m), snapshot); err != nil {
+				t.Errorf("round-tripped proof rejected for key %v: %v", key, err)
+			}
+		}
+	}
+}
+
+func TestProofJSONUnknownHash(t *testing.T) {
+	if _, err := json.Marshal(Proof{hash: func(...[]byte) []byte { return nil }}); err == nil {
+		t.Errorf("marshaled proof with unregistered hash function")
+	}
+	var p Proof
+	if err := json.Unmarshal([]byte(`{"hash_algorithm":"md4","index":-1}`), &p); err == nil {
+		t.Errorf("unmarshaled proof with unknown hash algorithm")
+	}
+}
+
+func TestAnswerJSON(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	snapshot := wt.Snapshot()
+	for _, key := range []string{
+		stringutil.Reverse("foo.com"),
+		stringutil.Reverse("sub.qux.se"),
+		stringutil.Reverse("sub0.foo.com"),
+	} {
+		answer, proof := wt.Get(key)
+		ab, err := json.Marshal(answer)
+		if err != nil {
+			t.Errorf("marshal answer failed for key %v: %v", key, err)
+			continue
+		}
+		pb, err := json.Marshal(proof)
+		if err != nil {
+			t.Errorf("marshal proof failed for key %v: %v", key, err)
+			continue
+		}
+
+		var a Answer
+		var p Proof
+		if err := json.Unmarshal(ab, &a); err != nil {
+			t.Errorf("unmarshal answer failed for key %v: %v", key, err)
+			continue
+		}
+		if err := json.Unmarshal(pb, &p); err != nil {
+			t.Errorf("unmarshal proof failed for key %v: %v", key, err)
+			continue
+		}
+		if len(a.subject) != len(answer.subject) {
+			t.Errorf("subjects => got %v, want %v", a.subject, answer.subject)
+		}
+		if err := p.Verify(key, a, len(m), snapshot); err != nil {
+			t.Errorf("round-tripped answer rejected for key %v: %v", key, err)
+		}
+	}
+}
+
+func TestAnswerJSONLengthMismatch(t *testing.T) {
+	var a Answer
+	if err := json.Unmarshal([]byte(`{"subject":["a","b"],"payload":[["YQ"]]}`),
+		&a); err == nil {
+		t.Errorf("accepted answer with more subjects than payloads")
+	}
+}
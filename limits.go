@@ -0,0 +1,61 @@
+package lwm
+
+import (
+	"fmt"
+)
+
+// WithMaxKeyLength makes a tree reject keys that are longer than n bytes after
+// normalization. By default there is no limit.
+func WithMaxKeyLength(n int) WildcardTreeOption {
+	return func(wt *WildcardTree) {
+		wt.maxKeyLength = limit(wt, "key length", n)
+	}
+}
+
+// WithMaxPayloadSize makes a tree reject payloads with more than n bytes in
+// total, i.e., summed over all items. By default there is no limit.
+func WithMaxPayloadSize(n int) WildcardTreeOption {
+	return func(wt *WildcardTree) {
+		wt.maxPayloadSize = limit(wt, "payload size", n)
+	}
+}
+
+// WithMaxPayloadCount makes a tree reject payloads with more than n items. By
+// default there is no limit.
+func WithMaxPayloadCount(n int) WildcardTreeOption {
+	return func(wt *WildcardTree) {
+		wt.maxPayloadCount = limit(wt, "payload count", n)
+	}
+}
+
+// limit outputs n, or records an error in wt if n is not a positive limit
+func limit(wt *WildcardTree, name string, n int) int {
+	if n < 1 && wt.err == nil {
+		wt.err = fmt.Errorf("%s limit %d is not positive", name, n)
+	}
+	return n
+}
+
+// checkLimits outputs an error that wraps ErrLimitExceeded if a key or its
+// payload exceeds the tree's limits
+func (wt *WildcardTree) checkLimits(key string, payload [][]byte) error {
+	if wt.maxKeyLength > 0 && len(key) > wt.maxKeyLength {
+		return fmt.Errorf("%w: key has length %d, max %d", ErrLimitExceeded,
+			len(key), wt.maxKeyLength)
+	}
+	if wt.maxPayloadCount > 0 && len(payload) > wt.maxPayloadCount {
+		return fmt.Errorf("%w: payload of key %q has %d items, max %d",
+			ErrLimitExceeded, key, len(payload), wt.maxPayloadCount)
+	}
+	if wt.maxPayloadSize > 0 {
+		size := 0
+		for _, item := range payload {
+			size += len(item)
+		}
+		if size > wt.maxPayloadSize {
+			return fmt.Errorf("%w: payload of key %q has %d bytes, max %d",
+				ErrLimitExceeded, key, size, wt.maxPayloadSize)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	long := strings.Repeat("a", 10000)
+	large := [][]byte{bytes.Repeat([]byte{1}, 1<<20)}
+	many := make([][]byte, 10000)
+	for i := range many {
+		many[i] = []byte{byte(i)}
+	}
+	opts := []WildcardTreeOption{
+		WithMaxKeyLength(1000),
+		WithMaxPayloadSize(1 << 19),
+		WithMaxPayloadCount(1000),
+	}
+
+	for _, table := range []struct {
+		desc    string
+		key     string
+		payload [][]byte
+	}{
+		{"long key", long, [][]byte{[]byte("payload")}},
+		{"large payload", "key", large},
+		{"many payload items", "key", many},
+	} {
+		if _, err := NewWildcardTree(twc, hash, map[string]interface{}{
+			table.key: table.payload,
+		}, opts...); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%s: new => got error %v, want limit exceeded", table.desc,
+				err)
+		}
+		if _, err := NewWildcardTree(twc, hash, map[string]interface{}{
+			table.key: table.payload,
+		}); err != nil {
+			t.Errorf("%s: new without limits => %v", table.desc, err)
+		}
+
+		wt := MustNewWildcardTree(twc, hash, testData(), opts...)
+		snapshot := wt.Snapshot()
+		if err := wt.Add(table.key, table.payload); !errors.Is(err,
+			ErrLimitExceeded) {
+			t.Errorf("%s: add => got error %v, want limit exceeded", table.desc,
+				err)
+		}
+		tx := wt.Begin()
+		tx.Add(table.key, table.payload)
+		if _, err := tx.Commit(); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%s: commit => got error %v, want limit exceeded",
+				table.desc, err)
+		}
+		if !bytes.Equal(wt.Snapshot(), snapshot) {
+			t.Errorf("%s: tree changed", table.desc)
+		}
+	}
+
+	wt := MustNewWildcardTree(twc, hash, testData(), opts...)
+	key := wt.Keys()[0]
+	if err := wt.Update(key, many); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("update => got error %v, want limit exceeded", err)
+	}
+	if err := wt.Add("key", many[:1000]); err != nil {
+		t.Errorf("add at the limit => %v", err)
+	}
+	if err := wt.Compact().Add(long, nil); !errors.Is(err,
+		ErrLimitExceeded) {
+		t.Errorf("compacted tree dropped its limits: %v", err)
+	}
+
+	// rebuilt trees report entries that exceed the limits instead of panicking
+	a := MustNewWildcardTree(twc, hash, nil, WithMaxKeyLength(3))
+	b := MustNewWildcardTree(twc, hash, map[string]interface{}{
+		"longkey1": [][]byte{},
+	})
+	if _, err := Union(a, b); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("union => got error %v, want limit exceeded", err)
+	}
+
+	for _, opt := range []WildcardTreeOption{
+		WithMaxKeyLength(0),
+		WithMaxPayloadSize(-1),
+		WithMaxPayloadCount(0),
+	} {
+		if _, err := NewWildcardTree(twc, hash, testData(), opt); err == nil {
+			t.Errorf("accepted a non-positive limit")
+		}
+	}
+}
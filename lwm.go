@@ -2,8 +2,14 @@ package lwm
 
 import (
 	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	radix "github.com/armon/go-radix"
 	"sort"
+	"strings"
 )
 
 var (
@@ -11,12 +17,12 @@ var (
 	interiorPrefix = []byte{0x01}
 )
 
-
 // Answer is a wildcard answer that contains a list of matching subject names
 // and associated payloads
 type Answer struct {
-	subject []string
-	payload [][][]byte
+	subject  []string
+	payload  [][][]byte
+	mimeType [][]string // nil or one entry per subject, see MIMEType
 }
 
 // Proof contains information to prove that an answer is authentic and complete
@@ -26,54 +32,333 @@ type Proof struct {
 	index    int                         // first mt index (or where it should be)
 	ll, rl   []byte                      // left and right leaf data (nil->na)
 	lap, rap [][]byte                    // left and right audit paths (nil->n/a)
+
+	leafPrefix, interiorPrefix []byte // nil->default prefixes
+	hashLength                 int    // truncated hash length (0->untruncated)
+	nonceLength                int    // leaf nonce length (0->n/a)
+
+	nonce func(key string) []byte // leaf nonces for verification (nil->n/a)
 }
 
 // WildcardTree is a an authenticated data structure that supports cryptographic
 // (non-)membership proofs for wildcard prefixes
 type WildcardTree struct {
-	r  *radix.Tree
-	mt *MerkleTree
+	r         *radix.Tree
+	mt        *MerkleTree
+	snapshot  []byte                  // cached root hash (nil->not computed)
+	normalize func(key string) string // nil->keys are used as is
+	validate  func(key string) error  // nil->all keys are valid
+	blinded   bool                    // payloads are not stored, see Reveal
+
+	hashLength  int                         // see WithHashLength (0->n/a)
+	untruncated func(data ...[]byte) []byte // hash before truncation (nil->n/a)
+	err         error                       // first invalid option (nil->n/a)
+
+	nonce       func(key string) []byte // see WithLeafNonce (nil->n/a)
+	nonceLength int                     // length of every leaf nonce
+
+	maxKeyLength    int // see WithMaxKeyLength (0->no limit)
+	maxPayloadSize  int // see WithMaxPayloadSize (0->no limit)
+	maxPayloadCount int // see WithMaxPayloadCount (0->no limit)
+
+	listeners []*listener // see OnChange
 }
 
 type radixValue struct {
-	payload [][]byte // an ordered list of data values
-	index   int      // merkle tree index for payload[0]
+	payload   [][]byte // an ordered list of data values
+	mimeTypes []string // one per data value (nil->n/a)
+	index     int      // merkle tree index for payload[0]
 }
 
 // NewWildcardTree outputs a new WildcardTree based on a tree-wide constant
 // twc, a hash function h, and a map of key-value pairs. Every key must be in
-// reversed order (e.g., foo.com->moc.foo), and the associated value a [][]byte.
+// reversed order (e.g., foo.com->moc.foo), and the associated value a [][]byte
+// or a TypedPayload.
+// An error is returned if a value has any other type, if options that
+// validate keys reject them, or if the configured prefixes are not prefix-free.
 func NewWildcardTree(twc []byte, h func(data ...[]byte) []byte,
-	m map[string]interface{}) *WildcardTree {
-	wt := new(WildcardTree)
+	m map[string]interface{}, opts ...WildcardTreeOption) (*WildcardTree,
+	error) {
+	wt, err := newWildcardTree(twc, h, opts)
+	if err != nil {
+		return nil, err
+	}
+	m, err = wt.prepareKeys(m)
+	if err != nil {
+		return nil, err
+	}
 	// Order key-value pairs in radix order, creating a Merkle tree and saving
 	// the resulting indices in a new (final) radix tree for easy look-up
 	r := radix.NewFromMap(m)
-	tmp, index := make(map[string]interface{}), 0
+	tmp := make(map[string]interface{})
 	var data [][]byte
 	r.WalkPrefix("", func(k string, v interface{}) bool {
-		p, ok := v.([][]byte)
-		if !ok {
-			panic("This should never happen given the function's precondition")
+		var rv radixValue
+		var leaf []byte
+		if rv, leaf, err = wt.newEntry(k, v, len(data)); err != nil {
+			return true
 		}
-		tmp[k], index = radixValue{payload: p, index: index}, index+1
-		data = append(data, append([]byte(k), h(p...)...))
+		tmp[k] = rv
+		data = append(data, leaf)
 		return false
 	})
+	if err != nil {
+		return nil, err
+	}
 	wt.r = radix.NewFromMap(tmp)
-	wt.mt = NewMerkleTree(twc, leafPrefix, interiorPrefix, h, data)
+	wt.mt = NewMerkleTree(twc, wt.mt.leafPrefix, wt.mt.interiorPrefix,
+		wt.mt.hash, data)
+	return wt, nil
+}
+
+// newWildcardTree outputs an empty tree with all options applied. The hash
+// function of its Merkle tree is truncated if requested, see WithHashLength.
+func newWildcardTree(twc []byte, h func(data ...[]byte) []byte,
+	opts []WildcardTreeOption) (*WildcardTree, error) {
+	wt := new(WildcardTree)
+	wt.mt = NewMerkleTree(twc, leafPrefix, interiorPrefix, h, nil) // prefixes
+	for _, opt := range opts {
+		opt(wt)
+	}
+	if wt.err != nil {
+		return nil, wt.err
+	}
+	if err := checkPrefixes(wt.mt.leafPrefix, wt.mt.interiorPrefix); err != nil {
+		return nil, err
+	}
+	if wt.hashLength > 0 {
+		if n := len(h()); wt.hashLength > n {
+			return nil, fmt.Errorf("hash length %d exceeds the hash output of %d "+
+				"bytes", wt.hashLength, n)
+		} else if wt.hashLength == n {
+			wt.hashLength = 0 // nothing to truncate
+		} else {
+			wt.untruncated, h = h, truncatedHash(h, wt.hashLength)
+		}
+	}
+	if wt.nonce != nil {
+		wt.nonceLength = len(wt.nonce(""))
+	}
+	wt.r = radix.New()
+	wt.mt = NewMerkleTree(twc, wt.mt.leafPrefix, wt.mt.interiorPrefix, h, nil)
+	return wt, nil
+}
+
+// newEntry outputs the radix value and the leaf data of a normalized key k
+// with value v at a given index, see NewWildcardTree for valid values
+func (wt *WildcardTree) newEntry(k string, v interface{}, index int) (
+	radixValue, []byte, error) {
+	var rv radixValue
+	var ph []byte
+	switch v := v.(type) {
+	case [][]byte:
+		rv.payload, ph = v, wt.mt.hash(v...)
+	case TypedPayload:
+		if len(v.MIMETypes) != len(v.Data) {
+			return rv, nil, fmt.Errorf("key %q has %d MIME types for %d payload "+
+				"items", k, len(v.MIMETypes), len(v.Data))
+		}
+		rv.payload, rv.mimeTypes, ph = v.Data, v.MIMETypes, wt.mt.hash(v.Data...)
+	case blindedPayload: // from toMap() of a blinded tree
+		rv.mimeTypes, ph = v.mimeTypes, v.hash
+	default:
+		return rv, nil, fmt.Errorf("value of key %q has type %T, want [][]byte "+
+			"or TypedPayload", k, v)
+	}
+	if err := wt.checkLimits(k, rv.payload); err != nil {
+		return rv, nil, err
+	}
+	leaf, err := wt.leafData(k, ph)
+	if err != nil {
+		return rv, nil, err
+	}
+	rv.payload, rv.index = wt.storedPayload(rv.payload), index
+	return rv, leaf, nil
+}
+
+// MustNewWildcardTree is like NewWildcardTree, but panics on error. It is
+// intended for callers that know that every value is a [][]byte.
+func MustNewWildcardTree(twc []byte, h func(data ...[]byte) []byte,
+	m map[string]interface{}, opts ...WildcardTreeOption) *WildcardTree {
+	wt, err := NewWildcardTree(twc, h, m, opts...)
+	if err != nil {
+		panic(err)
+	}
 	return wt
 }
 
 // Snapshot outputs the root hash of the underlying Merkle tree
 func (wt *WildcardTree) Snapshot() []byte {
-	return wt.mt.Mth()
+	if wt.snapshot == nil {
+		wt.snapshot = wt.mt.Mth()
+	}
+	return wt.snapshot
+}
+
+// SnapshotHex outputs the snapshot as a hex string, see ParseSnapshotHex
+func (wt *WildcardTree) SnapshotHex() string {
+	return hex.EncodeToString(wt.Snapshot())
+}
+
+// SnapshotBase64 outputs the snapshot as a standard base64 string, see
+// ParseSnapshotBase64
+func (wt *WildcardTree) SnapshotBase64() string {
+	return base64.StdEncoding.EncodeToString(wt.Snapshot())
+}
+
+// ParseSnapshotHex outputs the snapshot that a hex string encodes
+func ParseSnapshotHex(s string) ([]byte, error) {
+	snapshot, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// ParseSnapshotBase64 outputs the snapshot that a standard base64 string
+// encodes
+func ParseSnapshotBase64(s string) ([]byte, error) {
+	snapshot, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// Size outputs the number of leaves in the tree, which is the size that a
+// verifier should pass to Proof.Verify together with Snapshot()
+func (wt *WildcardTree) Size() int {
+	return len(wt.mt.data)
+}
+
+// TWC outputs the tree-wide constant
+func (wt *WildcardTree) TWC() []byte {
+	return wt.mt.twc
+}
+
+// IsEmpty outputs true if the tree has no leaves
+func (wt *WildcardTree) IsEmpty() bool {
+	return wt.Size() == 0
+}
+
+// Add inserts a new key-value pair into the tree, which means that leaves to
+// the right of key shift one index and that the Merkle tree is rebuilt. The
+// key must be in reversed order, and ErrKeyExists is returned if it is present.
+func (wt *WildcardTree) Add(key string, payload [][]byte) error {
+	key = wt.normalizeKey(key)
+	if err := wt.validateKey(key); err != nil {
+		return err
+	}
+	if _, ok := wt.r.Get(key); ok {
+		return ErrKeyExists
+	}
+	if err := wt.checkLimits(key, payload); err != nil {
+		return err
+	}
+	leaf, err := wt.leafData(key, wt.mt.hash(payload...))
+	if err != nil {
+		return err
+	}
+	index := sort.Search(len(wt.mt.data), func(i int) bool {
+		return wt.leafKey(wt.mt.data[i]) >= key
+	})
+
+	old := wt.oldSnapshot()
+	wt.shift(index, 1)
+	wt.r.Insert(key, radixValue{payload: wt.storedPayload(payload),
+		index: index})
+
+	data := make([][]byte, 0, len(wt.mt.data)+1)
+	data = append(data, wt.mt.data[:index]...)
+	data = append(data, leaf)
+	data = append(data, wt.mt.data[index:]...)
+	wt.mt.Release()
+	wt.snapshot = nil
+	wt.mt = NewMerkleTree(wt.mt.twc, wt.mt.leafPrefix, wt.mt.interiorPrefix,
+		wt.mt.hash, data)
+	wt.notify(EventAdded, key, old)
+	return nil
+}
+
+// Remove deletes a key-value pair from the tree, which means that leaves to
+// the right of key shift one index and that the Merkle tree is rebuilt.
+// ErrKeyNotFound is returned if key is not present.
+func (wt *WildcardTree) Remove(key string) error {
+	key = wt.normalizeKey(key)
+	if _, ok := wt.r.Get(key); !ok {
+		return ErrKeyNotFound
+	}
+	old := wt.oldSnapshot()
+	v, _ := wt.r.Delete(key)
+	index := v.(radixValue).index
+	wt.shift(index, -1)
+
+	data := make([][]byte, 0, len(wt.mt.data)-1)
+	data = append(data, wt.mt.data[:index]...)
+	data = append(data, wt.mt.data[index+1:]...)
+	wt.mt.Release()
+	wt.snapshot = nil
+	wt.mt = NewMerkleTree(wt.mt.twc, wt.mt.leafPrefix, wt.mt.interiorPrefix,
+		wt.mt.hash, data)
+	wt.notify(EventRemoved, key, old)
+	return nil
+}
+
+// Update replaces the payload of an existing key. Leaf indices are unchanged,
+// but the hash cache is invalidated. ErrKeyNotFound is returned if key is not
+// present.
+func (wt *WildcardTree) Update(key string, payload [][]byte) error {
+	key = wt.normalizeKey(key)
+	v, ok := wt.r.Get(key)
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if err := wt.checkLimits(key, payload); err != nil {
+		return err
+	}
+	leaf, err := wt.leafData(key, wt.mt.hash(payload...))
+	if err != nil {
+		return err
+	}
+	old := wt.oldSnapshot()
+	rv := v.(radixValue)
+	rv.payload, rv.mimeTypes = wt.storedPayload(payload), nil
+	wt.r.Insert(key, rv)
+	wt.mt.data[rv.index] = leaf
+	wt.mt.Release()
+	wt.snapshot = nil
+	wt.notify(EventUpdated, key, old)
+	return nil
+}
+
+// shift adds delta to the Merkle tree index of every leaf at index from or later
+func (wt *WildcardTree) shift(from, delta int) {
+	shifted := make(map[string]radixValue)
+	wt.r.WalkPrefix("", func(k string, v interface{}) bool {
+		if rv := v.(radixValue); rv.index >= from {
+			rv.index += delta
+			shifted[k] = rv
+		}
+		return false
+	})
+	for k, rv := range shifted {
+		wt.r.Insert(k, rv)
+	}
 }
 
 // Get outputs a verifiable wildcard answer for key
-func (wt *WildcardTree) Get(key string) (answer Answer, proof Proof) {
-	proof.hash = wt.mt.hash
-	proof.twc = wt.mt.twc
+func (wt *WildcardTree) Get(key string) (Answer, Proof) {
+	answer, proof, _ := wt.GetWithContext(context.Background(), key)
+	return answer, proof
+}
+
+// GetWithContext outputs a verifiable wildcard answer for key like Get, but
+// stops walking the matching keys if ctx is done. In that case ctx.Err() is
+// returned together with a partial answer and proof that must not be verified.
+func (wt *WildcardTree) GetWithContext(ctx context.Context,
+	key string) (answer Answer, proof Proof, err error) {
+	key = wt.normalizeKey(key)
+	wt.initProof(&proof)
 	proof.index = -1
 
 	// special case: empty tree
@@ -84,41 +369,84 @@ func (wt *WildcardTree) Get(key string) (answer Answer, proof Proof) {
 
 	// tree size > 0: find matches and first index
 	wt.r.WalkPrefix(key, func(subject string, value interface{}) bool {
+		if err = ctx.Err(); err != nil {
+			return true
+		}
 		data, ok := value.(radixValue)
 		if !ok {
 			panic("This should never happen")
 		}
-		answer.subject = append(answer.subject, subject)
-		answer.payload = append(answer.payload, data.payload)
+		answer.add(subject, data)
 		if proof.index < 0 {
 			proof.index = data.index
 		}
 		return false
 	})
+	if err != nil {
+		return
+	}
 
 	// if there's no match: make proof for the range where this key should be
 	if proof.index < 0 {
-		proof.index = sort.Search(len(wt.mt.data), func(i int) bool {
-			return mkKey(wt.mt.data[i]) >= key
-		})
-
-		if proof.index == len(wt.mt.data) { // value last -> need left proof
-			proof.index -= 1
-			proof.lap = wt.mt.Ap(proof.index)
-			proof.ll = wt.mt.data[proof.index]
-		} else if proof.index == 0 { // value first -> need right proof
-			proof.rap = wt.mt.Ap(proof.index)
-			proof.rl = wt.mt.data[proof.index]
-		} else { // value in between, need both proofs
-			proof.index -= 1
-			proof.lap, proof.rap = wt.mt.Ap(proof.index), wt.mt.Ap(proof.index+1)
-			proof.ll, proof.rl = wt.mt.data[proof.index], wt.mt.data[proof.index+1]
-		}
+		wt.absenceProof(&proof, key)
 		return
 	}
 
 	// if there's at least one match: make range proof
-	if rindex := proof.index + len(answer.subject); rindex < len(wt.mt.data) {
+	wt.rangeProof(&proof, proof.index, len(answer.subject))
+	return
+}
+
+// GetExact outputs a verifiable answer that only contains key, i.e., without
+// any wildcard expansion. If key is not in the tree, false is returned together
+// with an empty answer and a proof of non-membership.
+func (wt *WildcardTree) GetExact(key string) (answer Answer, proof Proof,
+	ok bool) {
+	key = wt.normalizeKey(key)
+	wt.initProof(&proof)
+	proof.index = -1
+
+	// special case: empty tree
+	if len(wt.mt.data) == 0 {
+		return
+	}
+
+	v, ok := wt.r.Get(key)
+	if !ok {
+		wt.absenceProof(&proof, key)
+		return
+	}
+	rv := v.(radixValue)
+	answer.add(key, rv)
+	wt.rangeProof(&proof, rv.index, 1)
+	return
+}
+
+// absenceProof makes a proof for the range where key should be in a non-empty
+// tree
+func (wt *WildcardTree) absenceProof(proof *Proof, key string) {
+	proof.index = sort.Search(len(wt.mt.data), func(i int) bool {
+		return wt.leafKey(wt.mt.data[i]) >= key
+	})
+
+	if proof.index == len(wt.mt.data) { // value last -> need left proof
+		proof.index -= 1
+		proof.lap = wt.mt.Ap(proof.index)
+		proof.ll = wt.mt.data[proof.index]
+	} else if proof.index == 0 { // value first -> need right proof
+		proof.rap = wt.mt.Ap(proof.index)
+		proof.rl = wt.mt.data[proof.index]
+	} else { // value in between, need both proofs
+		proof.index -= 1
+		proof.lap, proof.rap = wt.mt.Ap(proof.index), wt.mt.Ap(proof.index+1)
+		proof.ll, proof.rl = wt.mt.data[proof.index], wt.mt.data[proof.index+1]
+	}
+}
+
+// rangeProof makes a proof for n consecutive leaves that start at index
+func (wt *WildcardTree) rangeProof(proof *Proof, index, n int) {
+	proof.index = index
+	if rindex := index + n; rindex < len(wt.mt.data) {
 		proof.rap = wt.mt.Ap(rindex)
 		proof.rl = wt.mt.data[rindex]
 	}
@@ -127,29 +455,373 @@ func (wt *WildcardTree) Get(key string) (answer Answer, proof Proof) {
 		proof.lap = wt.mt.Ap(proof.index)
 		proof.ll = wt.mt.data[proof.index]
 	}
+}
+
+// Contains outputs true if there is at least one match for the wildcard key.
+// No proof is generated, and the hash cache is not accessed, which means that
+// it is safe to call concurrently with other read-only lookups.
+func (wt *WildcardTree) Contains(key string) (found bool) {
+	key = wt.normalizeKey(key)
+	wt.r.WalkPrefix(key, func(string, interface{}) bool {
+		found = true
+		return true
+	})
+	return
+}
+
+// PayloadOf outputs the payload of key without prefix expansion, and false if
+// key is not in the tree. No proof is generated, and like Contains it is safe
+// to call concurrently with other read-only lookups. The payload is shared
+// with the tree and must not be modified.
+func (wt *WildcardTree) PayloadOf(key string) ([][]byte, bool) {
+	v, ok := wt.r.Get(wt.normalizeKey(key))
+	if !ok {
+		return nil, false
+	}
+	return v.(radixValue).payload, true
+}
+
+// PayloadsWithPrefix outputs the payloads of all matches for the wildcard key
+// prefix. Like PayloadOf, no proof is generated and payloads are shared.
+func (wt *WildcardTree) PayloadsWithPrefix(prefix string) map[string][][]byte {
+	m := make(map[string][][]byte)
+	wt.r.WalkPrefix(wt.normalizeKey(prefix), func(key string,
+		v interface{}) bool {
+		m[key] = v.(radixValue).payload
+		return false
+	})
+	return m
+}
+
+// CountPrefix outputs the number of matches for the wildcard key prefix. No
+// answer or proof is generated: matches are consecutive leaves, which means
+// that they can be counted by binary search in O(log N) time. Like Contains,
+// it is safe to call concurrently with other read-only lookups.
+func (wt *WildcardTree) CountPrefix(prefix string) int {
+	prefix = wt.normalizeKey(prefix)
+	lo := sort.Search(len(wt.mt.data), func(i int) bool {
+		return wt.leafKey(wt.mt.data[i]) >= prefix
+	})
+	hi := lo + sort.Search(len(wt.mt.data)-lo, func(i int) bool {
+		return !strings.HasPrefix(wt.leafKey(wt.mt.data[lo+i]), prefix)
+	})
+	return hi - lo
+}
+
+// ContainsExact outputs true if key is in the tree, without prefix expansion.
+// Like Contains, it is safe to call concurrently with other read-only lookups.
+func (wt *WildcardTree) ContainsExact(key string) bool {
+	key = wt.normalizeKey(key)
+	_, ok := wt.r.Get(key)
+	return ok
+}
+
+// Entry is a key-value pair in a WildcardTree
+type Entry struct {
+	Key     string
+	Payload [][]byte
+}
+
+// ForEach calls fn for every key-value pair in radix order, which is also the
+// order of leaves in the Merkle tree. Iteration stops if fn returns false.
+func (wt *WildcardTree) ForEach(fn func(key string, payload [][]byte) bool) {
+	wt.r.WalkPrefix("", func(key string, value interface{}) bool {
+		return !fn(key, value.(radixValue).payload)
+	})
+}
+
+// Walk calls fn for every key-value pair that matches the wildcard prefix in
+// radix order, like ForEach. Iteration stops if fn returns false, in which
+// case nil is returned, or if ctx is done, in which case ctx.Err() is returned.
+func (wt *WildcardTree) Walk(ctx context.Context, prefix string,
+	fn func(key string, payload [][]byte) bool) (err error) {
+	wt.r.WalkPrefix(wt.normalizeKey(prefix), func(key string,
+		value interface{}) bool {
+		if err = ctx.Err(); err != nil {
+			return true
+		}
+		return !fn(key, value.(radixValue).payload)
+	})
 	return
 }
 
-// Verify outputs true if answer is valid for key, proof, size, and snapshot
-func (p Proof) Verify(key string, a Answer, size int, snapshot []byte) bool {
+// Keys outputs all keys in radix order
+func (wt *WildcardTree) Keys() []string {
+	keys := make([]string, 0, wt.Size())
+	wt.ForEach(func(key string, _ [][]byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// Entries outputs all key-value pairs in radix order
+func (wt *WildcardTree) Entries() []Entry {
+	entries := make([]Entry, 0, wt.Size())
+	wt.ForEach(func(key string, payload [][]byte) bool {
+		entries = append(entries, Entry{Key: key, Payload: payload})
+		return true
+	})
+	return entries
+}
+
+// All streams all key-value pairs in radix order. The channel is closed after
+// the last entry, or early if ctx is done. The tree must not be modified until
+// the channel is closed.
+func (wt *WildcardTree) All(ctx context.Context) <-chan Entry {
+	ch := make(chan Entry)
+	go func() {
+		defer close(ch)
+		wt.ForEach(func(key string, payload [][]byte) bool {
+			if ctx.Err() != nil {
+				return false
+			}
+			select {
+			case ch <- Entry{Key: key, Payload: payload}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return ch
+}
+
+// Verify outputs nil if answer is valid for key, proof, size, and snapshot.
+// Otherwise a *VerificationError is returned that describes what went wrong.
+func (p Proof) Verify(key string, a Answer, size int, snapshot []byte) error {
+	lp, ip := p.prefixes()
+	mt := NewMerkleTree(p.twc, lp, ip, p.hashFunc(), nil)
+	return p.verify(mt, key, a, size, snapshot)
+}
+
+// verify is like Verify, but uses an existing Merkle tree without data to
+// recompute the root hash
+func (p Proof) verify(mt *MerkleTree, key string, a Answer, size int,
+	snapshot []byte) error {
+	snapshotp, err := p.recompute(mt, key, a, size)
+	if err != nil {
+		return err
+	}
+	// constant time, since snapshot may be secret if it is not yet published
+	if subtle.ConstantTimeCompare(snapshot, snapshotp) != 1 {
+		return verificationError(KindRootMismatch, "expected snapshot %x, got %x",
+			snapshot, snapshotp)
+	}
+	return nil
+}
+
+// recompute checks that the proof is well-formed for key and answer, and then
+// outputs the root hash of a tree of a given size using mt
+func (p Proof) recompute(mt *MerkleTree, key string, a Answer,
+	size int) ([]byte, error) {
+	if p.hash == nil {
+		return nil, verificationError(KindMalformedData, "missing hash function")
+	}
 	lindex, rindex := indices(&p, &a)
+	// check that the range of leaves fits in the tree
+	if size < 0 || (size == 0 && lindex >= 0) ||
+		(size > 0 && (lindex < 0 || rindex >= size)) {
+		return nil, verificationError(KindTreeSizeMismatch,
+			"leaves [%d,%d] do not fit in a tree of size %d", lindex, rindex, size)
+	}
 	// check that ends are provided if expected
-	if (p.ll == nil && lindex > 0) || (p.rl == nil && rindex+1 < size) {
-		return false
+	if p.ll == nil && lindex > 0 {
+		return nil, verificationError(KindMissingLeftBound, "expected left leaf")
+	}
+	if p.rl == nil && rindex+1 < size {
+		return nil, verificationError(KindMissingRightBound, "expected right leaf")
+	}
+	// check that ends and audit paths are well-formed
+	if (p.ll != nil && len(p.ll) < p.nonceLength+p.hashLen()) ||
+		(p.rl != nil && len(p.rl) < p.nonceLength+p.hashLen()) {
+		return nil, verificationError(KindMalformedData,
+			"leaf data is too short")
+	}
+	if n := len(p.hashFunc()()); !hashLengths(p.lap, n) ||
+		!hashLengths(p.rap, n) {
+		return nil, verificationError(KindMalformedData,
+			"audit path contains a bad hash length")
 	}
 	// check that ends are valid for key
-	if (p.ll != nil && key < mkKey(p.ll)) || (p.rl != nil && key > mkKey(p.rl)) {
-		return false
+	if p.ll != nil && key < p.leafKey(p.ll) {
+		return nil, verificationError(KindLeafOrder,
+			"left leaf %q is after key %q", p.leafKey(p.ll), key)
+	}
+	if p.rl != nil && key > p.leafKey(p.rl) {
+		return nil, verificationError(KindLeafOrder,
+			"right leaf %q is before key %q", p.leafKey(p.rl), key)
 	}
 	// check that leaf data is ordered
-	data, ok := mkLeafData(&p, &a)
-	if !ok {
-		return false
+	data, err := mkLeafData(&p, &a)
+	if err != nil {
+		return nil, err
 	}
 	// check that leaf data is valid for Merkle tree (size+location+snapshot)
-	mt := NewMerkleTree(p.twc, leafPrefix, interiorPrefix, p.hash, nil)
 	snapshotp, err := mt.MthFromRangeAp(data, lindex, size, p.lap, p.rap)
-	return err == nil && bytes.Equal(snapshot, snapshotp)
+	if err != nil {
+		return nil, verificationError(KindMalformedData, "%v", err)
+	}
+	return snapshotp, nil
+}
+
+// Subjects outputs the matching subject names in radix order
+func (a Answer) Subjects() []string {
+	return a.subject
+}
+
+// Payloads outputs the payloads associated with each subject name
+func (a Answer) Payloads() [][][]byte {
+	return a.payload
+}
+
+// Merge outputs an answer that contains the matches of a followed by those of
+// b. An error is returned unless every subject in a is before those in b.
+func (a Answer) Merge(b Answer) (Answer, error) {
+	if n := len(a.subject); n > 0 && len(b.subject) > 0 &&
+		a.subject[n-1] >= b.subject[0] {
+		return Answer{}, fmt.Errorf("subject %q is not before %q",
+			a.subject[n-1], b.subject[0])
+	}
+	var merged Answer
+	merged.subject = append(append(merged.subject, a.subject...), b.subject...)
+	merged.payload = append(append(merged.payload, a.payload...), b.payload...)
+	if a.mimeType != nil || b.mimeType != nil {
+		merged.mimeType = append(append([][]string{}, a.mimeTypes()...),
+			b.mimeTypes()...)
+	}
+	return merged, nil
+}
+
+// Slice outputs the matches in [start, end), panicking on bad bounds like a
+// regular slice expression
+func (a Answer) Slice(start, end int) Answer {
+	sliced := Answer{subject: a.subject[start:end], payload: a.payload[start:end]}
+	if a.mimeType != nil {
+		sliced.mimeType = a.mimeType[start:end]
+	}
+	return sliced
+}
+
+// Validate outputs an error if the answer is inconsistent, e.g., after being
+// decoded from an untrusted source. Subjects must be non-empty and strictly
+// increasing, and each subject must have a non-nil payload without nil items.
+// A valid answer may still fail verification.
+func (a Answer) Validate() error {
+	if len(a.subject) != len(a.payload) {
+		return fmt.Errorf("got %d subjects but %d payloads", len(a.subject),
+			len(a.payload))
+	}
+	for i, subject := range a.subject {
+		if subject == "" {
+			return fmt.Errorf("subject %d is empty", i)
+		}
+		if i > 0 && a.subject[i-1] >= subject {
+			return fmt.Errorf("subject %q is not before %q", a.subject[i-1],
+				subject)
+		}
+		if a.payload[i] == nil {
+			return fmt.Errorf("payload of subject %q is nil", subject)
+		}
+		for j, item := range a.payload[i] {
+			if item == nil {
+				return fmt.Errorf("payload item %d of subject %q is nil", j, subject)
+			}
+		}
+	}
+	return nil
+}
+
+// TWC outputs the tree-wide constant
+func (p Proof) TWC() []byte {
+	return p.twc
+}
+
+// Index outputs the left-most Merkle tree index covered by the proof, or -1 if
+// the tree is empty
+func (p Proof) Index() int {
+	return p.index
+}
+
+// LeftLeaf outputs the leaf data that bounds the answer from the left (if any)
+func (p Proof) LeftLeaf() []byte {
+	return p.ll
+}
+
+// RightLeaf outputs the leaf data that bounds the answer from the right (if any)
+func (p Proof) RightLeaf() []byte {
+	return p.rl
+}
+
+// LeftAP outputs the audit path for the left-most leaf in the range (if any)
+func (p Proof) LeftAP() [][]byte {
+	return p.lap
+}
+
+// RightAP outputs the audit path for the right-most leaf in the range (if any)
+func (p Proof) RightAP() [][]byte {
+	return p.rap
+}
+
+// Size outputs the approximate size of the proof's binary encoding in bytes,
+// which is exact up to the 2-byte length of each component
+func (p Proof) Size() int {
+	n := len(p.twc) + len(p.ll) + len(p.rl) + 8 // 8 bytes for metadata
+	for _, h := range p.lap {
+		n += len(h)
+	}
+	for _, h := range p.rap {
+		n += len(h)
+	}
+	return n
+}
+
+// AuditPathLen outputs the total number of audit path hashes
+func (p Proof) AuditPathLen() int {
+	return len(p.lap) + len(p.rap)
+}
+
+// LeafPrefix outputs the prefix that separates leaf hashes from interior hashes
+func (p Proof) LeafPrefix() []byte {
+	lp, _ := p.prefixes()
+	return lp
+}
+
+// InteriorPrefix outputs the prefix that separates interior hashes from leaf
+// hashes
+func (p Proof) InteriorPrefix() []byte {
+	_, ip := p.prefixes()
+	return ip
+}
+
+// prefixes outputs the leaf and interior prefixes, where decoded proofs use
+// the defaults
+func (p Proof) prefixes() (lp, ip []byte) {
+	lp, ip = p.leafPrefix, p.interiorPrefix
+	if lp == nil {
+		lp = leafPrefix
+	}
+	if ip == nil {
+		ip = interiorPrefix
+	}
+	return
+}
+
+// defaultPrefixes outputs true if the proof uses the default prefixes, which
+// is required by the proof encodings
+func (p Proof) defaultPrefixes() bool {
+	lp, ip := p.prefixes()
+	return bytes.Equal(lp, leafPrefix) && bytes.Equal(ip, interiorPrefix)
+}
+
+// initProof sets the parameters of a proof that are shared by all proofs
+func (wt *WildcardTree) initProof(proof *Proof) {
+	proof.hash = wt.hash()
+	proof.hashLength = wt.hashLength
+	proof.nonceLength = wt.nonceLength
+	proof.twc = wt.mt.twc
+	proof.leafPrefix = wt.mt.leafPrefix
+	proof.interiorPrefix = wt.mt.interiorPrefix
 }
 
 // indices returns the {left,right} inclusive range for a proof and an answer
@@ -167,44 +839,89 @@ func indices(p *Proof, a *Answer) (lindex, rindex int) {
 }
 
 // mkLeafData makes a consecutive range of leaf data from a proof and an answer
-func mkLeafData(p *Proof, a *Answer) ([][]byte, bool) {
+func mkLeafData(p *Proof, a *Answer) ([][]byte, error) {
 	n := len(a.subject)
 	if n != len(a.payload) {
-		return nil, false
+		return nil, verificationError(KindMalformedData,
+			"subject and payload lengths differ")
 	}
 
 	// left side
 	var d [][]byte
 	if p.ll != nil {
 		d = append(d, p.ll)
-		if n > 0 && mkKey(p.ll) > a.subject[0] {
-			return nil, false // bad leaf order
+		if n > 0 && p.leafKey(p.ll) > a.subject[0] {
+			return nil, verificationError(KindLeafOrder,
+				"left leaf %q is after subject %q", p.leafKey(p.ll), a.subject[0])
 		}
 	}
 
 	// actual range
 	for i := 0; i < n; i++ {
 		if i > 0 && a.subject[i-1] >= a.subject[i] {
-			return nil, false // bad leaf order
+			return nil, verificationError(KindLeafOrder,
+				"subject %q is not before %q", a.subject[i-1], a.subject[i])
+		}
+		leaf, err := p.leafData(a.subject[i], p.hashFunc()(a.payload[i]...))
+		if err != nil {
+			return nil, err
 		}
-		d = append(d, append([]byte(a.subject[i]), p.hash(a.payload[i]...)...))
+		d = append(d, leaf)
 	}
 
 	// right side
 	if p.rl != nil {
-		if n > 0 && mkKey(p.rl) < a.subject[n-1] {
-			return nil, false // bad leaf order
+		if n > 0 && p.leafKey(p.rl) < a.subject[n-1] {
+			return nil, verificationError(KindLeafOrder,
+				"right leaf %q is before subject %q", p.leafKey(p.rl), a.subject[n-1])
 		}
 		d = append(d, p.rl)
 	}
 
-	return d, true
+	return d, nil
 }
 
-// mkKey outputs the key of a leaf's data
-func mkKey(data []byte) string {
-	if n := len(data); n >= hashLen {
-		return string(data[:n-hashLen])
+// hashLengths outputs true if every hash in ap has length n
+func hashLengths(ap [][]byte, n int) bool {
+	for _, h := range ap {
+		if len(h) != n {
+			return false
+		}
+	}
+	return true
+}
+
+// LeafKey outputs the key of a leaf's data, e.g., as returned by
+// Proof.LeftLeaf, and false if data is too short to be leaf data
+func LeafKey(data []byte) (string, bool) {
+	return leafKey(data, 0, hashLen)
+}
+
+// leafKey is like LeafKey for leaf nonces of length m and payload hashes of
+// length n
+func leafKey(data []byte, m, n int) (string, bool) {
+	if len(data) >= m+n {
+		return string(data[m : len(data)-n]), true
+	}
+	return "", false
+}
+
+// LeafHash outputs the payload hash of a leaf's data, and false if data is too
+// short to be leaf data. The returned hash shares memory with data.
+func LeafHash(data []byte) ([]byte, bool) {
+	return leafHash(data, hashLen)
+}
+
+// leafHash is like LeafHash for payload hashes of length n
+func leafHash(data []byte, n int) ([]byte, bool) {
+	if len(data) >= n {
+		return data[len(data)-n:], true
 	}
-	return "" // invalid data
+	return nil, false
+}
+
+// mkKey outputs the key of a leaf's data, or an empty key if data is invalid
+func mkKey(data []byte) string {
+	key, _ := LeafKey(data)
+	return key
 }
@@ -2,8 +2,17 @@ package lwm
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/golang/example/stringutil"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/quick"
+	"time"
 )
 
 var (
@@ -11,7 +20,7 @@ var (
 )
 
 func TestRadix(t *testing.T) {
-	wt := NewWildcardTree(twc, hash, testData())
+	wt := MustNewWildcardTree(twc, hash, testData())
 
 	// check in-order traversal (should be sorted)
 	last := ""
@@ -71,7 +80,7 @@ type wtExpect struct {
 func TestWildcardTree(t *testing.T) {
 	// size == 0
 	var m map[string]interface{} = nil
-	wt := NewWildcardTree(twc, hash, m)
+	wt := MustNewWildcardTree(twc, hash, m)
 	snapshot := wt.Snapshot()
 	for _, table := range []wtExpect{
 		{"a", -1, 0, false, false},
@@ -84,7 +93,7 @@ func TestWildcardTree(t *testing.T) {
 	// size == 1
 	m = make(map[string]interface{})
 	m["b"] = [][]byte{[]byte("b cert")}
-	wt = NewWildcardTree(twc, hash, m)
+	wt = MustNewWildcardTree(twc, hash, m)
 	snapshot = wt.Snapshot()
 	for _, table := range []wtExpect{
 		{"a", 0, 0, false, true},
@@ -100,7 +109,7 @@ func TestWildcardTree(t *testing.T) {
 
 	// size > 1
 	m = testData()
-	wt = NewWildcardTree(twc, hash, m)
+	wt = MustNewWildcardTree(twc, hash, m)
 	snapshot = wt.Snapshot()
 	for _, table := range []wtExpect{
 		{stringutil.Reverse("foo.com"), 1, 3, true, true},
@@ -115,6 +124,31 @@ func TestWildcardTree(t *testing.T) {
 	}
 }
 
+func TestNewWildcardTree(t *testing.T) {
+	m := testData()
+	wt, err := NewWildcardTree(twc, hash, m)
+	if err != nil {
+		t.Fatalf("valid map rejected: %v", err)
+	}
+	if got, want := wt.Size(), len(m); got != want {
+		t.Errorf("size => got %v, want %v", got, want)
+	}
+
+	m["moc.rab"] = []byte("not a [][]byte")
+	if _, err := NewWildcardTree(twc, hash, m); err == nil {
+		t.Errorf("invalid value accepted")
+	} else if !strings.Contains(err.Error(), "moc.rab") ||
+		!strings.Contains(err.Error(), "[]uint8") {
+		t.Errorf("error does not name key and type: %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustNewWildcardTree did not panic on invalid value")
+		}
+	}()
+	MustNewWildcardTree(twc, hash, m)
+}
+
 func wildcardTests(t *testing.T, table wtExpect, answer Answer, proof Proof,
 	size int, snapshot []byte) {
 	// answer
@@ -147,9 +181,691 @@ func wildcardTests(t *testing.T, table wtExpect, answer Answer, proof Proof,
 		t.Errorf("expected right leaf but got none")
 	}
 	// range proof
-	if !proof.Verify(table.key, answer, size, snapshot) {
-		t.Errorf("Valid proof rejected for key %v and answer %v: ", table.key,
-			answer.subject)
+	if err := proof.Verify(table.key, answer, size, snapshot); err != nil {
+		t.Errorf("Valid proof rejected for key %v and answer %v: %v", table.key,
+			answer.subject, err)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	wt.Snapshot()
+	answer, proof := wt.Get(stringutil.Reverse("foo.com"))
+	if got, want := len(answer.Subjects()), 3; got != want {
+		t.Errorf("subjects => got %v, want %v", got, want)
+	}
+	if got, want := len(answer.Payloads()), 3; got != want {
+		t.Errorf("payloads => got %v, want %v", got, want)
+	}
+	if !bytes.Equal(proof.TWC(), twc) {
+		t.Errorf("twc => got %v, want %v", proof.TWC(), twc)
+	}
+	if got, want := proof.Index(), 1; got != want {
+		t.Errorf("index => got %v, want %v", got, want)
+	}
+	if !bytes.Equal(proof.LeftLeaf(), proof.ll) ||
+		!bytes.Equal(proof.RightLeaf(), proof.rl) {
+		t.Errorf("leaf accessors do not match proof")
+	}
+	if len(proof.LeftAP()) != len(proof.lap) ||
+		len(proof.RightAP()) != len(proof.rap) {
+		t.Errorf("audit path accessors do not match proof")
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	snapshot := wt.Snapshot()
+	key := stringutil.Reverse("foo.com")
+	for _, table := range []struct {
+		desc   string
+		kind   VerificationErrorKind
+		mutate func(*string, *Answer, *Proof, *int, *[]byte)
+	}{
+		{"missing left leaf", KindMissingLeftBound,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) { p.ll = nil }},
+		{"missing right leaf", KindMissingRightBound,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) { p.rl = nil }},
+		{"key before left leaf", KindLeafOrder,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) { *k = "a" }},
+		{"key after right leaf", KindLeafOrder,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) { *k = "zzz" }},
+		{"subject before left leaf", KindLeafOrder,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				a.subject[0] = "a"
+			}},
+		{"subject after right leaf", KindLeafOrder,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				a.subject[len(a.subject)-1] = "zzz"
+			}},
+		{"unordered subjects", KindLeafOrder,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				a.subject[1], a.subject[2] = a.subject[2], a.subject[1]
+			}},
+		{"payload length mismatch", KindMalformedData,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				a.payload = a.payload[1:]
+			}},
+		{"short left leaf", KindMalformedData,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				p.ll = p.ll[:hashLen-1]
+			}},
+		{"bad audit path hash length", KindMalformedData,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				p.rap[0] = p.rap[0][1:]
+			}},
+		{"tree too small", KindTreeSizeMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) { *n = 2 }},
+		{"negative tree size", KindTreeSizeMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) { *n = -1 }},
+		{"missing hash function", KindMalformedData,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) { p.hash = nil }},
+		{"other hash function", KindRootMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				p.hash = HashFunctions["blake2b-256"]
+			}},
+		{"other twc", KindRootMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				p.twc = []byte("other twc")
+			}},
+		{"index shifted right", KindRootMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) { p.index++ }},
+		{"index shifted out of tree", KindTreeSizeMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				p.index = *n
+			}},
+		{"negative index", KindTreeSizeMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) { p.index = -1 }},
+		{"corrupted left leaf", KindRootMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				p.ll = append([]byte{}, p.ll...)
+				p.ll[len(p.ll)-1] ^= 1
+			}},
+		{"corrupted right leaf", KindRootMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				p.rl = append([]byte{}, p.rl...)
+				p.rl[len(p.rl)-1] ^= 1
+			}},
+		{"corrupted left audit path", KindRootMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				p.lap = append([][]byte{}, p.lap...)
+				p.lap[0] = hash([]byte("bad hash"))
+			}},
+		{"corrupted right audit path", KindRootMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				p.rap = append([][]byte{}, p.rap...)
+				for i := range p.rap {
+					p.rap[i] = hash([]byte("bad hash"))
+				}
+			}},
+		{"bad snapshot", KindRootMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				*s = hash([]byte("bad snapshot"))
+			}},
+		{"bad payload", KindRootMismatch,
+			func(k *string, a *Answer, p *Proof, n *int, s *[]byte) {
+				a.payload[0] = [][]byte{[]byte("bad payload")}
+			}},
+	} {
+		k, size, snap := key, len(m), snapshot
+		answer, proof := wt.Get(key)
+		table.mutate(&k, &answer, &proof, &size, &snap)
+
+		err := proof.Verify(k, answer, size, snap)
+		var verr *VerificationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s => got %v, want verification error", table.desc, err)
+			continue
+		}
+		if verr.Kind != table.kind {
+			t.Errorf("%s => got kind %v, want %v", table.desc, verr.Kind, table.kind)
+		}
+		if verr.Detail == "" {
+			t.Errorf("%s => missing detail", table.desc)
+		}
+	}
+
+	// details describe the mismatching hashes
+	answer, proof := wt.Get(key)
+	bad := hash([]byte("bad snapshot"))
+	err := proof.Verify(key, answer, len(m), bad)
+	if verr := (*VerificationError)(nil); !errors.As(err, &verr) ||
+		!strings.Contains(verr.Detail, fmt.Sprintf("%x", bad)) ||
+		!strings.Contains(verr.Detail, fmt.Sprintf("%x", snapshot)) {
+		t.Errorf("root mismatch => got detail %v", err)
+	}
+}
+
+func TestVerifySnapshotLastByte(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	for _, key := range []string{"foo.com", "bar.se", ""} {
+		key = stringutil.Reverse(key)
+		answer, proof := wt.Get(key)
+		snapshot := append([]byte{}, wt.Snapshot()...)
+		snapshot[len(snapshot)-1] ^= 1
+		err := proof.Verify(key, answer, wt.Size(), snapshot)
+		if verr := (*VerificationError)(nil); !errors.As(err, &verr) ||
+			verr.Kind != KindRootMismatch {
+			t.Errorf("key %q => got %v, want root mismatch", key, err)
+		}
+	}
+	empty := MustNewWildcardTree(twc, hash, nil)
+	answer, proof := empty.Get("")
+	snapshot := append([]byte{}, empty.Snapshot()...)
+	snapshot[len(snapshot)-1] ^= 1
+	if err := proof.Verify("", answer, 0, snapshot); err == nil {
+		t.Errorf("empty tree => accepted bad snapshot")
+	}
+}
+
+func TestAnswerValidate(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	for _, name := range []string{"", "com", "foo.com", "sub1.foo.com",
+		"bar.se", "zzz"} {
+		if answer, _ := wt.Get(stringutil.Reverse(name)); answer.Validate() != nil {
+			t.Errorf("%q => got error %v", name, answer.Validate())
+		}
+	}
+
+	p := [][]byte{[]byte("cert")}
+	for _, table := range []struct {
+		desc   string
+		answer Answer
+	}{
+		{"length mismatch", Answer{[]string{"a", "b"}, [][][]byte{p}, nil}},
+		{"unordered subjects", Answer{[]string{"b", "a"}, [][][]byte{p, p}, nil}},
+		{"repeated subject", Answer{[]string{"a", "a"}, [][][]byte{p, p}, nil}},
+		{"empty subject", Answer{[]string{""}, [][][]byte{p}, nil}},
+		{"nil payload", Answer{[]string{"a", "b"}, [][][]byte{p, nil}, nil}},
+		{"nil payload item", Answer{[]string{"a"},
+			[][][]byte{{[]byte("cert"), nil}}, nil}},
+	} {
+		if err := table.answer.Validate(); err == nil {
+			t.Errorf("%s => accepted", table.desc)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	m := testData()
+	want := MustNewWildcardTree(twc, hash, m)
+	var keys []string
+	want.r.WalkPrefix("", func(k string, v interface{}) bool {
+		keys = append(keys, k)
+		return false
+	})
+
+	for _, table := range []struct {
+		desc  string
+		order []string
+	}{
+		{"in order", keys},
+		{"reverse order", []string{keys[6], keys[5], keys[4], keys[3], keys[2],
+			keys[1], keys[0]}},
+		{"interleaved", []string{keys[3], keys[0], keys[6], keys[1], keys[5],
+			keys[2], keys[4]}},
+	} {
+		wt := MustNewWildcardTree(twc, hash, nil)
+		for _, k := range table.order {
+			if err := wt.Add(k, m[k].([][]byte)); err != nil {
+				t.Errorf("%s => add %v failed: %v", table.desc, k, err)
+			}
+		}
+		if got, want := wt.Snapshot(), want.Snapshot(); !bytes.Equal(got, want) {
+			t.Errorf("%s => got snapshot %x, want %x", table.desc, got, want)
+		}
+		for i, k := range keys {
+			if v, _ := wt.r.Get(k); v.(radixValue).index != i {
+				t.Errorf("%s => got index %v for %v, want %v", table.desc,
+					v.(radixValue).index, k, i)
+			}
+		}
+		if err := wt.Add(keys[0], nil); err != ErrKeyExists {
+			t.Errorf("%s => got %v for existing key, want %v", table.desc, err,
+				ErrKeyExists)
+		}
+
+		// proofs must verify without a prior call to Snapshot()
+		wt.Add("zzz", [][]byte{[]byte("zzz cert")})
+		answer, proof := wt.Get("moc")
+		if err := proof.Verify("moc", answer, len(m)+1, wt.Snapshot()); err != nil {
+			t.Errorf("%s => valid proof rejected after add: %v", table.desc, err)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	key := stringutil.Reverse("sub1.foo.com")
+	oldSnapshot := wt.Snapshot()
+	oldAnswer, oldProof := wt.Get(key)
+
+	if err := wt.Remove(key); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if err := wt.Remove(key); err != ErrKeyNotFound {
+		t.Errorf("got %v for removed key, want %v", err, ErrKeyNotFound)
+	}
+	delete(m, key)
+	if got, want := wt.Snapshot(), MustNewWildcardTree(twc, hash, m).Snapshot(); !bytes.Equal(got, want) {
+		t.Errorf("got snapshot %x, want %x", got, want)
+	}
+
+	// the removed key must be excluded from new proofs
+	answer, proof := wt.Get(key)
+	if len(answer.subject) != 0 {
+		t.Errorf("got subjects %v for removed key", answer.subject)
+	}
+	if err := proof.Verify(key, answer, len(m), wt.Snapshot()); err != nil {
+		t.Errorf("valid non-membership proof rejected: %v", err)
+	}
+	wildcard := stringutil.Reverse("foo.com")
+	answer, proof = wt.Get(wildcard)
+	if len(answer.subject) != 2 {
+		t.Errorf("got subjects %v, want two", answer.subject)
+	}
+	if err := proof.Verify(wildcard, answer, len(m), wt.Snapshot()); err != nil {
+		t.Errorf("valid proof rejected: %v", err)
+	}
+
+	// old proofs must not verify against the new snapshot
+	if err := oldProof.Verify(key, oldAnswer, len(m), wt.Snapshot()); err == nil {
+		t.Errorf("stale proof accepted")
+	}
+	if err := oldProof.Verify(key, oldAnswer, len(m)+1, oldSnapshot); err != nil {
+		t.Errorf("stale proof rejected for old snapshot: %v", err)
+	}
+
+	// an emptied tree has the empty-tree root
+	for k := range m {
+		if err := wt.Remove(k); err != nil {
+			t.Errorf("remove %v failed: %v", k, err)
+		}
+	}
+	if got, want := wt.Snapshot(), hash(twc); !bytes.Equal(got, want) {
+		t.Errorf("got empty snapshot %x, want %x", got, want)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	key := stringutil.Reverse("sub2.foo.com")
+	old := wt.Snapshot()
+	v, _ := wt.r.Get(key)
+	index := v.(radixValue).index
+
+	payload := [][]byte{[]byte("sub2.foo.com cert (rotated)")}
+	if err := wt.Update(key, payload); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if err := wt.Update("zzz", payload); err != ErrKeyNotFound {
+		t.Errorf("got %v for missing key, want %v", err, ErrKeyNotFound)
+	}
+	snapshot := wt.Snapshot()
+	if bytes.Equal(old, snapshot) {
+		t.Errorf("snapshot unchanged after update")
+	}
+	m[key] = payload
+	if want := MustNewWildcardTree(twc, hash, m).Snapshot(); !bytes.Equal(snapshot, want) {
+		t.Errorf("got snapshot %x, want %x", snapshot, want)
+	}
+	if v, _ := wt.r.Get(key); v.(radixValue).index != index {
+		t.Errorf("got index %v, want %v", v.(radixValue).index, index)
+	}
+
+	answer, proof := wt.Get(key)
+	if len(answer.payload) != 1 || !bytes.Equal(answer.payload[0][0], payload[0]) {
+		t.Errorf("got payload %v, want %v", answer.payload, payload)
+	}
+	if err := proof.Verify(key, answer, len(m), snapshot); err != nil {
+		t.Errorf("valid proof rejected after update: %v", err)
+	}
+}
+
+func TestSize(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, nil)
+	if !wt.IsEmpty() || wt.Size() != 0 {
+		t.Errorf("got size %v for empty tree", wt.Size())
+	}
+	wt.Add("a", [][]byte{[]byte("a cert")})
+	if wt.IsEmpty() || wt.Size() != 1 {
+		t.Errorf("got size %v after add, want 1", wt.Size())
+	}
+	if wt = MustNewWildcardTree(twc, hash, testData()); wt.Size() != len(testData()) {
+		t.Errorf("got size %v, want %v", wt.Size(), len(testData()))
+	}
+}
+
+func TestGetWithContext(t *testing.T) {
+	m := make(map[string]interface{})
+	for i := 0; i < 10000; i++ {
+		m[fmt.Sprintf("moc.oof.%05d", i)] = [][]byte{[]byte("cert")}
+	}
+	wt := MustNewWildcardTree(twc, hash, m)
+	snapshot := wt.Snapshot()
+
+	answer, proof, err := wt.GetWithContext(context.Background(), "moc.oof")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(answer.subject) != len(m) {
+		t.Errorf("got %v matches, want %v", len(answer.subject), len(m))
+	}
+	if err := proof.Verify("moc.oof", answer, len(m), snapshot); err != nil {
+		t.Errorf("valid proof rejected: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	answer, _, err = wt.GetWithContext(ctx, "moc.oof")
+	if err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if len(answer.subject) >= len(m) {
+		t.Errorf("got %v matches for a cancelled query", len(answer.subject))
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("cancelled query took %v", d)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	var keys []string
+	wt.ForEach(func(key string, payload [][]byte) bool {
+		if !bytes.Equal(concat(payload), concat(m[key].([][]byte))) {
+			t.Errorf("bad payload for key %q", key)
+		}
+		keys = append(keys, key)
+		return true
+	})
+	if got, want := len(keys), len(m); got != want {
+		t.Fatalf("visited %d keys, want %d", got, want)
+	}
+	for i, key := range keys {
+		if want := mkKey(wt.mt.data[i]); key != want {
+			t.Errorf("key %d => got %q, want %q (Merkle leaf order)", i, key,
+				want)
+		}
+	}
+
+	// early termination
+	n := 0
+	wt.ForEach(func(string, [][]byte) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("early termination => visited %d keys, want 3", n)
+	}
+
+	// keys and entries
+	if got := wt.Keys(); fmt.Sprint(got) != fmt.Sprint(keys) {
+		t.Errorf("keys => got %v, want %v", got, keys)
+	}
+	entries := wt.Entries()
+	if len(entries) != len(keys) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(keys))
+	}
+	for i, e := range entries {
+		if e.Key != keys[i] || len(e.Payload) != len(m[e.Key].([][]byte)) {
+			t.Errorf("bad entry %d: %v", i, e)
+		}
+	}
+	if got := MustNewWildcardTree(twc, hash, nil).Entries(); len(got) != 0 {
+		t.Errorf("empty tree => got %d entries, want 0", len(got))
+	}
+}
+
+func TestAll(t *testing.T) {
+	wt := consistencyTree(100)
+	keys := wt.Keys()
+	var got []string
+	for e := range wt.All(context.Background()) {
+		got = append(got, e.Key)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(keys) {
+		t.Errorf("got keys %v, want %v", got, keys)
+	}
+
+	// cancel after three entries: at most one more entry may be in flight
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := wt.All(ctx)
+	n := 0
+	for range ch {
+		if n++; n == 3 {
+			cancel()
+		}
+	}
+	if n < 3 || n > 4 {
+		t.Errorf("cancelled => got %d entries, want 3 or 4", n)
+	}
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			t.Fatalf("cancelled => goroutine leaked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// already cancelled
+	if _, ok := <-wt.All(ctx); ok {
+		t.Errorf("cancelled context => got an entry")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	var keys []string
+	if err := wt.Walk(context.Background(), "moc.oof",
+		func(key string, _ [][]byte) bool {
+			keys = append(keys, key)
+			return true
+		}); err != nil {
+		t.Errorf("walk failed: %v", err)
+	}
+	if a, _ := wt.Get("moc.oof"); fmt.Sprint(keys) != fmt.Sprint(a.Subjects()) {
+		t.Errorf("got keys %v, want %v", keys, a.Subjects())
+	}
+
+	// stopped by callback
+	n := 0
+	if err := wt.Walk(context.Background(), "", func(string, [][]byte) bool {
+		n++
+		return n < 2
+	}); err != nil || n != 2 {
+		t.Errorf("stopped by callback => got %d keys and error %v", n, err)
+	}
+
+	// cancelled by context deadline
+	big := consistencyTree(1000)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	keys = nil
+	start := time.Now()
+	err := big.Walk(ctx, "", func(key string, _ [][]byte) bool {
+		keys = append(keys, key)
+		if len(keys) == 10 {
+			<-ctx.Done()
+		}
+		return true
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("deadline => got error %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("deadline => returned after %v", d)
+	}
+	if fmt.Sprint(keys) != fmt.Sprint(big.Keys()[:10]) {
+		t.Errorf("deadline => got partial keys %v", keys)
+	}
+}
+
+func TestContains(t *testing.T) {
+	for _, table := range []struct {
+		m     map[string]interface{}
+		key   string
+		match bool // expect Contains
+		exact bool // expect ContainsExact
+	}{
+		{nil, "", false, false},
+		{nil, "a", false, false},
+		{map[string]interface{}{"b": [][]byte{}}, "", true, false},
+		{map[string]interface{}{"b": [][]byte{}}, "b", true, true},
+		{map[string]interface{}{"b": [][]byte{}}, "bb", false, false},
+		{testData(), stringutil.Reverse("foo.com"), true, true},
+		{testData(), stringutil.Reverse("com"), true, false},
+		{testData(), stringutil.Reverse("sub1.foo.com"), true, true},
+		{testData(), stringutil.Reverse("sub0.foo.com"), false, false},
+		{testData(), stringutil.Reverse("net"), false, false},
+	} {
+		wt := MustNewWildcardTree(twc, hash, table.m)
+		if got := wt.Contains(table.key); got != table.match {
+			t.Errorf("Contains(%q) => got %v, want %v", table.key, got, table.match)
+		}
+		if got := wt.ContainsExact(table.key); got != table.exact {
+			t.Errorf("ContainsExact(%q) => got %v, want %v", table.key, got,
+				table.exact)
+		}
+	}
+}
+
+func TestSnapshotStrings(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	for _, table := range []struct {
+		desc   string
+		encode func() string
+		parse  func(string) ([]byte, error)
+	}{
+		{"hex", wt.SnapshotHex, ParseSnapshotHex},
+		{"base64", wt.SnapshotBase64, ParseSnapshotBase64},
+	} {
+		snapshot, err := table.parse(table.encode())
+		if err != nil {
+			t.Errorf("%s => %v", table.desc, err)
+		} else if !bytes.Equal(snapshot, wt.Snapshot()) {
+			t.Errorf("%s => got %x, want %x", table.desc, snapshot, wt.Snapshot())
+		}
+		if _, err := table.parse("not a snapshot"); err == nil {
+			t.Errorf("%s => accepted malformed snapshot", table.desc)
+		}
+	}
+	if got, want := wt.SnapshotHex(), fmt.Sprintf("%x", wt.Snapshot()); got !=
+		want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestPayloadOf(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	for key, want := range m {
+		payload, ok := wt.PayloadOf(key)
+		if !ok || !reflect.DeepEqual(payload, want) {
+			t.Errorf("%q => got %q (%v), want %q", key, payload, ok, want)
+		}
+	}
+	if payload, ok := wt.PayloadOf("moc"); ok || payload != nil {
+		t.Errorf("absent key => got %q (%v)", payload, ok)
+	}
+
+	for _, prefix := range []string{"", "moc.oof", "moc.oof.", "es", "ten"} {
+		got := wt.PayloadsWithPrefix(prefix)
+		answer, _ := wt.Get(prefix)
+		if len(got) != len(answer.subject) {
+			t.Errorf("%q => got %d payloads, want %d", prefix, len(got),
+				len(answer.subject))
+		}
+		for key, payload := range got {
+			if !strings.HasPrefix(key, prefix) ||
+				!reflect.DeepEqual(payload, m[key]) {
+				t.Errorf("%q => bad payload for key %q", prefix, key)
+			}
+		}
+	}
+}
+
+func TestCountPrefix(t *testing.T) {
+	for _, m := range []map[string]interface{}{nil, testData(),
+		consistencyTree(100).toMap()} {
+		wt := MustNewWildcardTree(twc, hash, m)
+		for _, prefix := range []string{"", "a", "es", "es.xuq", "key", "key0",
+			"key05", "key099", "key1", "moc.oof", "moc.oof.", "moc.oof.3", "zzz"} {
+			answer, _ := wt.Get(prefix)
+			if got, want := wt.CountPrefix(prefix), len(answer.subject); got !=
+				want {
+				t.Errorf("%q => got %d, want %d", prefix, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkCountPrefix(b *testing.B) {
+	m := make(map[string]interface{})
+	for i := 0; i < 100000; i++ {
+		m[fmt.Sprintf("moc.%d.%05d", i%2, i)] = [][]byte{[]byte("cert")}
+	}
+	wt := MustNewWildcardTree(twc, hash, m)
+	wt.Snapshot()
+	b.Run("CountPrefix", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			wt.CountPrefix("moc.0.")
+		}
+	})
+	b.Run("Get", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			wt.Get("moc.0.")
+		}
+	})
+}
+
+func TestGetExact(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	snapshot := wt.Snapshot()
+
+	key := stringutil.Reverse("foo.com")
+	if answer, _ := wt.Get(key); len(answer.subject) != 3 {
+		t.Errorf("wildcard query => got %v, want three matches", answer.subject)
+	}
+	answer, proof, ok := wt.GetExact(key)
+	if !ok {
+		t.Errorf("exact query => key %v not found", key)
+	}
+	if len(answer.subject) != 1 || answer.subject[0] != key {
+		t.Errorf("exact query => got %v, want [%v]", answer.subject, key)
+	}
+	if got, want := mkKey(proof.rl), stringutil.Reverse("sub1.foo.com"); got != want {
+		t.Errorf("exact query => got right leaf %v, want %v", got, want)
+	}
+	if err := proof.Verify(key, answer, len(m), snapshot); err != nil {
+		t.Errorf("exact query => valid proof rejected: %v", err)
+	}
+
+	for _, key := range []string{
+		"a", "zzz",
+		stringutil.Reverse("com"),
+		stringutil.Reverse("sub0.foo.com"),
+	} {
+		answer, proof, ok := wt.GetExact(key)
+		if ok || len(answer.subject) != 0 {
+			t.Errorf("exact query => got %v for absent key %v", answer.subject, key)
+		}
+		if err := proof.Verify(key, answer, len(m), snapshot); err != nil {
+			t.Errorf("exact query => valid absence proof rejected for %v: %v",
+				key, err)
+		}
+	}
+
+	if _, proof, ok := MustNewWildcardTree(twc, hash, nil).GetExact("a"); ok ||
+		proof.index != -1 {
+		t.Errorf("exact query => bad result for empty tree")
 	}
 }
 
@@ -167,3 +883,272 @@ func testData() map[string]interface{} {
 	m[stringutil.Reverse("sub.qux.se")] = [][]byte{[]byte("sub.qux.se cert")}
 	return m
 }
+
+func FuzzProofVerify(f *testing.F) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	snapshot := wt.Snapshot()
+	for _, key := range []string{"foo.com", "sub1.foo.com", "sub0.foo.com",
+		"bar.se", "foo.zzz"} {
+		key = stringutil.Reverse(key)
+		_, p := wt.Get(key)
+		flags := uint8(0)
+		if p.lap != nil {
+			flags |= fuzzLeftAP
+		}
+		if p.rap != nil {
+			flags |= fuzzRightAP
+		}
+		f.Add(key, p.index, p.ll, p.rl, concat(p.lap), concat(p.rap), flags)
+	}
+	f.Fuzz(func(t *testing.T, key string, index int, ll, rl, lap, rap []byte,
+		flags uint8) {
+		p := Proof{hash: hash, twc: twc, index: index}
+		if len(ll) > 0 {
+			p.ll = ll
+		}
+		if len(rl) > 0 {
+			p.rl = rl
+		}
+		if flags&fuzzLeftAP != 0 {
+			p.lap = fuzzHashes(lap)
+		}
+		if flags&fuzzRightAP != 0 {
+			p.rap = fuzzHashes(rap)
+		}
+		answer, _ := wt.Get(key)
+		err := p.Verify(key, answer, len(m), snapshot)
+		if !hashLengths(p.lap, hashLen) || !hashLengths(p.rap, hashLen) {
+			if err == nil {
+				t.Errorf("accepted audit path with a bad hash length")
+			}
+		}
+		if (p.ll != nil && len(p.ll) < hashLen) ||
+			(p.rl != nil && len(p.rl) < hashLen) {
+			if err == nil {
+				t.Errorf("accepted truncated leaf data")
+			}
+		}
+	})
+}
+
+func FuzzNewWildcardTree(f *testing.F) {
+	f.Add("", "a", "moc.oof", []byte{}, []byte("x"))
+	f.Add("moc.oof", "moc.oof.1bus", "es.xuq", []byte("cert"), []byte{})
+	f.Fuzz(func(t *testing.T, k1, k2, k3 string, v1, v2 []byte) {
+		m := map[string]interface{}{
+			k1: [][]byte{v1},
+			k2: [][]byte{v1, v2},
+			k3: [][]byte{},
+		}
+		wt := MustNewWildcardTree(twc, hash, m)
+		snapshot := wt.Snapshot()
+		for _, key := range []string{k1, k2, k3, k1 + k2} {
+			answer, proof := wt.Get(key)
+			if err := proof.Verify(key, answer, len(m), snapshot); err != nil {
+				t.Errorf("valid proof rejected for key %q: %v", key, err)
+			}
+		}
+	})
+}
+
+func TestSnapshotCache(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	if got, want := wt.Snapshot(), wt.mt.mth(wt.mt.data, wt.mt.cache); !bytes.Equal(got, want) {
+		t.Errorf("cached snapshot => got %x, want %x", got, want)
+	}
+	for _, mutate := range []func() error{
+		func() error { return wt.Add("moc.rab", [][]byte{[]byte("bar")}) },
+		func() error { return wt.Update("moc.rab", [][]byte{[]byte("baz")}) },
+		func() error { return wt.Remove("moc.rab") },
+	} {
+		old := wt.Snapshot()
+		if err := mutate(); err != nil {
+			t.Fatalf("mutation failed: %v", err)
+		}
+		fresh := MustNewWildcardTree(twc, hash, entryMap(wt.Entries())).Snapshot()
+		if got := wt.Snapshot(); !bytes.Equal(got, fresh) || bytes.Equal(got, old) {
+			t.Errorf("stale snapshot after mutation")
+		}
+	}
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	m := make(map[string]interface{})
+	for i := 0; i < 1000; i++ {
+		m[fmt.Sprintf("moc.%04d", i)] = [][]byte{[]byte("cert")}
+	}
+	wt := MustNewWildcardTree(twc, hash, m)
+	wt.Snapshot()
+	b.Run("Cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < 10000; j++ {
+				wt.Snapshot()
+			}
+		}
+	})
+	b.Run("Uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < 10000; j++ {
+				wt.mt.mth(wt.mt.data, wt.mt.cache)
+			}
+		}
+	})
+}
+
+// entryMap outputs entries as a map that can be passed to NewWildcardTree
+func entryMap(entries []Entry) map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, e := range entries {
+		m[e.Key] = e.Payload
+	}
+	return m
+}
+
+func TestAnswerMerge(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	a1, p1 := wt.Get("es")  // leaves 0-1
+	a2, p2 := wt.Get("moc") // leaves 2-4
+	merged, err := a1.Merge(a2)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if got, want := len(merged.Subjects()), 5; got != want {
+		t.Fatalf("got %d subjects, want %d", got, want)
+	}
+	if _, err := a2.Merge(a1); err == nil {
+		t.Errorf("accepted answers in the wrong order")
+	}
+	if _, err := a1.Merge(a1); err == nil {
+		t.Errorf("accepted overlapping answers")
+	}
+	if empty, err := a1.Merge(Answer{}); err != nil ||
+		len(empty.Subjects()) != len(a1.Subjects()) {
+		t.Errorf("merge with empty answer => got %v, %v", empty.Subjects(), err)
+	}
+
+	// a proof for the merged answer spans from the first proof's left end to
+	// the second proof's right end
+	p := Proof{hash: p1.hash, twc: p1.twc, index: p1.index, ll: p1.ll, lap: p1.lap,
+		rl: p2.rl, rap: p2.rap}
+	if err := p.Verify("es", merged, wt.Size(), wt.Snapshot()); err != nil {
+		t.Errorf("merged proof rejected: %v", err)
+	}
+
+	// slices
+	if got := merged.Slice(1, 4).Subjects(); fmt.Sprint(got) !=
+		fmt.Sprint(merged.Subjects()[1:4]) {
+		t.Errorf("slice => got %v", got)
+	}
+	if got, err := merged.Slice(0, 2).Merge(merged.Slice(2, 5)); err != nil ||
+		fmt.Sprint(got) != fmt.Sprint(merged) {
+		t.Errorf("slices do not merge back => got %v, %v", got, err)
+	}
+}
+
+func TestWildcardTreeQuickCheck(t *testing.T) {
+	f := func(m quickTree, probes quickKeys) bool {
+		wt := MustNewWildcardTree(twc, hash, m)
+		snapshot := wt.Snapshot()
+		if !bytes.Equal(snapshot, wt.Snapshot()) {
+			t.Logf("unstable snapshot")
+			return false
+		}
+		for key := range m {
+			a, p := wt.Get(key)
+			if len(a.Subjects()) == 0 {
+				t.Logf("member %q => no match", key)
+				return false
+			}
+			if err := p.Verify(key, a, wt.Size(), snapshot); err != nil {
+				t.Logf("member %q => %v", key, err)
+				return false
+			}
+		}
+		for _, key := range probes {
+			if _, ok := m[key]; ok {
+				continue
+			}
+			a, p := wt.Get(key)
+			if err := p.Verify(key, a, wt.Size(), snapshot); err != nil {
+				t.Logf("non-member %q => %v", key, err)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+// quickTree is a random map of reversed domain-like keys to payloads
+type quickTree map[string]interface{}
+
+func (quickTree) Generate(r *rand.Rand, size int) reflect.Value {
+	m := make(quickTree)
+	for n := r.Intn(size + 1); len(m) < n; {
+		payload := make([][]byte, r.Intn(3))
+		for i := range payload {
+			payload[i] = make([]byte, r.Intn(16))
+			r.Read(payload[i])
+		}
+		m[quickKey(r)] = payload
+	}
+	return reflect.ValueOf(m)
+}
+
+// quickKeys is a random list of reversed domain-like keys
+type quickKeys []string
+
+func (quickKeys) Generate(r *rand.Rand, size int) reflect.Value {
+	keys := make(quickKeys, r.Intn(size+1))
+	for i := range keys {
+		keys[i] = quickKey(r)
+	}
+	return reflect.ValueOf(keys)
+}
+
+// quickKey outputs one to four dot-separated labels, each of which is one to
+// four characters from a small alphabet such that keys often share prefixes
+func quickKey(r *rand.Rand) string {
+	const alphabet = "abc01"
+	labels := make([]string, 1+r.Intn(4))
+	for i := range labels {
+		label := make([]byte, 1+r.Intn(4))
+		for j := range label {
+			label[j] = alphabet[r.Intn(len(alphabet))]
+		}
+		labels[i] = string(label)
+	}
+	return strings.Join(labels, ".")
+}
+
+func TestLeafKeyAndHash(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	for _, table := range []struct {
+		desc string
+		data []byte
+		key  string
+		hash []byte
+		ok   bool
+	}{
+		{"nil", nil, "", nil, false},
+		{"too short", make([]byte, hashLen-1), "", nil, false},
+		{"empty key", make([]byte, hashLen), "", make([]byte, hashLen), true},
+		{"leaf", wt.mt.data[2], "moc.oof", hash(m["moc.oof"].([][]byte)...),
+			true},
+	} {
+		key, ok := LeafKey(table.data)
+		if ok != table.ok || key != table.key {
+			t.Errorf("%s => got key (%q, %v), want (%q, %v)", table.desc, key, ok,
+				table.key, table.ok)
+		}
+		h, ok := LeafHash(table.data)
+		if ok != table.ok || !bytes.Equal(h, table.hash) {
+			t.Errorf("%s => got hash (%x, %v), want (%x, %v)", table.desc, h, ok,
+				table.hash, table.ok)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package lwm
+
+// TypedPayload is a payload with one MIME type per item, e.g., to distinguish
+// PEM certificates from DER certificates. It can be used instead of a [][]byte
+// as a value in the map passed to NewWildcardTree. MIME types are metadata
+// that are not part of the Merkle tree, i.e., proofs do not cover them, and
+// callers must not trust them as security assertions. MIME types are not
+// encoded, and Add and Update store payloads without MIME types.
+type TypedPayload struct {
+	Data      [][]byte
+	MIMETypes []string
+}
+
+// MIMEType outputs the MIME type of a subject's payload item, where both are
+// given by index, or an empty string if there is no such MIME type
+func (a Answer) MIMEType(subject int, payloadIndex int) string {
+	if subject < 0 || subject >= len(a.mimeType) ||
+		payloadIndex < 0 || payloadIndex >= len(a.mimeType[subject]) {
+		return ""
+	}
+	return a.mimeType[subject][payloadIndex]
+}
+
+// add appends a match to the answer
+func (a *Answer) add(subject string, rv radixValue) {
+	if rv.mimeTypes != nil && a.mimeType == nil {
+		a.mimeType = make([][]string, len(a.subject))
+	}
+	a.subject = append(a.subject, subject)
+	a.payload = append(a.payload, rv.payload)
+	if a.mimeType != nil {
+		a.mimeType = append(a.mimeType, rv.mimeTypes)
+	}
+}
+
+// mimeTypes outputs one (possibly nil) list of MIME types per subject
+func (a Answer) mimeTypes() [][]string {
+	if a.mimeType == nil {
+		return make([][]string, len(a.subject))
+	}
+	return a.mimeType
+}
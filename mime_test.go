@@ -0,0 +1,78 @@
+package lwm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMIMETypes(t *testing.T) {
+	m := testData()
+	typed := make(map[string]interface{})
+	for key, v := range m {
+		payload := v.([][]byte)
+		mimeTypes := make([]string, len(payload))
+		for i := range payload {
+			mimeTypes[i] = "application/pkix-cert"
+		}
+		typed[key] = TypedPayload{payload, mimeTypes}
+	}
+	typed["moc.oof"] = TypedPayload{m["moc.oof"].([][]byte),
+		[]string{"application/x-pem-file", "application/pkix-cert"}}
+	delete(typed, "moc.oof.2bus") // untyped
+	typed["moc.oof.2bus"] = m["moc.oof.2bus"]
+
+	wt := MustNewWildcardTree(twc, hash, typed)
+	if want := MustNewWildcardTree(twc, hash, m).Snapshot(); !bytes.Equal(
+		wt.Snapshot(), want) {
+		t.Fatalf("MIME types changed the snapshot")
+	}
+	answer, proof := wt.Get("moc.oof")
+	if err := proof.Verify("moc.oof", answer, wt.Size(), wt.Snapshot()); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	for _, table := range []struct {
+		subject, item int
+		want          string
+	}{
+		{0, 0, "application/x-pem-file"},
+		{0, 1, "application/pkix-cert"},
+		{0, 2, ""},
+		{1, 0, "application/pkix-cert"}, // moc.oof.1bus
+		{2, 0, ""},                      // moc.oof.2bus
+		{3, 0, ""},
+		{-1, 0, ""},
+	} {
+		if got := answer.MIMEType(table.subject, table.item); got != table.want {
+			t.Errorf("MIMEType(%d, %d) => got %q, want %q", table.subject,
+				table.item, got, table.want)
+		}
+	}
+
+	// MIME types are not covered by the proof
+	answer.mimeType = append([][]string{{"text/plain"}}, answer.mimeType[1:]...)
+	if err := proof.Verify("moc.oof", answer, wt.Size(), wt.Snapshot()); err != nil {
+		t.Errorf("verify failed after changing a MIME type: %v", err)
+	}
+
+	// MIME types survive rebuilds and slicing, but not updates
+	if a, _ := wt.Compact().Get("moc.oof"); a.MIMEType(0, 0) !=
+		"application/x-pem-file" {
+		t.Errorf("compact => MIME type is lost")
+	}
+	if a, _ := wt.Get("moc.oof"); a.Slice(1, 3).MIMEType(0, 0) !=
+		"application/pkix-cert" {
+		t.Errorf("slice => MIME type is lost")
+	}
+	if err := wt.Update("moc.oof", [][]byte{[]byte("new cert")}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if a, _, _ := wt.GetExact("moc.oof"); a.MIMEType(0, 0) != "" {
+		t.Errorf("update => got MIME type %q", a.MIMEType(0, 0))
+	}
+
+	if _, err := NewWildcardTree(twc, hash, map[string]interface{}{
+		"moc.oof": TypedPayload{[][]byte{[]byte("cert")}, nil},
+	}); err == nil {
+		t.Errorf("accepted MIME type count mismatch")
+	}
+}
@@ -3,8 +3,17 @@ package lwm
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
 )
 
+// parallelDepth is the depth down to which MthParallel hashes the left and
+// right subtrees concurrently. Deeper levels are hashed sequentially, because
+// the goroutine overhead would outweigh the gain for small subtrees.
+const parallelDepth = 4
+
 // MerkleTree is a static Merkle tree supporting range verification. Root hash
 // and audit path calculations are based on RFC 6962, but we also cache hashes.
 type MerkleTree struct {
@@ -14,17 +23,83 @@ type MerkleTree struct {
 	hash           func(data ...[]byte) []byte
 	data           [][]byte
 	cache          *hashCache
+	snapshot       []byte     // cached root hash (nil->not computed)
+	apCache        [][][]byte // audit path of every leaf (nil->not computed)
 }
 
 type hashCache struct {
-	this  []byte     // hash of current node
-	left  *hashCache // left node
-	right *hashCache // right node
+	this   []byte     // hash of current node
+	left   *hashCache // left node
+	right  *hashCache // right node
+	pooled bool       // allocated by newHashCache, see releaseHashCache
+}
+
+// hashCachePool reuses hashCache nodes that were handed back by Release()
+var hashCachePool = sync.Pool{
+	New: func() interface{} { return new(hashCache) },
+}
+
+// newHashCache outputs an empty hashCache node from the pool
+func newHashCache() *hashCache {
+	c := hashCachePool.Get().(*hashCache)
+	c.pooled = true
+	return c
+}
+
+// allocChildren allocates the child nodes of c unless they already exist, which
+// is the case if the cache was allocated by preallocHashCache
+func (c *hashCache) allocChildren() {
+	if c.left == nil {
+		c.left = newHashCache()
+	}
+	if c.right == nil {
+		c.right = newHashCache()
+	}
+}
+
+// releaseHashCache resets and returns all nodes of a hashCache to the pool,
+// except those allocated by preallocHashCache: a single pooled node would
+// keep its entire allocation alive, so they are left to the garbage collector
+func releaseHashCache(c *hashCache) {
+	if c == nil {
+		return
+	}
+	releaseHashCache(c.left)
+	releaseHashCache(c.right)
+	pooled := c.pooled
+	*c = hashCache{}
+	if pooled {
+		hashCachePool.Put(c)
+	}
+}
+
+// preallocHashCache outputs the hashCache nodes of a tree with n leaves using
+// a single allocation. The tree is not perfect unless n is a power of two, so
+// only the 2n-1 nodes of its actual shape are allocated and linked.
+func preallocHashCache(n int) *hashCache {
+	if n <= 1 {
+		return newHashCache()
+	}
+	nodes := make([]hashCache, 2*n-1)
+	var link func(lo, hi int) *hashCache
+	next := 0
+	link = func(lo, hi int) *hashCache {
+		c := &nodes[next]
+		next++
+		if hi-lo > 1 {
+			k := lo + lpow2s(hi-lo)
+			c.left = link(lo, k)
+			c.right = link(k, hi)
+		}
+		return c
+	}
+	return link(0, n)
 }
 
 // NewMerkleTree outputs a new MerkleTree for data that uses a given leaf
-// prefix, interior prefix, and hash function. No hashes are cached upon
-// initialization: this is done when Mth() is invoked for the first time.
+// prefix, interior prefix, and hash function. All hashCache nodes are
+// allocated upfront, but no hashes are cached upon initialization: this is
+// done when Mth() is invoked for the first time.
 func NewMerkleTree(twc, leafPrefix, interiorPrefix []byte,
 	hash func(data ...[]byte) []byte, data [][]byte) *MerkleTree {
 	mt := new(MerkleTree)
@@ -33,13 +108,177 @@ func NewMerkleTree(twc, leafPrefix, interiorPrefix []byte,
 	mt.interiorPrefix = interiorPrefix
 	mt.hash = hash
 	mt.data = data
-	mt.cache = new(hashCache)
+	mt.cache = preallocHashCache(len(data))
 	return mt
 }
 
+// Release drops all cached hashes. Nodes that were allocated after a previous
+// Release are handed back to a package-wide pool, which reduces the number of
+// allocations when a tree is modified and released repeatedly. The nodes that
+// NewMerkleTree allocates at once are left to the garbage collector. The tree
+// remains usable but hashes are recomputed on demand. It must not be called
+// concurrently with other methods.
+func (mt *MerkleTree) Release() {
+	releaseHashCache(mt.cache)
+	mt.cache = newHashCache()
+	mt.snapshot = nil
+	mt.apCache = nil
+}
+
+// Clone outputs a deep copy of the tree, including all cached hashes. The
+// hash function is shared.
+func (mt *MerkleTree) Clone() *MerkleTree {
+	return &MerkleTree{
+		twc:            cloneBytes(mt.twc),
+		leafPrefix:     cloneBytes(mt.leafPrefix),
+		interiorPrefix: cloneBytes(mt.interiorPrefix),
+		hash:           mt.hash,
+		data:           cloneData(mt.data),
+		cache:          cloneHashCache(mt.cache),
+		snapshot:       cloneBytes(mt.snapshot),
+	}
+}
+
+// cloneHashCache outputs a deep copy of c using nodes from the pool
+func cloneHashCache(c *hashCache) *hashCache {
+	if c == nil {
+		return nil
+	}
+	clone := newHashCache()
+	clone.this = cloneBytes(c.this)
+	clone.left = cloneHashCache(c.left)
+	clone.right = cloneHashCache(c.right)
+	return clone
+}
+
+// cloneBytes outputs a copy of b that is nil if b is nil
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// cloneData outputs a deep copy of data that is nil if data is nil
+func cloneData(data [][]byte) [][]byte {
+	if data == nil {
+		return nil
+	}
+	clone := make([][]byte, len(data))
+	for i, d := range data {
+		clone[i] = cloneBytes(d)
+	}
+	return clone
+}
+
+// Equal outputs true if both trees have the same tree-wide constant, prefixes,
+// hash algorithm, leaf data, and root hash. Hash algorithms are compared by
+// their output on a fixed probe, since functions are not comparable.
+func (mt *MerkleTree) Equal(other *MerkleTree) bool {
+	if !mt.DataEqual(other) {
+		return false
+	}
+	if mt == nil {
+		return true // both are nil
+	}
+	if !bytes.Equal(mt.twc, other.twc) ||
+		!bytes.Equal(mt.leafPrefix, other.leafPrefix) ||
+		!bytes.Equal(mt.interiorPrefix, other.interiorPrefix) {
+		return false
+	}
+	probe := []byte("probe")
+	if !bytes.Equal(mt.hash(probe), other.hash(probe)) {
+		return false
+	}
+	return bytes.Equal(mt.Mth(), other.Mth())
+}
+
+// DataEqual outputs true if both trees have the same leaf data
+func (mt *MerkleTree) DataEqual(other *MerkleTree) bool {
+	if mt == nil || other == nil {
+		return mt == other
+	}
+	if len(mt.data) != len(other.data) {
+		return false
+	}
+	for i := range mt.data {
+		if !bytes.Equal(mt.data[i], other.data[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Size outputs the number of leaves
+func (mt *MerkleTree) Size() int {
+	return len(mt.data)
+}
+
+// NumLeaves outputs the number of leaves, see Size
+func (mt *MerkleTree) NumLeaves() int {
+	return mt.Size()
+}
+
+// NumInteriorNodes outputs the number of interior nodes. Every interior node
+// has two children, so this is one less than the number of leaves if the tree
+// is non-empty.
+func (mt *MerkleTree) NumInteriorNodes() int {
+	return numInteriorNodes(len(mt.data))
+}
+
+func numInteriorNodes(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	k := lpow2s(n)
+	return 1 + numInteriorNodes(k) + numInteriorNodes(n-k)
+}
+
+// TotalNodes outputs the number of leaf and interior nodes
+func (mt *MerkleTree) TotalNodes() int {
+	return mt.NumLeaves() + mt.NumInteriorNodes()
+}
+
+// CachedNodeCount outputs the number of nodes with a computed hash in the cache
+func (mt *MerkleTree) CachedNodeCount() int {
+	return cachedNodeCount(mt.cache)
+}
+
+func cachedNodeCount(c *hashCache) int {
+	if c == nil {
+		return 0
+	}
+	n := cachedNodeCount(c.left) + cachedNodeCount(c.right)
+	if c.this != nil {
+		n++
+	}
+	return n
+}
+
+// Leaf outputs a copy of the i:th leaf's data
+func (mt *MerkleTree) Leaf(i int) ([]byte, error) {
+	if i < 0 || i >= len(mt.data) {
+		return nil, fmt.Errorf("leaf index %d is out of bounds [0,%d)", i,
+			len(mt.data))
+	}
+	return append([]byte{}, mt.data[i]...), nil
+}
+
+// Leaves outputs a deep copy of all leaf data
+func (mt *MerkleTree) Leaves() [][]byte {
+	leaves := make([][]byte, len(mt.data))
+	for i, d := range mt.data {
+		leaves[i] = append([]byte{}, d...)
+	}
+	return leaves
+}
+
 // Mth compute a Merkle tree head
 func (mt *MerkleTree) Mth() []byte {
-	return mt.mth(mt.data, mt.cache)
+	if mt.snapshot == nil {
+		mt.snapshot = mt.mth(mt.data, mt.cache)
+	}
+	return mt.snapshot
 }
 
 func (mt *MerkleTree) mth(data [][]byte, c *hashCache) []byte {
@@ -50,8 +289,7 @@ func (mt *MerkleTree) mth(data [][]byte, c *hashCache) []byte {
 			c.this = mt.hash(mt.twc, mt.leafPrefix, data[0])
 		} else {
 			k := lpow2s(n)
-			c.left = new(hashCache)
-			c.right = new(hashCache)
+			c.allocChildren()
 			c.this = mt.hash(mt.interiorPrefix, mt.mth(data[:k], c.left),
 				mt.mth(data[k:], c.right))
 		}
@@ -59,8 +297,55 @@ func (mt *MerkleTree) mth(data [][]byte, c *hashCache) []byte {
 	return c.this
 }
 
-// Ap computes an audit path for the m:th leaf
+// MthParallel computes a Merkle tree head like Mth, but hashes independent
+// subtrees concurrently using at most workers goroutines. The hash cache is
+// populated as by Mth. It must not be called concurrently with other methods.
+func (mt *MerkleTree) MthParallel(workers int) []byte {
+	if workers <= 1 {
+		return mt.Mth()
+	}
+	sem := make(chan struct{}, workers-1) // the caller's goroutine is a worker
+	return mt.mthParallel(mt.data, mt.cache, 0, sem)
+}
+
+func (mt *MerkleTree) mthParallel(data [][]byte, c *hashCache, depth int,
+	sem chan struct{}) []byte {
+	if c.this != nil || len(data) <= 1 || depth >= parallelDepth {
+		return mt.mth(data, c)
+	}
+
+	// every cache node is written by exactly one goroutine
+	k := lpow2s(len(data))
+	c.allocChildren()
+	var wg sync.WaitGroup
+	var left []byte
+	select {
+	case sem <- struct{}{}:
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			left = mt.mthParallel(data[:k], c.left, depth+1, sem)
+		}()
+	default: // no idle worker
+		left = mt.mthParallel(data[:k], c.left, depth+1, sem)
+	}
+	right := mt.mthParallel(data[k:], c.right, depth+1, sem)
+	wg.Wait()
+	c.this = mt.hash(mt.interiorPrefix, left, right)
+	return c.this
+}
+
+// Ap computes an audit path for the m:th leaf, or looks it up if the tree was
+// warmed up, see WildcardTree.Warm
 func (mt *MerkleTree) Ap(m int) [][]byte {
+	if mt.apCache != nil && m >= 0 && m < len(mt.apCache) {
+		if len(mt.apCache[m]) == 0 {
+			return nil // like ap, e.g., if the root is a leaf
+		}
+		return append([][]byte{}, mt.apCache[m]...)
+	}
+	mt.Mth() // ap relies on a populated cache
 	return mt.ap(m, mt.data, mt.cache)
 }
 
@@ -75,6 +360,69 @@ func (mt *MerkleTree) ap(m int, data [][]byte, c *hashCache) [][]byte {
 	return append(mt.ap(m-k, data[k:], c.right), mt.mth(data[:k], c.left))
 }
 
+// cp computes a consistency proof between the first m leaves and all data, see
+// SUBPROOF in RFC 6962 (§2.1.2). The flag b is true if the m leaves are a
+// complete subtree that the verifier already has the hash for.
+func (mt *MerkleTree) cp(m int, data [][]byte, c *hashCache, b bool) [][]byte {
+	n := len(data)
+	if m == n {
+		if b {
+			return nil
+		}
+		return [][]byte{mt.mth(data, c)}
+	}
+	k := lpow2s(n)
+	if m <= k {
+		return append(mt.cp(m, data[:k], c.left, b), mt.mth(data[k:], c.right))
+	}
+	return append(mt.cp(m-k, data[k:], c.right, false), mt.mth(data[:k], c.left))
+}
+
+// ApAll computes audit paths for all leaves in a single tree walk, such that
+// the i:th path is equal to Ap(i). Independent subtrees are walked concurrently
+// using cached hashes. It must not be called concurrently with other methods.
+func (mt *MerkleTree) ApAll() [][][]byte {
+	mt.Mth() // apAll relies on a populated cache
+	paths := make([][][]byte, len(mt.data))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0)-1)
+	mt.apAll(mt.data, mt.cache, 0, nil, paths, 0, sem)
+	return paths
+}
+
+// apAll writes the audit path of every leaf in data to paths, where offset is
+// the index of data[0] and suffix is the audit path of the current subtree
+func (mt *MerkleTree) apAll(data [][]byte, c *hashCache, offset int,
+	suffix [][]byte, paths [][][]byte, depth int, sem chan struct{}) {
+	if len(data) <= 1 {
+		if len(data) == 1 {
+			paths[offset] = suffix
+		}
+		return
+	}
+
+	k := lpow2s(len(data))
+	lsuffix := append([][]byte{c.right.this}, suffix...)
+	rsuffix := append([][]byte{c.left.this}, suffix...)
+	if depth < parallelDepth {
+		select {
+		case sem <- struct{}{}:
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				mt.apAll(data[:k], c.left, offset, lsuffix, paths, depth+1, sem)
+			}()
+			mt.apAll(data[k:], c.right, offset+k, rsuffix, paths, depth+1, sem)
+			wg.Wait()
+			return
+		default: // no idle worker
+		}
+	}
+	mt.apAll(data[:k], c.left, offset, lsuffix, paths, depth+1, sem)
+	mt.apAll(data[k:], c.right, offset+k, rsuffix, paths, depth+1, sem)
+}
+
 // MthFromAp builds a root hash from an audit path
 func (mt *MerkleTree) MthFromAp(l []byte, index, size int,
 	path [][]byte) (r []byte) {
@@ -94,6 +442,139 @@ func (mt *MerkleTree) MthFromAp(l []byte, index, size int,
 	return
 }
 
+// FastMthFromAp is like MthFromAp, but uses the hash cache of the tree after
+// Mth() was called. While the recomputed node and its sibling in the audit
+// path equal the cached ones, the cached parent is used instead of hashing.
+// This means that no hashing is needed for a leaf and an audit path of the
+// tree. If size is not the tree's size or the cache is empty, MthFromAp is
+// used. An error is returned if the audit path does not fit index and size.
+func (mt *MerkleTree) FastMthFromAp(l []byte, index, size int,
+	path [][]byte) ([]byte, error) {
+	if index < 0 || index >= size {
+		return nil, fmt.Errorf("leaf index %d is not in [0,%d)", index, size)
+	}
+	if size != len(mt.data) || mt.cache == nil || mt.cache.this == nil {
+		return mt.MthFromAp(l, index, size, path), nil
+	}
+
+	// cached nodes on the path from the root to the leaf, with siblings
+	type step struct {
+		parent, node, sibling *hashCache
+		left                  bool // node is a left child
+	}
+	var steps []step
+	c, m, n := mt.cache, index, size
+	for n > 1 {
+		k := lpow2s(n)
+		if m < k {
+			steps = append(steps, step{c, c.left, c.right, true})
+			c, n = c.left, k
+		} else {
+			steps = append(steps, step{c, c.right, c.left, false})
+			c, m, n = c.right, m-k, n-k
+		}
+	}
+	if len(steps) != len(path) {
+		return nil, fmt.Errorf("audit path has length %d, want %d", len(path),
+			len(steps))
+	}
+
+	var r []byte
+	if c.this != nil && bytes.Equal(l, mt.data[index]) {
+		r = c.this
+	} else {
+		r = mt.hash(mt.twc, mt.leafPrefix, l)
+	}
+	for i, s := range path {
+		st := steps[len(steps)-1-i]
+		if st.parent.this != nil && bytes.Equal(r, st.node.this) &&
+			bytes.Equal(s, st.sibling.this) {
+			r = st.parent.this
+		} else if st.left {
+			r = mt.hash(mt.interiorPrefix, r, s)
+		} else {
+			r = mt.hash(mt.interiorPrefix, s, r)
+		}
+	}
+	return r, nil
+}
+
+// MthFromApWithCache is like MthFromAp, but looks up interior hashes in cache
+// that maps "index/level" to the hash of a node, where level zero are leaves.
+// A cached hash is only used if its children's cached hashes are the ones that
+// are being hashed, so cache entries from other leaves, paths, and tree sizes
+// are safe to mix. Computed hashes are stored in cache, and entries that no
+// longer match their children are deleted. A nil cache is not used.
+func (mt *MerkleTree) MthFromApWithCache(l []byte, index, size int,
+	path [][]byte, cache map[string][]byte) ([]byte, error) {
+	if index < 0 || index >= size {
+		return nil, fmt.Errorf("leaf index %d is not in [0,%d)", index, size)
+	}
+	if n := apLen(index, size); n != len(path) {
+		return nil, fmt.Errorf("audit path has length %d, want %d", len(path), n)
+	}
+	if cache == nil {
+		return mt.MthFromAp(l, index, size, path), nil
+	}
+
+	r := mt.hash(mt.twc, mt.leafPrefix, l)
+	lastIndex := size - 1
+	for level := 0; lastIndex > 0; level++ {
+		if index%2 == 1 || index < lastIndex {
+			var s []byte
+			s, path = head(path)
+			node, sibling := nodeKey(index, level), nodeKey(index^1, level)
+			parent := nodeKey(index/2, level+1)
+			if p, ok := cache[parent]; ok && cached(cache, node, r) &&
+				cached(cache, sibling, s) {
+				r = p
+			} else {
+				var p []byte
+				if index%2 == 1 {
+					p = mt.hash(mt.interiorPrefix, s, r)
+				} else {
+					p = mt.hash(mt.interiorPrefix, r, s)
+				}
+				cacheNode(cache, index, level, r, true)
+				cacheNode(cache, index^1, level, s, true)
+				cacheNode(cache, index/2, level+1, p, false)
+				r = p
+			}
+		}
+		index = index / 2
+		lastIndex = lastIndex / 2
+	}
+	return r, nil
+}
+
+// nodeKey outputs the cache key of a node, see MthFromApWithCache
+func nodeKey(index, level int) string {
+	return strconv.Itoa(index) + "/" + strconv.Itoa(level)
+}
+
+// cached outputs true if cache maps key to h
+func cached(cache map[string][]byte, key string, h []byte) bool {
+	c, ok := cache[key]
+	return ok && bytes.Equal(c, h)
+}
+
+// cacheNode stores the hash h of a node. If the node's hash changed, the
+// cached parent and optionally the cached children are deleted, such that a
+// cached node is always the hash of its cached children.
+func cacheNode(cache map[string][]byte, index, level int, h []byte,
+	children bool) {
+	key := nodeKey(index, level)
+	if cached(cache, key, h) {
+		return
+	}
+	cache[key] = h
+	delete(cache, nodeKey(index/2, level+1))
+	if children && level > 0 {
+		delete(cache, nodeKey(2*index, level-1))
+		delete(cache, nodeKey(2*index+1, level-1))
+	}
+}
+
 // MthFromRangeAp builds a root hash from a consecutive range of leaves; data
 // is a list of leaf values, i the left-most leaf index in the range, n the
 // size of the full Merkle tree, and {l,r}Ap an audit path to the {left,right}
@@ -119,7 +600,10 @@ func (mt *MerkleTree) MthFromRangeAp(data [][]byte, i, n int,
 	}
 
 	// input validation: ensure that all slice bounds will be valid
-	if i+len(data) > n {
+	if n < 0 || i < 0 || len(data) == 0 {
+		return nil, errors.New("malformed proof: bad range")
+	}
+	if i > n-len(data) { // not i+len(data) > n, which may overflow
 		return nil, errors.New("malformed proof: tree too small")
 	}
 
@@ -135,10 +619,15 @@ func (mt *MerkleTree) MthFromRangeAp(data [][]byte, i, n int,
 
 // jp is used for {left,right} APs that go down `joint paths'
 func (mt *MerkleTree) jp(data [][]byte, i, n int, lAp, rAp [][]byte) []byte {
-	k := lpow2s(n)
-	sindex, lindex, rindex := split(k, len(data), i)
+	k, ok := safeLpow2s(n)
+	if !ok { // audit paths with too many shared hashes reach a leaf
+		return mt.dp(data, i, n, lAp)
+	}
+	sindex, lindex, rindex := SplitRange(k, len(data), i)
 
 	if lAp != nil && rAp != nil {
+		// not constant time: audit path hashes are part of the proof, i.e., they
+		// are not secret, and only the final root comparison must be
 		if bytes.Equal(last(lAp), last(rAp)) {
 			if sindex > 0 {
 				return mt.hash(mt.interiorPrefix,
@@ -178,19 +667,24 @@ func (mt *MerkleTree) dp(data [][]byte, i, n int, ap [][]byte) (h []byte) {
 
 	// interior node -> get child hashes recurisvely
 	k := lpow2s(n)
-	sindex, lindex, rindex := split(k, len(data), i)
+	sindex, lindex, rindex := SplitRange(k, len(data), i)
 	return mt.hash(mt.interiorPrefix,
 		mt.dp(data[:sindex], lindex, k, next(ap)),
 		mt.dp(data[sindex:], rindex, n-k, next(ap)))
 }
 
-// split is used to split a consecutive list of leaf data in a (sub)tree, where
-// k is the smallest power of 2 larger than the (sub)tree size, n is the number
-// of leaves to split on, and i is the index of the left-most leaf in _subtree_
-func split(k, n, i int) (int, int, int) {
-	s := k - i
+// SplitRange splits a consecutive range of leaves in a (sub)tree at the split
+// point, i.e., the size of the left subtree, see LargestPowerOf2Below. The
+// range has rangeLen leaves and starts at startIndex in the (sub)tree. The
+// output is the number of range leaves in the left subtree, the index of the
+// first of them in the left subtree, and the index where the remaining leaves
+// start in the right subtree. The latter is zero if the range starts in the
+// left subtree, and the former two are zero if it starts in the right subtree.
+func SplitRange(splitPoint, rangeLen, startIndex int) (leftCount, leftIndex,
+	rightIndex int) {
+	s := splitPoint - startIndex
 	if s > 0 {
-		return min(n, s), i, 0
+		return min(rangeLen, s), startIndex, 0
 	}
 	return 0, 0, -s
 }
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"math"
+	"math/rand"
+	"sync"
 	"testing"
 )
 
@@ -45,6 +48,133 @@ func TestAp(t *testing.T) {
 	}
 }
 
+func TestFastMthFromAp(t *testing.T) {
+	for n := 1; n <= 64; n++ {
+		data := leafData(n)
+		mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+		for _, warm := range []bool{false, true} {
+			if warm {
+				mt.Mth()
+			}
+			for i := 0; i < n; i++ {
+				ap := mt.Ap(i)
+				bad := append([][]byte{}, ap...)
+				if len(bad) > 0 {
+					bad[len(bad)-1] = hash([]byte("bad"))
+				}
+				for _, table := range []struct {
+					leaf []byte
+					path [][]byte
+				}{
+					{data[i], ap},
+					{[]byte("bad"), ap},
+					{data[i], bad},
+				} {
+					want := mt.MthFromAp(table.leaf, i, n, table.path)
+					got, err := mt.FastMthFromAp(table.leaf, i, n, table.path)
+					if err != nil {
+						t.Errorf("n=%d i=%d => %v", n, i, err)
+					} else if !bytes.Equal(got, want) {
+						t.Errorf("n=%d i=%d => got %x, want %x", n, i, got, want)
+					}
+				}
+			}
+		}
+		if _, err := mt.FastMthFromAp(data[0], n, n, nil); err == nil {
+			t.Errorf("n=%d => accepted out of range index", n)
+		}
+		if n > 1 {
+			if _, err := mt.FastMthFromAp(data[0], 0, n, nil); err == nil {
+				t.Errorf("n=%d => accepted short audit path", n)
+			}
+		}
+	}
+}
+
+func TestMthFromApWithCache(t *testing.T) {
+	// one cache is shared by all tree sizes and tampered inputs
+	cache := make(map[string][]byte)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		n := 1 + rnd.Intn(40)
+		data := leafData(n)
+		mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+		m := rnd.Intn(n)
+		leaf, ap := data[m], mt.Ap(m)
+		switch rnd.Intn(4) {
+		case 0:
+			leaf = data[rnd.Intn(n)]
+		case 1:
+			if len(ap) > 0 {
+				ap = append([][]byte{}, ap...)
+				ap[rnd.Intn(len(ap))] = hash([]byte{byte(rnd.Intn(4))})
+			}
+		}
+		want := mt.MthFromAp(leaf, m, n, ap)
+		got, err := mt.MthFromApWithCache(leaf, m, n, ap, cache)
+		if err != nil {
+			t.Fatalf("n=%d m=%d => %v", n, m, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("n=%d m=%d => got %x, want %x", n, m, got, want)
+		}
+	}
+
+	// a warm cache avoids interior hashes
+	calls := 0
+	counting := func(data ...[]byte) []byte {
+		calls++
+		return hash(data...)
+	}
+	data := leafData(64)
+	mt := NewMerkleTree(testTwc, lp, ip, counting, data)
+	ap := mt.Ap(5)
+	cache = make(map[string][]byte)
+	for _, want := range []int{7, 1} {
+		calls = 0
+		mt.MthFromApWithCache(data[5], 5, len(data), ap, cache)
+		if calls != want {
+			t.Errorf("got %d hash calls, want %d", calls, want)
+		}
+	}
+
+	if _, err := mt.MthFromApWithCache(data[0], 64, 64, nil, cache); err == nil {
+		t.Errorf("accepted out of range index")
+	}
+	if _, err := mt.MthFromApWithCache(data[0], 0, 64, nil, cache); err == nil {
+		t.Errorf("accepted short audit path")
+	}
+}
+
+func TestApAll(t *testing.T) {
+	for n := 0; n <= 256; n++ {
+		data := leafData(n)
+		mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+		r := mt.Mth()
+		paths := mt.ApAll()
+		if len(paths) != n {
+			t.Errorf("Bad number of audit paths => got %v, want %v", len(paths), n)
+			continue
+		}
+		for i := 0; i < n; i++ {
+			if rp := mt.MthFromAp(data[i], i, n, paths[i]); !bytes.Equal(r, rp) {
+				t.Errorf("Bad recomputed root hash =>\ngot:  %v\nwant: %v", rp, r)
+			}
+			ap := mt.Ap(i)
+			if len(ap) != len(paths[i]) {
+				t.Errorf("Bad audit path length => got %v, want %v",
+					len(paths[i]), len(ap))
+				continue
+			}
+			for j := range ap {
+				if !bytes.Equal(ap[j], paths[i][j]) {
+					t.Errorf("Bad audit path hash for leaf %d of %d", i, n)
+				}
+			}
+		}
+	}
+}
+
 func TestRangeAp(t *testing.T) {
 	// Check reconstruct for an empty tree (valid parameters)
 	var d [][]byte
@@ -91,6 +221,300 @@ func TestRangeAp(t *testing.T) {
 			}
 		}
 	}
+
+	// Check that ranges outside of the tree are rejected
+	for _, table := range []struct {
+		data [][]byte
+		i, n int
+	}{
+		{leafData(1), -1, 3},
+		{leafData(1), -10, -5},
+		{nil, 0, 4},
+	} {
+		if _, err := mt.MthFromRangeAp(table.data, table.i, table.n, [][]byte{r}, nil); err == nil {
+			t.Errorf("Invalid range accepted: i=%d n=%d", table.i, table.n)
+		}
+	}
+}
+
+func TestLeaf(t *testing.T) {
+	data := leafData(5)
+	mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+	r := mt.Mth()
+	if mt.Size() != len(data) {
+		t.Errorf("Bad size => got %v, want %v", mt.Size(), len(data))
+	}
+	for i := range data {
+		l, err := mt.Leaf(i)
+		if err != nil {
+			t.Errorf("Valid index %d rejected: %v", i, err)
+		} else if !bytes.Equal(l, data[i]) {
+			t.Errorf("Bad leaf %d => got %v, want %v", i, l, data[i])
+		}
+		l[0] ^= 0xff // must not alias tree data
+	}
+	for _, i := range []int{-1, len(data)} {
+		if _, err := mt.Leaf(i); err == nil {
+			t.Errorf("Invalid index %d accepted", i)
+		}
+	}
+	leaves := mt.Leaves()
+	for i := range leaves {
+		leaves[i][0] ^= 0xff
+	}
+	if !bytes.Equal(data[0], []byte("1")) {
+		t.Errorf("Leaf data modified through a copy")
+	}
+	mt.Release()
+	if rp := mt.Mth(); !bytes.Equal(r, rp) {
+		t.Errorf("Bad root hash after copies =>\ngot:  %v\nwant: %v", rp, r)
+	}
+}
+
+func TestClone(t *testing.T) {
+	calls := 0
+	counting := func(data ...[]byte) []byte {
+		calls++
+		return hash(data...)
+	}
+	data := leafData(13)
+	mt := NewMerkleTree(testTwc, lp, ip, counting, data)
+	r := mt.Mth()
+	clone := mt.Clone()
+	data[0][0] ^= 0xff // must not affect the clone
+	mt.data[1] = []byte("modified")
+
+	calls = 0
+	if rp := clone.Mth(); !bytes.Equal(r, rp) {
+		t.Errorf("Bad cloned root hash =>\ngot:  %v\nwant: %v", rp, r)
+	}
+	for i := 0; i < clone.Size(); i++ {
+		clone.Ap(i)
+	}
+	if calls != 0 {
+		t.Errorf("Clone recomputed %d cached hashes", calls)
+	}
+	if !bytes.Equal(mt.Mth(), clone.Mth()) {
+		t.Errorf("Bad root hashes after clone")
+	}
+	for i, d := range leafData(13) {
+		if !bytes.Equal(clone.data[i], d) {
+			t.Errorf("Bad cloned leaf %d => got %v, want %v", i, clone.data[i], d)
+		}
+	}
+	clone.Release()
+	if rp := clone.Mth(); !bytes.Equal(r, rp) {
+		t.Errorf("Bad cloned root hash after release =>\ngot:  %v\nwant: %v", rp, r)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	data := leafData(9)
+	mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+	if !mt.Equal(mt.Clone()) || !mt.Equal(NewMerkleTree(testTwc, lp, ip, hash, leafData(9))) {
+		t.Errorf("Equal trees are not equal")
+	}
+
+	other := leafData(9)
+	other[4] = []byte("x")
+	for _, table := range []struct {
+		desc      string
+		mt        *MerkleTree
+		dataEqual bool
+	}{
+		{"one leaf differs", NewMerkleTree(testTwc, lp, ip, hash, other), false},
+		{"fewer leaves", NewMerkleTree(testTwc, lp, ip, hash, data[:8]), false},
+		{"twc differs", NewMerkleTree([]byte{0}, lp, ip, hash, data), true},
+		{"leaf prefix differs", NewMerkleTree(testTwc, ip, ip, hash, data), true},
+		{"interior prefix differs", NewMerkleTree(testTwc, lp, lp, hash, data), true},
+		{"hash differs", NewMerkleTree(testTwc, lp, ip, BLAKE3Hash, data), true},
+		{"nil tree", nil, false},
+	} {
+		if mt.Equal(table.mt) {
+			t.Errorf("%s: Equal => got true, want false", table.desc)
+		}
+		if got := mt.DataEqual(table.mt); got != table.dataEqual {
+			t.Errorf("%s: DataEqual => got %v, want %v", table.desc, got,
+				table.dataEqual)
+		}
+	}
+}
+
+func TestMthParallelConsistency(t *testing.T) {
+	for n := 1; n <= 1024; n++ {
+		data := leafData(n)
+		want := NewMerkleTree(testTwc, lp, ip, hash, data).Mth()
+		for _, workers := range []int{1, 2, 8} {
+			mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+			if root := mt.MthParallel(workers); !bytes.Equal(root, want) {
+				t.Errorf("Bad parallel root hash for %d leaves =>\ngot:  %v\nwant: %v",
+					n, root, want)
+			}
+			if i := n / 2; !bytes.Equal(mt.MthFromAp(data[i], i, n, mt.Ap(i)), want) {
+				t.Errorf("Bad audit path after parallel root hash for %d leaves", n)
+			}
+		}
+	}
+}
+
+func TestNodeCounts(t *testing.T) {
+	for _, table := range []struct {
+		leaves, interior int
+	}{
+		{1, 0}, {2, 1}, {3, 2}, {4, 3}, {7, 6}, {8, 7}, {9, 8},
+	} {
+		mt := NewMerkleTree(testTwc, lp, ip, hash, leafData(table.leaves))
+		if got := mt.NumLeaves(); got != table.leaves {
+			t.Errorf("size %d => got %d leaves", table.leaves, got)
+		}
+		if got := mt.NumInteriorNodes(); got != table.interior {
+			t.Errorf("size %d => got %d interior nodes, want %d", table.leaves,
+				got, table.interior)
+		}
+		total := table.leaves + table.interior
+		if got := mt.TotalNodes(); got != total {
+			t.Errorf("size %d => got %d nodes, want %d", table.leaves, got, total)
+		}
+		if got := mt.CachedNodeCount(); got != 0 {
+			t.Errorf("size %d => got %d cached nodes before Mth", table.leaves, got)
+		}
+		mt.Mth()
+		if got := mt.CachedNodeCount(); got != total {
+			t.Errorf("size %d => got %d cached nodes, want %d", table.leaves, got,
+				total)
+		}
+	}
+}
+
+func TestPreallocHashCache(t *testing.T) {
+	for n := 0; n <= 70; n++ {
+		data := leafData(n)
+		lazy := NewMerkleTree(testTwc, lp, ip, hash, data)
+		lazy.cache = newHashCache()
+		mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+		if got, want := mt.Mth(), lazy.Mth(); !bytes.Equal(got, want) {
+			t.Errorf("size %d => got root %x, want %x", n, got, want)
+		}
+		if got, want := mt.CachedNodeCount(), lazy.CachedNodeCount(); got != want {
+			t.Errorf("size %d => got %d cached nodes, want %d", n, got, want)
+		}
+		if got, want := fmt.Sprint(mt.ApAll()), fmt.Sprint(lazy.ApAll()); got != want {
+			t.Errorf("size %d => audit paths differ", n)
+		}
+		var got, want bytes.Buffer
+		mt.DebugTree(&got)
+		lazy.DebugTree(&want)
+		if got.String() != want.String() {
+			t.Errorf("size %d => cache trees differ", n)
+		}
+	}
+}
+
+func TestRelease(t *testing.T) {
+	// run with -race to detect data races related to the hash cache pool
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := g; n <= 64; n += 8 {
+				data := leafData(n)
+				mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+				r := mt.Mth()
+				mt.Release()
+				if rp := mt.Mth(); !bytes.Equal(r, rp) {
+					t.Errorf("Bad root hash after release =>\ngot:  %v\nwant: %v", rp, r)
+				}
+				for i := range data {
+					if rp := mt.MthFromAp(data[i], i, n, mt.Ap(i)); !bytes.Equal(r, rp) {
+						t.Errorf("Bad audit path after release for leaf %d of %d", i, n)
+					}
+				}
+				mt.Release()
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestReleasePooledNodes(t *testing.T) {
+	pooled := func(c *hashCache) (n, m int) {
+		var walk func(c *hashCache)
+		walk = func(c *hashCache) {
+			if c == nil {
+				return
+			}
+			if c.pooled {
+				n++
+			} else {
+				m++
+			}
+			walk(c.left)
+			walk(c.right)
+		}
+		walk(c)
+		return
+	}
+	mt := NewMerkleTree(testTwc, lp, ip, hash, leafData(10))
+	mt.Mth()
+	if n, m := pooled(mt.cache); n != 0 || m != 19 {
+		t.Errorf("got %d pooled and %d preallocated nodes, want 0 and 19", n, m)
+	}
+	prealloc := mt.cache.left
+	mt.Release()
+	if prealloc.pooled || prealloc.left != nil {
+		t.Errorf("preallocated node was not reset")
+	}
+	mt.Mth()
+	if n, m := pooled(mt.cache); n != 19 || m != 0 {
+		t.Errorf("got %d pooled and %d preallocated nodes, want 19 and 0", n, m)
+	}
+}
+
+func BenchmarkMthAllocations(b *testing.B) {
+	data := leafData(50000)
+	b.Run("NoRelease", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			NewMerkleTree(testTwc, lp, ip, hash, data).Mth()
+		}
+	})
+	b.Run("Release", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+			mt.Mth()
+			mt.Release()
+		}
+	})
+}
+
+func BenchmarkMthParallel(b *testing.B) {
+	data := leafData(1 << 16)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewMerkleTree(testTwc, lp, ip, hash, data).MthParallel(workers)
+			}
+		})
+	}
+}
+
+func BenchmarkApAll(b *testing.B) {
+	mt := NewMerkleTree(testTwc, lp, ip, hash, leafData(4096))
+	mt.Mth()
+	b.Run("Ap", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < len(mt.data); j++ {
+				mt.Ap(j)
+			}
+		}
+	})
+	b.Run("ApAll", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			mt.ApAll()
+		}
+	})
 }
 
 // Manually computed roots
@@ -124,3 +548,164 @@ func leafData(n int) (data [][]byte) {
 	}
 	return data
 }
+
+func FuzzMthFromRangeAp(f *testing.F) {
+	// seed with the valid parameters from TestRangeAp, encoding data as leaves
+	// separated by commas and audit paths as concatenated hashes
+	f.Add([]byte{}, -1, 0, []byte{}, []byte{}, uint8(0))
+	f.Add([]byte("1"), 0, 1, []byte{}, []byte{}, uint8(fuzzData))
+	f.Add([]byte("1"), math.MaxInt, 5, []byte{}, []byte{}, uint8(fuzzData))
+	for leaves := 2; leaves <= 8; leaves++ {
+		d := leafData(leaves)
+		n := len(d)
+		mt := NewMerkleTree(testTwc, lp, ip, hash, d)
+		for i := 0; i < n; i++ {
+			for j := i + 1; j <= n; j++ {
+				if j-i > 1 || i == 0 || j == n {
+					flags := uint8(fuzzData)
+					var lAp, rAp []byte
+					if i != 0 {
+						flags |= fuzzLeftAP
+						lAp = concat(mt.Ap(i))
+					}
+					if j != n {
+						flags |= fuzzRightAP
+						rAp = concat(mt.Ap(j - 1))
+					}
+					f.Add(bytes.Join(d[i:j], []byte(",")), i, n, lAp, rAp, flags)
+				}
+			}
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte, i, n int, lAp, rAp []byte,
+		flags uint8) {
+		// bound the inputs, since the fuzzer otherwise spends its time minimizing
+		// large inputs that reach no new code; indices close to math.MaxInt are
+		// kept to catch overflows
+		if n > fuzzMaxSize || (i > fuzzMaxSize && i < math.MaxInt-fuzzMaxSize) ||
+			len(data) > fuzzMaxSize || len(lAp) > fuzzMaxAP ||
+			len(rAp) > fuzzMaxAP {
+			return
+		}
+		var d, l, r [][]byte
+		if flags&fuzzData != 0 {
+			d = bytes.Split(data, []byte(","))
+		}
+		if flags&fuzzLeftAP != 0 {
+			l = fuzzHashes(lAp)
+		}
+		if flags&fuzzRightAP != 0 {
+			r = fuzzHashes(rAp)
+		}
+		mt := NewMerkleTree(testTwc, lp, ip, hash, nil)
+		h, err := mt.MthFromRangeAp(d, i, n, l, r)
+		if err == nil && h == nil {
+			t.Errorf("no error but also no root hash")
+		}
+		if err == nil && n > 0 && (i < 0 || i > n-len(d)) {
+			t.Errorf("accepted %d leaves at index %d in a tree of size %d", len(d),
+				i, n)
+		}
+	})
+}
+
+const (
+	fuzzData    = 1 << 0
+	fuzzLeftAP  = 1 << 1
+	fuzzRightAP = 1 << 2
+
+	fuzzMaxSize = 1 << 10      // max tree size, index, and data length
+	fuzzMaxAP   = 16 * hashLen // max audit path length in bytes
+)
+
+// fuzzHashes splits b into hashLen chunks, keeping a shorter trailing chunk
+func fuzzHashes(b []byte) [][]byte {
+	hashes := [][]byte{}
+	for len(b) > hashLen {
+		hashes, b = append(hashes, b[:hashLen]), b[hashLen:]
+	}
+	return append(hashes, b)
+}
+
+func BenchmarkFastMthFromAp(b *testing.B) {
+	data := leafData(1024)
+	mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+	mt.Mth()
+	rnd := rand.New(rand.NewSource(1))
+	indices := make([]int, 1000)
+	paths := make([][][]byte, len(indices))
+	for i := range indices {
+		indices[i] = rnd.Intn(len(data))
+		paths[i] = mt.Ap(indices[i])
+	}
+	b.Run("MthFromAp", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j, m := range indices {
+				mt.MthFromAp(data[m], m, len(data), paths[j])
+			}
+		}
+	})
+	b.Run("FastMthFromAp", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j, m := range indices {
+				mt.FastMthFromAp(data[m], m, len(data), paths[j])
+			}
+		}
+	})
+}
+
+func BenchmarkMthFromApWithCache(b *testing.B) {
+	data := leafData(4096)
+	mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+	mt.Mth()
+	rnd := rand.New(rand.NewSource(1))
+	indices := make([]int, 1000)
+	paths := make([][][]byte, len(indices))
+	for i := range indices {
+		indices[i] = rnd.Intn(len(data))
+		paths[i] = mt.Ap(indices[i])
+	}
+	verify := func(cache map[string][]byte) {
+		for j, m := range indices {
+			mt.MthFromApWithCache(data[m], m, len(data), paths[j], cache)
+		}
+	}
+	b.Run("None", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			verify(nil)
+		}
+	})
+	b.Run("Cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			verify(make(map[string][]byte))
+		}
+	})
+	b.Run("Warm", func(b *testing.B) {
+		cache := make(map[string][]byte)
+		verify(cache)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			verify(cache)
+		}
+	})
+}
+
+func BenchmarkPreallocHashCache(b *testing.B) {
+	for _, n := range []int{256, 1024, 4096} {
+		data := leafData(n)
+		b.Run(fmt.Sprintf("Lazy/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+				mt.cache = newHashCache()
+				mt.Mth()
+			}
+		})
+		b.Run(fmt.Sprintf("Prealloc/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				NewMerkleTree(testTwc, lp, ip, hash, data).Mth()
+			}
+		})
+	}
+}
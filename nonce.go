@@ -0,0 +1,57 @@
+package lwm
+
+import (
+	"fmt"
+)
+
+// WithLeafNonce makes a tree prefix the data of each leaf with a nonce, such
+// that a leaf is hashed as h(twc, leafPrefix, nonce, key, h(payload...)) with
+// nonce = fn(key). If nonces are secret, e.g., fn(key) = h(secret, key), it is
+// not possible to test guessed keys against leaf hashes. Every nonce must have
+// the same length as fn(""). Proofs carry the nonces of their left and right
+// leaves, and verifiers need fn to recompute matching leaves, see
+// WithNonceProvider.
+func WithLeafNonce(fn func(key string) []byte) WildcardTreeOption {
+	return func(wt *WildcardTree) {
+		wt.nonce = fn
+	}
+}
+
+// leafData outputs the leaf data for a key and a payload hash
+func (wt *WildcardTree) leafData(key string, ph []byte) ([]byte, error) {
+	return mkLeaf(wt.nonce, wt.nonceLength, key, ph)
+}
+
+// leafData outputs the leaf data for a key and a payload hash
+func (p Proof) leafData(key string, ph []byte) ([]byte, error) {
+	if p.nonceLength > 0 && p.nonce == nil {
+		return nil, verificationError(KindMalformedData,
+			"proof has leaf nonces, but there is no nonce provider")
+	}
+	data, err := mkLeaf(p.nonce, p.nonceLength, key, ph)
+	if err != nil {
+		return nil, verificationError(KindMalformedData, "%v", err)
+	}
+	return data, nil
+}
+
+// mkLeaf outputs nonce(key) + key + ph, where the nonce must have length n
+func mkLeaf(nonce func(key string) []byte, n int, key string,
+	ph []byte) ([]byte, error) {
+	if nonce == nil || n == 0 {
+		return append([]byte(key), ph...), nil
+	}
+	data := nonce(key)
+	if len(data) != n {
+		return nil, fmt.Errorf("nonce of key %q has length %d, want %d", key,
+			len(data), n)
+	}
+	data = append(data[:n:n], key...)
+	return append(data, ph...), nil
+}
+
+// NonceLength outputs the length of the leaf nonces that the proof uses, see
+// WithLeafNonce, and zero if there are none
+func (p Proof) NonceLength() int {
+	return p.nonceLength
+}
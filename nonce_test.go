@@ -0,0 +1,97 @@
+package lwm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/golang/example/stringutil"
+	"testing"
+)
+
+func TestWithLeafNonce(t *testing.T) {
+	nonce := func(secret string) func(key string) []byte {
+		return func(key string) []byte {
+			return hash([]byte(secret), []byte(key))
+		}
+	}
+	wt1 := MustNewWildcardTree(twc, hash, testData(),
+		WithLeafNonce(nonce("secret 1")))
+	wt2 := MustNewWildcardTree(twc, hash, testData(),
+		WithLeafNonce(nonce("secret 2")))
+	plain := MustNewWildcardTree(twc, hash, testData())
+	if bytes.Equal(wt1.Snapshot(), wt2.Snapshot()) ||
+		bytes.Equal(wt1.Snapshot(), plain.Snapshot()) {
+		t.Fatalf("nonces do not change the snapshot")
+	}
+	leaf := wt1.mt.data[0]
+	if want := nonce("secret 1")(wt1.leafKey(leaf)); !bytes.HasPrefix(leaf, want) {
+		t.Errorf("leaf data %x does not start with nonce %x", leaf, want)
+	}
+
+	for _, name := range []string{"foo.com", "sub1.foo.com", "bar.se", "zzz"} {
+		key := stringutil.Reverse(name)
+		answer, proof := wt1.Get(key)
+		want, _ := plain.Get(key)
+		if len(answer.Subjects()) != len(want.Subjects()) {
+			t.Errorf("%s => got %d matches, want %d", name,
+				len(answer.Subjects()), len(want.Subjects()))
+		}
+		if err := proof.VerifyWithOptions(key, answer, wt1.Size(), wt1.Snapshot(),
+			WithNonceProvider(nonce("secret 1"))); err != nil {
+			t.Errorf("%s => %v", name, err)
+		}
+
+		b, err := json.Marshal(proof)
+		if err != nil {
+			t.Fatalf("%s => marshal failed: %v", name, err)
+		}
+		var pp Proof
+		if err := json.Unmarshal(b, &pp); err != nil {
+			t.Fatalf("%s => unmarshal failed: %v", name, err)
+		}
+		if err := pp.VerifyWithOptions(key, answer, wt1.Size(), wt1.Snapshot(),
+			WithNonceProvider(nonce("secret 1"))); err != nil {
+			t.Errorf("%s => JSON round-trip: %v", name, err)
+		}
+		if len(answer.Subjects()) == 0 {
+			continue
+		}
+
+		err = proof.Verify(key, answer, wt1.Size(), wt1.Snapshot())
+		if verr := (*VerificationError)(nil); !errors.As(err, &verr) ||
+			verr.Kind != KindMalformedData {
+			t.Errorf("%s => got %v without nonce provider", name, err)
+		}
+		if err := proof.VerifyWithOptions(key, answer, wt1.Size(),
+			wt1.Snapshot(), WithNonceProvider(nonce("secret 2"))); err == nil {
+			t.Errorf("%s => verified with the wrong nonces", name)
+		}
+		if err := proof.VerifyWithOptions(key, answer, wt2.Size(),
+			wt2.Snapshot(), WithNonceProvider(nonce("secret 2"))); err == nil {
+			t.Errorf("%s => verified against another tree", name)
+		}
+	}
+
+	np, err := wt1.GetAbsenceProof(stringutil.Reverse("bar.se"))
+	if err != nil || !np.VerifyAbsence(stringutil.Reverse("bar.se"),
+		wt1.Snapshot()) {
+		t.Errorf("absence proof failed: %v", err)
+	}
+	if err := wt1.Add("moc.rab", [][]byte{[]byte("bar.com cert")}); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if got := wt1.Compact().Snapshot(); !bytes.Equal(got, wt1.Snapshot()) {
+		t.Errorf("compact => got snapshot %x, want %x", got, wt1.Snapshot())
+	}
+
+	short := func(key string) []byte {
+		if key == "moc.oof" {
+			return []byte("short")
+		}
+		return hash([]byte(key))
+	}
+	if _, err := NewWildcardTree(twc, hash, testData(),
+		WithLeafNonce(short)); err == nil {
+		t.Errorf("accepted nonces of different lengths")
+	}
+}
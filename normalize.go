@@ -0,0 +1,43 @@
+package lwm
+
+import (
+	"bytes"
+)
+
+// Normalize outputs the proof in a canonical form, such that proofs for the
+// same statement are equal and have the same encodings. Default prefixes and
+// empty audit paths are nil, trailing nil hashes are trimmed from audit paths,
+// and a negative index is -1, i.e., the index of an empty tree.
+//
+// A non-negative index is not moved to the left-most match. Verify needs the
+// index of the first leaf that the proof covers, which is the left leaf if
+// there is one. The left-most match is then the next index, so each of them
+// determines the other and the index of a verifying proof is already unique.
+func (p Proof) Normalize() Proof {
+	if p.index < 0 {
+		p.index = -1
+	}
+	if len(p.twc) == 0 {
+		p.twc = nil
+	}
+	if bytes.Equal(p.leafPrefix, leafPrefix) {
+		p.leafPrefix = nil
+	}
+	if bytes.Equal(p.interiorPrefix, interiorPrefix) {
+		p.interiorPrefix = nil
+	}
+	p.lap, p.rap = normalizeAp(p.lap), normalizeAp(p.rap)
+	return p
+}
+
+// normalizeAp outputs an audit path without trailing nil hashes, or nil if
+// no hashes remain
+func normalizeAp(ap [][]byte) [][]byte {
+	for len(ap) > 0 && ap[len(ap)-1] == nil {
+		ap = ap[:len(ap)-1]
+	}
+	if len(ap) == 0 {
+		return nil
+	}
+	return ap[:len(ap):len(ap)]
+}
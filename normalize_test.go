@@ -0,0 +1,130 @@
+package lwm
+
+import (
+	"bytes"
+	"github.com/golang/example/stringutil"
+	"testing"
+)
+
+func TestProofNormalize(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	for _, key := range []string{"", stringutil.Reverse("foo.com"),
+		stringutil.Reverse("sub0.foo.com"), "zzz"} {
+		answer, proof := wt.Get(key)
+		variant := proof
+		variant.leafPrefix = []byte{0x00}
+		variant.interiorPrefix = []byte{0x01}
+		if variant.lap == nil {
+			variant.lap = [][]byte{}
+		} else {
+			variant.lap = append(append([][]byte{}, variant.lap...), nil)
+		}
+		if variant.rap == nil {
+			variant.rap = [][]byte{nil}
+		}
+
+		p, q := proof.Normalize(), variant.Normalize()
+		if !equalProofs(p, q) {
+			t.Errorf("%q => normalized proofs differ", key)
+		}
+		if !equalProofs(p, p.Normalize()) {
+			t.Errorf("%q => normalization is not idempotent", key)
+		}
+		b, err := p.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c, _ := q.Marshal(); !bytes.Equal(b, c) {
+			t.Errorf("%q => encodings differ", key)
+		}
+		for _, p := range []Proof{proof, p, q} {
+			if err := p.Verify(key, answer, wt.Size(), wt.Snapshot()); err != nil {
+				t.Errorf("%q => %v", key, err)
+			}
+		}
+	}
+
+	empty := MustNewWildcardTree(twc, hash, nil)
+	answer, proof := empty.Get("a")
+	variant := proof
+	variant.index = -7
+	if p := variant.Normalize(); !equalProofs(p, proof.Normalize()) ||
+		p.Verify("a", answer, 0, empty.Snapshot()) != nil {
+		t.Errorf("empty tree => bad normalized proof")
+	}
+}
+
+func TestProofNormalizeEncodings(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	key := stringutil.Reverse("baz.gov") // last leaf, i.e., no right path
+	answer, proof := wt.Get(key)
+	if proof.rap != nil {
+		t.Fatalf("proof has a right audit path")
+	}
+	variant := proof
+	variant.rap = [][]byte{}
+
+	// the two encodings differ, but decode to the same normalized proof
+	b, err := proof.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := variant.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(b, c) {
+		t.Fatalf("encodings are equal before normalization")
+	}
+	var p, q Proof
+	if err := p.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Unmarshal(c); err != nil {
+		t.Fatal(err)
+	}
+	if !equalProofs(p.Normalize(), q.Normalize()) {
+		t.Errorf("normalized proofs differ")
+	}
+	if d, _ := q.Normalize().Marshal(); !bytes.Equal(b, d) {
+		t.Errorf("normalized encodings differ")
+	}
+
+	// the index refers to the left leaf, not the left-most match
+	if proof.ll == nil {
+		t.Fatalf("proof has no left leaf")
+	}
+	if err := proof.Normalize().Verify(key, answer, wt.Size(),
+		wt.Snapshot()); err != nil {
+		t.Errorf("normalized proof => %v", err)
+	}
+	shifted := proof
+	shifted.index++
+	if shifted.Verify(key, answer, wt.Size(), wt.Snapshot()) == nil {
+		t.Errorf("proof with the left-most match as index verified")
+	}
+}
+
+// equalProofs outputs true if two proofs have equal fields, except for their
+// hash functions and nonce providers which are not comparable
+func equalProofs(p, q Proof) bool {
+	equal := func(a, b []byte) bool {
+		return bytes.Equal(a, b) && (a == nil) == (b == nil)
+	}
+	equalAp := func(a, b [][]byte) bool {
+		if len(a) != len(b) || (a == nil) != (b == nil) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return p.index == q.index && p.hashLength == q.hashLength &&
+		p.nonceLength == q.nonceLength && equal(p.twc, q.twc) &&
+		equal(p.ll, q.ll) && equal(p.rl, q.rl) && equalAp(p.lap, q.lap) &&
+		equalAp(p.rap, q.rap) && equal(p.leafPrefix, q.leafPrefix) &&
+		equal(p.interiorPrefix, q.interiorPrefix)
+}
@@ -0,0 +1,124 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WildcardTreeOption configures optional behavior of a WildcardTree
+type WildcardTreeOption func(*WildcardTree)
+
+// WithKeyValidator makes a tree reject keys for which fn outputs an error.
+// Keys are validated after normalization, see WithKeyNormalizer.
+func WithKeyValidator(fn func(key string) error) WildcardTreeOption {
+	return func(wt *WildcardTree) {
+		wt.validate = fn
+	}
+}
+
+// WithKeyNormalizer makes a tree normalize keys before insertion and before
+// every look-up. Verifiers must apply the same normalizer to query keys.
+func WithKeyNormalizer(fn func(key string) string) WildcardTreeOption {
+	return func(wt *WildcardTree) {
+		wt.normalize = fn
+	}
+}
+
+// WithBlindedPayloads makes a tree store payload hashes rather than payloads,
+// e.g., because payloads are sensitive. Answers from Get and similar methods
+// then have nil payloads and do not verify, but the tree can confirm payloads
+// that are provided by someone else, see Reveal. Blinded trees cannot be
+// encoded with Marshal or WriteCSV.
+func WithBlindedPayloads() WildcardTreeOption {
+	return func(wt *WildcardTree) {
+		wt.blinded = true
+	}
+}
+
+// WithLeafPrefix replaces the default leaf prefix 0x00, e.g., to match the
+// hashing of another protocol. Proofs carry the prefix so that Verify works,
+// but they cannot be encoded. VerifyConsistency and VerifySize assume the
+// default prefixes.
+func WithLeafPrefix(b []byte) WildcardTreeOption {
+	return func(wt *WildcardTree) {
+		wt.mt.leafPrefix = cloneBytes(b)
+	}
+}
+
+// WithInteriorPrefix replaces the default interior prefix 0x01, see
+// WithLeafPrefix
+func WithInteriorPrefix(b []byte) WildcardTreeOption {
+	return func(wt *WildcardTree) {
+		wt.mt.interiorPrefix = cloneBytes(b)
+	}
+}
+
+// checkPrefixes outputs an error if neither prefix separates leaf hashes from
+// interior hashes, which is the case if one is a prefix of the other
+func checkPrefixes(lp, ip []byte) error {
+	if bytes.HasPrefix(lp, ip) || bytes.HasPrefix(ip, lp) {
+		return fmt.Errorf("leaf prefix %x and interior prefix %x overlap", lp, ip)
+	}
+	return nil
+}
+
+// ReversedLowercaseDomain is a key normalizer that takes a domain name in
+// regular order, lowercases it, strips a trailing dot, and reverses it, e.g.,
+// "Foo.COM." -> "moc.oof"
+func ReversedLowercaseDomain(domain string) string {
+	return reverse(strings.TrimSuffix(strings.ToLower(domain), "."))
+}
+
+// normalizeKey outputs key as normalized by the tree's normalizer (if any)
+func (wt *WildcardTree) normalizeKey(key string) string {
+	if wt.normalize == nil {
+		return key
+	}
+	return wt.normalize(key)
+}
+
+// validateKey outputs an error if the tree's validator (if any) rejects key
+func (wt *WildcardTree) validateKey(key string) error {
+	if wt.validate == nil {
+		return nil
+	}
+	if err := wt.validate(key); err != nil {
+		return fmt.Errorf("invalid key %q: %w", key, err)
+	}
+	return nil
+}
+
+// prepareKeys outputs m with normalized keys, or an error that aggregates all
+// invalid keys and keys that are equal after normalization
+func (wt *WildcardTree) prepareKeys(m map[string]interface{}) (
+	map[string]interface{}, error) {
+	if wt.normalize == nil && wt.validate == nil {
+		return m, nil
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // deterministic error order
+
+	var errs []error
+	out := make(map[string]interface{}, len(m))
+	from := make(map[string]string, len(m))
+	for _, key := range keys {
+		nkey := wt.normalizeKey(key)
+		if err := wt.validateKey(nkey); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if prev, ok := from[nkey]; ok {
+			errs = append(errs, fmt.Errorf("keys %q and %q both normalize to %q",
+				prev, key, nkey))
+			continue
+		}
+		out[nkey], from[nkey] = m[key], key
+	}
+	return out, errors.Join(errs...)
+}
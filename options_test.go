@@ -0,0 +1,177 @@
+package lwm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestReversedLowercaseDomain(t *testing.T) {
+	for _, table := range []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"foo.com", "moc.oof"},
+		{"Foo.COM.", "moc.oof"},
+		{"sub.räksmörgås.se", "es.sågrömskär.bus"},
+	} {
+		if got := ReversedLowercaseDomain(table.in); got != table.want {
+			t.Errorf("%q => got %q, want %q", table.in, got, table.want)
+		}
+	}
+}
+
+func TestWithKeyNormalizer(t *testing.T) {
+	m := map[string]interface{}{
+		"Foo.com.":     [][]byte{[]byte("foo.com cert")},
+		"SUB.foo.com":  [][]byte{[]byte("sub.foo.com cert")},
+		"bar.example.": [][]byte{[]byte("bar.example cert")},
+	}
+	wt := MustNewWildcardTree(twc, hash, m,
+		WithKeyNormalizer(ReversedLowercaseDomain))
+	snapshot := wt.Snapshot()
+	for _, table := range []struct {
+		key string
+		n   int
+	}{
+		{"foo.com", 2},
+		{"FOO.COM.", 2},
+		{"sub.foo.com", 1},
+		{"baz.example", 0},
+	} {
+		if got := wt.Contains(table.key); got != (table.n > 0) {
+			t.Errorf("%q: contains => got %v", table.key, got)
+		}
+		answer, proof := wt.Get(table.key)
+		if got := len(answer.Subjects()); got != table.n {
+			t.Errorf("%q: got %d matches, want %d", table.key, got, table.n)
+		}
+		key := ReversedLowercaseDomain(table.key) // verifier normalizes too
+		if err := proof.Verify(key, answer, wt.Size(), snapshot); err != nil {
+			t.Errorf("%q: valid proof rejected: %v", table.key, err)
+		}
+	}
+	if !wt.ContainsExact("Sub.Foo.Com") {
+		t.Errorf("exact look-up is not normalized")
+	}
+	if err := wt.Add("foo.COM", [][]byte{}); err != ErrKeyExists {
+		t.Errorf("add => got error %v, want %v", err, ErrKeyExists)
+	}
+	if err := wt.Remove("SUB.FOO.COM"); err != nil {
+		t.Errorf("remove => got error %v", err)
+	}
+
+	// keys that collide after normalization
+	m["foo.com"] = [][]byte{}
+	if _, err := NewWildcardTree(twc, hash, m,
+		WithKeyNormalizer(ReversedLowercaseDomain)); err == nil {
+		t.Errorf("accepted keys that collide after normalization")
+	}
+}
+
+func TestWithKeyValidator(t *testing.T) {
+	errUpper := errors.New("uppercase letter")
+	validator := func(key string) error {
+		if !utf8.ValidString(key) {
+			return errors.New("invalid UTF-8")
+		}
+		if strings.ToLower(key) != key {
+			return errUpper
+		}
+		return nil
+	}
+	m := testData()
+	wt, err := NewWildcardTree(twc, hash, m, WithKeyValidator(validator))
+	if err != nil {
+		t.Fatalf("valid keys rejected: %v", err)
+	}
+	if err := wt.Add("moc.OOF", [][]byte{}); !errors.Is(err, errUpper) {
+		t.Errorf("add => got error %v, want %v", err, errUpper)
+	}
+
+	m["moc.OOF"] = [][]byte{}
+	m["moc.\xff"] = [][]byte{}
+	_, err = NewWildcardTree(twc, hash, m, WithKeyValidator(validator))
+	if !errors.Is(err, errUpper) {
+		t.Errorf("got error %v, want %v", err, errUpper)
+	}
+	if err == nil || !strings.Contains(err.Error(), "invalid UTF-8") {
+		t.Errorf("errors are not aggregated: %v", err)
+	}
+}
+
+func TestWithPrefixes(t *testing.T) {
+	lp, ip := []byte{0x10}, []byte{0x11}
+	def := MustNewWildcardTree(twc, hash, testData())
+	wt := MustNewWildcardTree(twc, hash, testData(), WithLeafPrefix(lp),
+		WithInteriorPrefix(ip))
+	if bytes.Equal(wt.Snapshot(), def.Snapshot()) {
+		t.Errorf("prefixes did not change the snapshot")
+	}
+	want := NewMerkleTree(twc, lp, ip, hash, def.mt.data).Mth()
+	if !bytes.Equal(wt.Snapshot(), want) {
+		t.Errorf("got snapshot %x, want %x", wt.Snapshot(), want)
+	}
+	if !bytes.Equal(wt.Compact().Snapshot(), want) {
+		t.Errorf("compaction did not keep the prefixes")
+	}
+
+	for _, key := range []string{"", "moc.oof", "moc.oof.1bus", "moc.rab",
+		"zzz"} {
+		a, p := wt.Get(key)
+		if err := p.Verify(key, a, wt.Size(), wt.Snapshot()); err != nil {
+			t.Errorf("key %q => valid proof rejected: %v", key, err)
+		}
+		if errs := BatchVerify([]VerifyEntry{{key, a, p}}, wt.Size(),
+			wt.Snapshot()); errs[0] != nil {
+			t.Errorf("key %q => valid batch entry rejected: %v", key, errs[0])
+		}
+		if !bytes.Equal(p.LeafPrefix(), lp) || !bytes.Equal(p.InteriorPrefix(), ip) {
+			t.Errorf("key %q => got prefixes %x and %x", key, p.LeafPrefix(),
+				p.InteriorPrefix())
+		}
+		p.leafPrefix, p.interiorPrefix = nil, nil
+		if p.Verify(key, a, wt.Size(), wt.Snapshot()) == nil {
+			t.Errorf("key %q => proof with default prefixes accepted", key)
+		}
+	}
+	np, err := wt.GetAbsenceProof("moc.rab")
+	if err != nil || !np.VerifyAbsence("moc.rab", wt.Snapshot()) {
+		t.Errorf("valid absence proof rejected: %v", err)
+	}
+
+	// encodings only support the default prefixes
+	_, p := wt.Get("moc.oof")
+	if _, err := p.Marshal(); err == nil {
+		t.Errorf("binary encoding accepted custom prefixes")
+	}
+	if _, err := json.Marshal(p); err == nil {
+		t.Errorf("JSON encoding accepted custom prefixes")
+	}
+	if _, err := CompressProofs([]Proof{p}).Marshal(); err == nil {
+		t.Errorf("compressed encoding accepted custom prefixes")
+	}
+	if _, err := wt.Marshal("sha256"); err == nil {
+		t.Errorf("tree encoding accepted custom prefixes")
+	}
+
+	// prefixes must separate leaves from interior nodes
+	for _, table := range []struct {
+		lp, ip []byte
+	}{
+		{[]byte{0x00}, []byte{0x00}},
+		{[]byte{0x00}, []byte{0x00, 0x01}},
+		{[]byte{0x01, 0x00}, []byte{0x01}},
+		{[]byte{}, []byte{0x01}},
+		{nil, []byte{0x01}},
+	} {
+		if _, err := NewWildcardTree(twc, hash, testData(),
+			WithLeafPrefix(table.lp), WithInteriorPrefix(table.ip)); err == nil {
+			t.Errorf("accepted leaf prefix %x and interior prefix %x", table.lp,
+				table.ip)
+		}
+	}
+}
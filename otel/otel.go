@@ -0,0 +1,79 @@
+// Package otel traces wildcard tree look-ups and proof verification with
+// OpenTelemetry
+package otel
+
+import (
+	"context"
+	"github.com/rgdd/lwm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span names and attribute keys
+const (
+	GetSpan    = "lwm.WildcardTree.Get"
+	VerifySpan = "lwm.Proof.Verify"
+
+	KeyAttribute        = attribute.Key("lwm.key")
+	MatchCountAttribute = attribute.Key("lwm.match_count")
+	TreeSizeAttribute   = attribute.Key("lwm.tree_size")
+	VerifiedAttribute   = attribute.Key("lwm.verified")
+)
+
+// TracedWildcardTree is a WildcardTree that records a span for each look-up.
+// Other methods of the embedded tree are not traced.
+type TracedWildcardTree struct {
+	*lwm.WildcardTree
+	tracer trace.Tracer
+}
+
+// TraceWildcardTree outputs a wrapper for wt that starts spans with tracer
+func TraceWildcardTree(wt *lwm.WildcardTree,
+	tracer trace.Tracer) *TracedWildcardTree {
+	return &TracedWildcardTree{WildcardTree: wt, tracer: tracer}
+}
+
+// Get is like WildcardTree.Get, but the span has no parent. Use
+// GetWithContext to make it part of an existing trace.
+func (tt *TracedWildcardTree) Get(key string) (lwm.Answer, lwm.Proof) {
+	answer, proof, _ := tt.GetWithContext(context.Background(), key)
+	return answer, proof
+}
+
+// GetWithContext is like WildcardTree.GetWithContext, and records a span that
+// is a child of the span in ctx (if any)
+func (tt *TracedWildcardTree) GetWithContext(ctx context.Context,
+	key string) (lwm.Answer, lwm.Proof, error) {
+	ctx, span := tt.tracer.Start(ctx, GetSpan,
+		trace.WithAttributes(KeyAttribute.String(key)))
+	defer span.End()
+
+	answer, proof, err := tt.WildcardTree.GetWithContext(ctx, key)
+	span.SetAttributes(
+		MatchCountAttribute.Int(len(answer.Subjects())),
+		TreeSizeAttribute.Int(tt.Size()),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return answer, proof, err
+}
+
+// Verify is like Proof.Verify, and records a span that is a child of the span
+// in ctx (if any)
+func Verify(ctx context.Context, tracer trace.Tracer, p lwm.Proof, key string,
+	a lwm.Answer, size int, snapshot []byte) error {
+	_, span := tracer.Start(ctx, VerifySpan,
+		trace.WithAttributes(KeyAttribute.String(key)))
+	defer span.End()
+
+	err := p.Verify(key, a, size, snapshot)
+	span.SetAttributes(VerifiedAttribute.Bool(err == nil))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/example/stringutil"
+	"github.com/rgdd/lwm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+func TestTraceWildcardTree(t *testing.T) {
+	m := map[string]interface{}{
+		stringutil.Reverse("foo.com"):     [][]byte{[]byte("foo.com cert")},
+		stringutil.Reverse("sub.foo.com"): [][]byte{[]byte("sub.foo.com cert")},
+		stringutil.Reverse("bar.example"): [][]byte{[]byte("bar.example cert")},
+	}
+	rec := &recorder{}
+	tt := TraceWildcardTree(lwm.MustNewWildcardTree([]byte{0xff},
+		lwm.HashFunctions["sha256"], m), rec)
+	snapshot := tt.Snapshot()
+
+	for _, table := range []struct {
+		desc    string
+		key     string
+		matches int
+	}{
+		{"match", "moc.oof", 2},
+		{"no match", "moc.rab", 0},
+	} {
+		rec.spans = nil
+		answer, proof := tt.Get(table.key)
+		if err := Verify(context.Background(), rec, proof, table.key, answer,
+			tt.Size(), snapshot); err != nil {
+			t.Errorf("%s: valid proof rejected: %v", table.desc, err)
+		}
+		if err := Verify(context.Background(), rec, proof, table.key, answer,
+			tt.Size()+1, snapshot); err == nil {
+			t.Errorf("%s: proof accepted for bad size", table.desc)
+		}
+
+		if len(rec.spans) != 3 {
+			t.Errorf("%s: got %d spans, want 3", table.desc, len(rec.spans))
+			continue
+		}
+		for i, want := range []struct {
+			name  string
+			attrs map[attribute.Key]attribute.Value
+			code  codes.Code
+		}{
+			{GetSpan, map[attribute.Key]attribute.Value{
+				KeyAttribute:        attribute.StringValue(table.key),
+				MatchCountAttribute: attribute.IntValue(table.matches),
+				TreeSizeAttribute:   attribute.IntValue(len(m)),
+			}, codes.Unset},
+			{VerifySpan, map[attribute.Key]attribute.Value{
+				KeyAttribute:      attribute.StringValue(table.key),
+				VerifiedAttribute: attribute.BoolValue(true),
+			}, codes.Unset},
+			{VerifySpan, map[attribute.Key]attribute.Value{
+				KeyAttribute:      attribute.StringValue(table.key),
+				VerifiedAttribute: attribute.BoolValue(false),
+			}, codes.Error},
+		} {
+			span := rec.spans[i]
+			if span.name != want.name {
+				t.Errorf("%s: span %d: got name %q, want %q", table.desc, i,
+					span.name, want.name)
+			}
+			if !span.ended {
+				t.Errorf("%s: span %d: not ended", table.desc, i)
+			}
+			if span.code != want.code {
+				t.Errorf("%s: span %d: got status %v, want %v", table.desc, i,
+					span.code, want.code)
+			}
+			if len(span.attrs) != len(want.attrs) {
+				t.Errorf("%s: span %d: got %d attributes, want %d", table.desc, i,
+					len(span.attrs), len(want.attrs))
+			}
+			for k, v := range want.attrs {
+				if got, ok := span.attrs[k]; !ok || got != v {
+					t.Errorf("%s: span %d: got %s=%v, want %v", table.desc, i, k,
+						got.Emit(), v.Emit())
+				}
+			}
+		}
+	}
+
+	// cancelled look-ups are recorded as errors
+	rec.spans = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := tt.GetWithContext(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled look-up: got error %v", err)
+	}
+	if len(rec.spans) != 1 || rec.spans[0].code != codes.Error {
+		t.Errorf("cancelled look-up: span not marked as failed")
+	}
+}
+
+// recorder is an in-memory tracer. The tracetest package is not used because
+// the OpenTelemetry SDK is a much larger dependency than the API.
+type recorder struct {
+	spans []*span
+}
+
+type span struct {
+	trace.Span // non-recording span for the methods that are not overridden
+
+	name  string
+	attrs map[attribute.Key]attribute.Value
+	code  codes.Code
+	ended bool
+}
+
+func (r *recorder) Start(ctx context.Context, name string,
+	opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := &span{
+		Span:  trace.SpanFromContext(context.Background()),
+		name:  name,
+		attrs: make(map[attribute.Key]attribute.Value),
+	}
+	cfg := trace.NewSpanStartConfig(opts...)
+	s.SetAttributes(cfg.Attributes()...)
+	r.spans = append(r.spans, s)
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+func (s *span) SetAttributes(kv ...attribute.KeyValue) {
+	for _, attr := range kv {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *span) SetStatus(code codes.Code, _ string) {
+	s.code = code
+}
+
+func (s *span) End(...trace.SpanEndOption) {
+	s.ended = true
+}
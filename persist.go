@@ -0,0 +1,171 @@
+package lwm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Binary tree format (all integers are little-endian):
+//
+//	version   1 byte
+//	hash name 1-byte length + bytes
+//	twc       2-byte length + bytes
+//	leaves    4-byte count, followed by each leaf in Merkle tree order:
+//	  data    2-byte length + bytes, i.e., key + h(payload)
+//	  payload 2-byte count, followed by each item as a 4-byte length + bytes
+//
+// Payloads are stored next to the leaf data, because Get must output them.
+// Interior hashes are not stored, since they are recomputed on construction.
+const treeBinaryVersion = 1
+
+// Marshal outputs a binary encoding of the tree, using name to identify the
+// tree's hash function on unmarshal. The tree must use the default prefixes,
+// and its payloads must not be blinded.
+func (wt *WildcardTree) Marshal(name string) ([]byte, error) {
+	if registered, ok := hashName(wt.mt.hash); ok && registered != name {
+		return nil, fmt.Errorf("hash function is registered as %q, not %q",
+			registered, name)
+	}
+	if !bytes.Equal(wt.mt.leafPrefix, leafPrefix) ||
+		!bytes.Equal(wt.mt.interiorPrefix, interiorPrefix) {
+		return nil, errors.New("custom prefixes cannot be encoded")
+	}
+	if wt.blinded {
+		return nil, errors.New("blinded payloads cannot be encoded")
+	}
+	if wt.hashLength > 0 {
+		return nil, errors.New("truncated hashes cannot be encoded")
+	}
+	if wt.nonceLength > 0 {
+		return nil, errors.New("leaf nonces cannot be encoded")
+	}
+	if len(name) > math.MaxUint8 {
+		return nil, errors.New("hash name is too long")
+	}
+	if len(wt.mt.twc) > math.MaxUint16 {
+		return nil, errors.New("tree-wide constant is too long")
+	}
+	if uint64(len(wt.mt.data)) > math.MaxUint32 {
+		return nil, errors.New("too many leaves")
+	}
+
+	b := []byte{treeBinaryVersion, uint8(len(name))}
+	b = append(b, name...)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(wt.mt.twc)))
+	b = append(b, wt.mt.twc...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(wt.mt.data)))
+	for _, data := range wt.mt.data {
+		v, ok := wt.r.Get(wt.leafKey(data))
+		if !ok {
+			panic("This should never happen")
+		}
+		payload := v.(radixValue).payload
+		if len(data) > math.MaxUint16 {
+			return nil, errors.New("leaf data is too large")
+		}
+		if len(payload) > math.MaxUint16 {
+			return nil, errors.New("too many payload items")
+		}
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(data)))
+		b = append(b, data...)
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(payload)))
+		for _, item := range payload {
+			if uint64(len(item)) > math.MaxUint32 {
+				return nil, errors.New("payload item is too large")
+			}
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(item)))
+			b = append(b, item...)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalWildcardTree restores a tree from its binary encoding. The encoded
+// hash name is looked up in hashFuncs, e.g., HashFunctions.
+func UnmarshalWildcardTree(b []byte,
+	hashFuncs map[string]func(...[]byte) []byte) (*WildcardTree, error) {
+	r := treeReader{b: b}
+	if v := r.read(1); r.err == nil && v[0] != treeBinaryVersion {
+		return nil, fmt.Errorf("unsupported version %d", v[0])
+	}
+	var name string
+	if n := r.read(1); r.err == nil {
+		name = string(r.read(int(n[0])))
+	}
+	twc := r.read(int(r.uint(2)))
+	n := int(r.uint(4))
+	if r.err != nil {
+		return nil, r.err
+	}
+	h, ok := hashFuncs[name]
+	if !ok || h == nil {
+		return nil, fmt.Errorf("unknown hash function %q", name)
+	}
+
+	m := make(map[string]interface{})
+	prev := ""
+	for i := 0; i < n && r.err == nil; i++ {
+		data := r.read(int(r.uint(2)))
+		payload := make([][]byte, r.uint(2))
+		for j := range payload {
+			payload[j] = r.read(int(r.uint(4)))
+		}
+		if r.err != nil {
+			break
+		}
+		if len(data) < hashLen {
+			return nil, errors.New("malformed encoding: short leaf data")
+		}
+		key := mkKey(data)
+		if i > 0 && key <= prev {
+			return nil, errors.New("malformed encoding: bad leaf order")
+		}
+		if !bytes.Equal(data[len(key):], h(payload...)) {
+			return nil, fmt.Errorf("payload hash mismatch for key %q", key)
+		}
+		m[key], prev = payload, key
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.b) != 0 {
+		return nil, errors.New("malformed encoding: trailing data")
+	}
+	return NewWildcardTree(twc, h, m)
+}
+
+// treeReader reads fields from a binary tree encoding, recording the first
+// error that occurs
+type treeReader struct {
+	b   []byte
+	err error
+}
+
+// read outputs a copy of the next n bytes
+func (r *treeReader) read(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = errors.New("malformed encoding: truncated")
+		return nil
+	}
+	v := append([]byte{}, r.b[:n]...)
+	r.b = r.b[n:]
+	return v
+}
+
+// uint outputs the next n-byte little-endian integer, where n is 2 or 4
+func (r *treeReader) uint(n int) uint32 {
+	v := r.read(n)
+	if r.err != nil {
+		return 0
+	}
+	if n == 2 {
+		return uint32(binary.LittleEndian.Uint16(v))
+	}
+	return binary.LittleEndian.Uint32(v)
+}
@@ -0,0 +1,73 @@
+package lwm
+
+import (
+	"bytes"
+	"github.com/golang/example/stringutil"
+	"testing"
+)
+
+func TestWildcardTreeMarshal(t *testing.T) {
+	wt := MustNewWildcardTree(twc, HashFunctions["sha256"], testData())
+	b, err := wt.Marshal("sha256")
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	wtp, err := UnmarshalWildcardTree(b, HashFunctions)
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if got, want := wtp.Snapshot(), wt.Snapshot(); !bytes.Equal(got, want) {
+		t.Errorf("snapshot => got %x, want %x", got, want)
+	}
+	for _, key := range []string{"foo.com", "bar.edu", "qux.se", "nope.org"} {
+		key = stringutil.Reverse(key)
+		answer, proof := wtp.Get(key)
+		if err := proof.Verify(key, answer, wt.Size(), wt.Snapshot()); err != nil {
+			t.Errorf("key %q: verify failed after round-trip: %v", key, err)
+		}
+		if want, _ := wt.Get(key); len(answer.Subjects()) !=
+			len(want.Subjects()) {
+			t.Errorf("key %q: got %d matches, want %d", key,
+				len(answer.Subjects()), len(want.Subjects()))
+		}
+	}
+
+	// empty tree
+	b, err = MustNewWildcardTree(twc, HashFunctions["sha256"], nil).Marshal("sha256")
+	if err != nil {
+		t.Fatalf("marshal failed for empty tree: %v", err)
+	}
+	if wtp, err = UnmarshalWildcardTree(b, HashFunctions); err != nil {
+		t.Fatalf("unmarshal failed for empty tree: %v", err)
+	}
+	if !wtp.IsEmpty() {
+		t.Errorf("empty tree is not empty after round-trip")
+	}
+}
+
+func TestWildcardTreeUnmarshalErrors(t *testing.T) {
+	wt := MustNewWildcardTree(twc, HashFunctions["sha256"], testData())
+	if _, err := wt.Marshal("blake3"); err == nil {
+		t.Errorf("marshal accepted the wrong hash name")
+	}
+	b, err := wt.Marshal("sha256")
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	for i := 0; i < len(b); i++ {
+		if _, err := UnmarshalWildcardTree(b[:i], HashFunctions); err == nil {
+			t.Errorf("accepted truncated encoding of length %d", i)
+		}
+	}
+	if _, err := UnmarshalWildcardTree(append(b, 0), HashFunctions); err == nil {
+		t.Errorf("accepted trailing data")
+	}
+	if _, err := UnmarshalWildcardTree(b, nil); err == nil {
+		t.Errorf("accepted unknown hash function")
+	}
+	bad := append([]byte{}, b...)
+	bad[len(bad)-1] ^= 1 // last payload byte
+	if _, err := UnmarshalWildcardTree(bad, HashFunctions); err == nil {
+		t.Errorf("accepted payload that does not match leaf data")
+	}
+}
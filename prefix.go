@@ -0,0 +1,152 @@
+package lwm
+
+import (
+	"sort"
+	"strings"
+)
+
+// Prefix outputs an answer that contains every key k in the tree such that k
+// is a prefix of key, e.g., all ancestors of a reversed domain name and the
+// name itself. Ancestors need not be adjacent in the tree, so the answer also
+// contains any keys between the first and the last match. Such keys must be
+// filtered by the caller after verification. The proof can be verified with
+// VerifyRange, using the first and the last subject as bounds, which shows that
+// the answer is authentic. It does not show that the answer is complete, i.e.,
+// that no shorter prefix of key is in the tree, see ProvePrefix. If nothing
+// matches, the proof shows that key is absent, see Verify.
+func (wt *WildcardTree) Prefix(key string) (answer Answer, proof Proof) {
+	key = wt.normalizeKey(key)
+	wt.initProof(&proof)
+	proof.index = -1
+
+	// special case: empty tree
+	if len(wt.mt.data) == 0 {
+		return
+	}
+
+	var first, last string
+	found := false
+	wt.r.WalkPath(key, func(k string, _ interface{}) bool {
+		if !found {
+			first, found = k, true
+		}
+		last = k
+		return false
+	})
+	if !found {
+		wt.absenceProof(&proof, key)
+		return
+	}
+	lo := sort.Search(len(wt.mt.data), func(i int) bool {
+		return wt.leafKey(wt.mt.data[i]) >= first
+	})
+	hi := sort.Search(len(wt.mt.data), func(i int) bool {
+		return wt.leafKey(wt.mt.data[i]) > last
+	})
+	for i := lo; i < hi; i++ {
+		k := wt.leafKey(wt.mt.data[i])
+		v, _ := wt.r.Get(k)
+		answer.add(k, v.(radixValue))
+	}
+	wt.rangeProof(&proof, lo, hi-lo)
+	return
+}
+
+// PrefixProof proves that an answer contains exactly those keys in a tree that
+// are non-empty prefixes of a key, see ProvePrefix
+type PrefixProof struct {
+	segments []prefixSegment
+}
+
+// prefixSegment is a range proof for the prefixes of a key with lengths in
+// [first,last]. The range contains no keys that are not prefixes of the key.
+type prefixSegment struct {
+	first, last int   // prefix lengths
+	count       int   // number of subjects in the range
+	proof       Proof // see VerifyRange
+}
+
+// ProvePrefix is like Prefix, but the answer only contains keys that are
+// prefixes of key and the proof also shows that no prefix is missing. The
+// prefixes of key are split into segments that contain no other keys in the
+// tree, and each segment is proven with a range proof. The number of range
+// proofs is at most the length of key.
+func (wt *WildcardTree) ProvePrefix(key string) (answer Answer,
+	proof PrefixProof) {
+	key = wt.normalizeKey(key)
+	upper := func(s string) int { // index of the first leaf that is > s
+		return sort.Search(len(wt.mt.data), func(i int) bool {
+			return wt.leafKey(wt.mt.data[i]) > s
+		})
+	}
+	for first := 1; first <= len(key); {
+		last, hi := first, upper(key[:first])
+		for last < len(key) {
+			next := upper(key[:last+1])
+			if !wt.onlyPrefixes(key, hi, next) {
+				break
+			}
+			last, hi = last+1, next
+		}
+		a, p := wt.rangeOf(key[:first], key[:last])
+		answer, _ = answer.Merge(a) // segments are ordered
+		proof.segments = append(proof.segments, prefixSegment{first, last,
+			len(a.subject), p})
+		first = last + 1
+	}
+	return
+}
+
+// onlyPrefixes outputs true if the keys of leaves [lo,hi) are prefixes of key
+func (wt *WildcardTree) onlyPrefixes(key string, lo, hi int) bool {
+	for i := lo; i < hi; i++ {
+		if !strings.HasPrefix(key, wt.leafKey(wt.mt.data[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify outputs nil if answer contains exactly those keys in the tree that
+// are non-empty prefixes of key, which must be normalized. Otherwise a
+// *VerificationError is returned.
+func (pp PrefixProof) Verify(key string, a Answer, size int,
+	snapshot []byte) error {
+	if len(a.subject) != len(a.payload) {
+		return verificationError(KindMalformedData,
+			"got %d subjects but %d payloads", len(a.subject), len(a.payload))
+	}
+	next, offset := 1, 0
+	for _, s := range pp.segments {
+		if s.first != next || s.last < s.first || s.last > len(key) {
+			return verificationError(KindMalformedData,
+				"segment [%d,%d] does not follow prefix length %d", s.first,
+				s.last, next-1)
+		}
+		if s.count < 0 || s.count > len(a.subject)-offset {
+			return verificationError(KindMalformedData,
+				"segment [%d,%d] has a bad subject count", s.first, s.last)
+		}
+		sub := a.Slice(offset, offset+s.count)
+		for _, subject := range sub.subject {
+			if !strings.HasPrefix(key, subject) {
+				return verificationError(KindLeafOrder,
+					"subject %q is not a prefix of %q", subject, key)
+			}
+		}
+		if err := s.proof.VerifyRange(key[:s.first], key[:s.last], sub, size,
+			snapshot); err != nil {
+			return err
+		}
+		next, offset = s.last+1, offset+s.count
+	}
+	if next != len(key)+1 {
+		return verificationError(KindMalformedData,
+			"prefixes of length %d and longer are not covered", next)
+	}
+	if offset != len(a.subject) {
+		return verificationError(KindMalformedData,
+			"answer has %d subjects that are not covered", len(a.subject)-offset)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package lwm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPrefix(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	snapshot := wt.Snapshot()
+	for _, table := range []struct {
+		key      string
+		subjects []string // answer, including non-prefixes between matches
+		match    []string // subjects that are prefixes of key
+	}{
+		{"moc.oof.1bus", []string{"moc.oof", "moc.oof.1bus"},
+			[]string{"moc.oof", "moc.oof.1bus"}},
+		{"moc.oof.1bus.www", []string{"moc.oof", "moc.oof.1bus"},
+			[]string{"moc.oof", "moc.oof.1bus"}},
+		{"moc.oof.2bus", []string{"moc.oof", "moc.oof.1bus", "moc.oof.2bus"},
+			[]string{"moc.oof", "moc.oof.2bus"}},
+		{"es.xuq.bus", []string{"es.xuq", "es.xuq.bus"},
+			[]string{"es.xuq", "es.xuq.bus"}},
+		{"moc.oof", []string{"moc.oof"}, []string{"moc.oof"}},
+		{"moc", nil, nil},
+		{"zzz", nil, nil},
+	} {
+		answer, proof := wt.Prefix(table.key)
+		subjects := answer.Subjects()
+		if got, want := fmt.Sprint(subjects), fmt.Sprint(table.subjects); got !=
+			want {
+			t.Errorf("%s => got %s, want %s", table.key, got, want)
+			continue
+		}
+		var err error
+		if len(subjects) == 0 {
+			err = proof.Verify(table.key, answer, wt.Size(), snapshot)
+		} else {
+			err = proof.VerifyRange(subjects[0], subjects[len(subjects)-1], answer,
+				wt.Size(), snapshot)
+		}
+		if err != nil {
+			t.Errorf("%s => %v", table.key, err)
+		}
+		var match []string
+		for _, subject := range subjects {
+			if strings.HasPrefix(table.key, subject) {
+				match = append(match, subject)
+			}
+		}
+		if got, want := fmt.Sprint(match), fmt.Sprint(table.match); got != want {
+			t.Errorf("%s => got matches %s, want %s", table.key, got, want)
+		}
+	}
+
+	empty := MustNewWildcardTree(twc, hash, nil)
+	answer, proof := empty.Prefix("moc.oof")
+	if err := proof.Verify("moc.oof", answer, 0, empty.Snapshot()); err != nil {
+		t.Errorf("empty tree => %v", err)
+	}
+}
+
+func TestProvePrefix(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	snapshot := wt.Snapshot()
+	for _, table := range []struct {
+		key  string
+		want []string
+	}{
+		{"moc.oof.1bus", []string{"moc.oof", "moc.oof.1bus"}},
+		{"moc.oof.1bus.www", []string{"moc.oof", "moc.oof.1bus"}},
+		{"moc.oof.2bus", []string{"moc.oof", "moc.oof.2bus"}},
+		{"es.xuq.bus", []string{"es.xuq", "es.xuq.bus"}},
+		{"moc.oof", []string{"moc.oof"}},
+		{"moc", nil},
+		{"zzz", nil},
+		{"", nil},
+	} {
+		answer, proof := wt.ProvePrefix(table.key)
+		if got, want := fmt.Sprint(answer.Subjects()),
+			fmt.Sprint(table.want); got != want {
+			t.Errorf("%q => got %s, want %s", table.key, got, want)
+		}
+		if err := proof.Verify(table.key, answer, wt.Size(),
+			snapshot); err != nil {
+			t.Errorf("%q => %v", table.key, err)
+		}
+		if len(proof.segments) > len(table.key) {
+			t.Errorf("%q => got %d segments", table.key, len(proof.segments))
+		}
+	}
+
+	// omitting an ancestor or a segment is detected
+	answer, proof := wt.ProvePrefix("moc.oof.2bus")
+	if err := proof.Verify("moc.oof.2bus", answer.Slice(1, 2), wt.Size(),
+		snapshot); err == nil {
+		t.Errorf("accepted an answer without an ancestor")
+	}
+	for i := range proof.segments {
+		dropped := PrefixProof{append(append([]prefixSegment{},
+			proof.segments[:i]...), proof.segments[i+1:]...)}
+		if err := dropped.Verify("moc.oof.2bus", answer, wt.Size(),
+			snapshot); err == nil {
+			t.Errorf("accepted a proof without segment %d", i)
+		}
+	}
+	if err := proof.Verify("moc.oof.2bus.www", answer, wt.Size(),
+		snapshot); err == nil {
+		t.Errorf("accepted a proof for another key")
+	}
+	empty := MustNewWildcardTree(twc, hash, nil)
+	answer, proof = empty.ProvePrefix("moc.oof")
+	if err := proof.Verify("moc.oof", answer, 0, empty.Snapshot()); err != nil {
+		t.Errorf("empty tree => %v", err)
+	}
+}
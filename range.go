@@ -0,0 +1,72 @@
+package lwm
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Range outputs a verifiable answer that contains every key k in the tree such
+// that start <= k <= end. Unlike Get, this is not restricted to key prefixes.
+func (wt *WildcardTree) Range(start, end string) (answer Answer, proof Proof,
+	err error) {
+	start, end = wt.normalizeKey(start), wt.normalizeKey(end)
+	if start > end {
+		return answer, proof, fmt.Errorf("start %q is after end %q", start, end)
+	}
+	answer, proof = wt.rangeOf(start, end)
+	return
+}
+
+// rangeOf is like Range for normalized keys start <= end
+func (wt *WildcardTree) rangeOf(start, end string) (answer Answer,
+	proof Proof) {
+	wt.initProof(&proof)
+	proof.index = -1
+
+	// special case: empty tree
+	if len(wt.mt.data) == 0 {
+		return
+	}
+
+	lo := sort.Search(len(wt.mt.data), func(i int) bool {
+		return wt.leafKey(wt.mt.data[i]) >= start
+	})
+	hi := sort.Search(len(wt.mt.data), func(i int) bool {
+		return wt.leafKey(wt.mt.data[i]) > end
+	})
+	if lo == hi {
+		wt.absenceProof(&proof, start)
+		return
+	}
+	for i := lo; i < hi; i++ {
+		key := wt.leafKey(wt.mt.data[i])
+		v, _ := wt.r.Get(key)
+		answer.add(key, v.(radixValue))
+	}
+	wt.rangeProof(&proof, lo, hi-lo)
+	return
+}
+
+// VerifyRange outputs nil if answer contains exactly those keys k in the tree
+// such that start <= k <= end. Otherwise a *VerificationError is returned.
+func (p Proof) VerifyRange(start, end string, a Answer, size int,
+	snapshot []byte) error {
+	if start > end {
+		return verificationError(KindMalformedData, "start is after end")
+	}
+	if p.ll != nil && p.leafKey(p.ll) >= start {
+		return verificationError(KindLeafOrder, "left leaf %q is in range",
+			p.leafKey(p.ll))
+	}
+	if p.rl != nil && p.leafKey(p.rl) <= end {
+		return verificationError(KindLeafOrder, "right leaf %q is in range",
+			p.leafKey(p.rl))
+	}
+	for _, subject := range a.subject {
+		if subject < start || subject > end {
+			return verificationError(KindLeafOrder, "subject %q is out of range",
+				subject)
+		}
+	}
+	return p.Verify(start, a, size, snapshot)
+}
@@ -0,0 +1,66 @@
+package lwm
+
+import (
+	"testing"
+)
+
+func TestRange(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, m)
+	snapshot := wt.Snapshot()
+	for _, table := range []struct {
+		start, end string
+		match      []string
+	}{
+		{"", "zzz", []string{"es.xuq", "es.xuq.bus", "moc.oof", "moc.oof.1bus",
+			"moc.oof.2bus", "ude.rab.bus", "vog.zab"}},
+		{"aaa", "b", nil},
+		{"es.xuq.bus", "moc.oof.1bus", []string{"es.xuq.bus", "moc.oof",
+			"moc.oof.1bus"}},
+		{"f", "moc.oof", []string{"moc.oof"}},
+		{"moc.oof.3", "ude", nil},
+		{"vog.zab", "vog.zab", []string{"vog.zab"}},
+		{"x", "zzz", nil},
+	} {
+		answer, proof, err := wt.Range(table.start, table.end)
+		if err != nil {
+			t.Errorf("[%v,%v] => %v", table.start, table.end, err)
+			continue
+		}
+		if len(answer.subject) != len(table.match) {
+			t.Errorf("[%v,%v] => got %v, want %v", table.start, table.end,
+				answer.subject, table.match)
+			continue
+		}
+		for i := range table.match {
+			if answer.subject[i] != table.match[i] {
+				t.Errorf("[%v,%v] => got %v, want %v", table.start, table.end,
+					answer.subject[i], table.match[i])
+			}
+		}
+		if err := proof.VerifyRange(table.start, table.end, answer, len(m),
+			snapshot); err != nil {
+			t.Errorf("[%v,%v] => valid proof rejected: %v", table.start, table.end,
+				err)
+		}
+		if len(answer.subject) > 0 {
+			if err := proof.VerifyRange(table.start, answer.subject[0], answer,
+				len(m), snapshot); err == nil && len(answer.subject) > 1 {
+				t.Errorf("[%v,%v] => accepted subjects outside of range",
+					table.start, table.end)
+			}
+			// a narrower answer must be rejected for the same range
+			a := answer
+			a.subject, a.payload = a.subject[1:], a.payload[1:]
+			if err := proof.VerifyRange(table.start, table.end, a, len(m),
+				snapshot); err == nil {
+				t.Errorf("[%v,%v] => accepted incomplete answer", table.start,
+					table.end)
+			}
+		}
+	}
+
+	if _, _, err := wt.Range("b", "a"); err == nil {
+		t.Errorf("accepted start after end")
+	}
+}
@@ -0,0 +1,62 @@
+package lwm
+
+import (
+	"context"
+)
+
+// ReadOnlyWildcardTree is a view of a WildcardTree without mutation methods,
+// e.g., for components that should only be able to make look-ups. The view
+// reflects later modifications of the underlying tree. Payloads are shared
+// with the tree and must not be modified. Look-ups populate the hash cache,
+// so a view is not safe for concurrent use unless the tree is, see
+// SafeWildcardTree.
+type ReadOnlyWildcardTree struct {
+	wt *WildcardTree
+}
+
+// ReadOnly outputs a read-only view of the tree
+func (wt *WildcardTree) ReadOnly() *ReadOnlyWildcardTree {
+	return &ReadOnlyWildcardTree{wt}
+}
+
+// Get is like WildcardTree.Get
+func (ro *ReadOnlyWildcardTree) Get(key string) (Answer, Proof) {
+	return ro.wt.Get(key)
+}
+
+// GetExact is like WildcardTree.GetExact
+func (ro *ReadOnlyWildcardTree) GetExact(key string) (Answer, Proof, bool) {
+	return ro.wt.GetExact(key)
+}
+
+// Contains is like WildcardTree.Contains
+func (ro *ReadOnlyWildcardTree) Contains(key string) bool {
+	return ro.wt.Contains(key)
+}
+
+// Size is like WildcardTree.Size
+func (ro *ReadOnlyWildcardTree) Size() int {
+	return ro.wt.Size()
+}
+
+// Snapshot is like WildcardTree.Snapshot
+func (ro *ReadOnlyWildcardTree) Snapshot() []byte {
+	return ro.wt.Snapshot()
+}
+
+// Keys is like WildcardTree.Keys
+func (ro *ReadOnlyWildcardTree) Keys() []string {
+	return ro.wt.Keys()
+}
+
+// ForEach is like WildcardTree.ForEach
+func (ro *ReadOnlyWildcardTree) ForEach(fn func(key string,
+	payload [][]byte) bool) {
+	ro.wt.ForEach(fn)
+}
+
+// Walk is like WildcardTree.Walk
+func (ro *ReadOnlyWildcardTree) Walk(ctx context.Context, prefix string,
+	fn func(key string, payload [][]byte) bool) error {
+	return ro.wt.Walk(ctx, prefix, fn)
+}
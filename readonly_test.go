@@ -0,0 +1,57 @@
+package lwm
+
+import (
+	"context"
+	"github.com/golang/example/stringutil"
+	"reflect"
+	"testing"
+)
+
+func TestReadOnlyWildcardTree(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	ro := wt.ReadOnly()
+	typ := reflect.TypeOf(ro)
+	for _, name := range []string{"Add", "Remove", "Update", "Compact",
+		"Rotate", "Reindex", "Begin", "OnChange"} {
+		if _, ok := typ.MethodByName(name); ok {
+			t.Errorf("read-only view has method %s", name)
+		}
+	}
+	for i := 0; i < typ.Elem().NumField(); i++ {
+		if typ.Elem().Field(i).IsExported() {
+			t.Errorf("read-only view exports field %s", typ.Elem().Field(i).Name)
+		}
+	}
+
+	key := stringutil.Reverse("foo.com")
+	answer, proof := ro.Get(key)
+	if err := proof.Verify(key, answer, ro.Size(), ro.Snapshot()); err != nil {
+		t.Errorf("valid proof rejected: %v", err)
+	}
+	if len(answer.Subjects()) != 3 {
+		t.Errorf("got %d matches, want 3", len(answer.Subjects()))
+	}
+	answer, proof, ok := ro.GetExact(key)
+	if !ok || proof.Verify(key, answer, ro.Size(), ro.Snapshot()) != nil {
+		t.Errorf("exact match => bad answer or proof")
+	}
+	if !ro.Contains(key) || ro.Contains("ten") {
+		t.Errorf("bad Contains")
+	}
+	n := 0
+	ro.ForEach(func(string, [][]byte) bool { n++; return true })
+	ro.Walk(context.Background(), "moc", func(string, [][]byte) bool {
+		n++
+		return true
+	})
+	if want := len(ro.Keys()) + 3; n != want {
+		t.Errorf("got %d entries, want %d", n, want)
+	}
+
+	if err := wt.Add("ten.oof", nil); err != nil {
+		t.Fatal(err)
+	}
+	if ro.Size() != wt.Size() || !ro.Contains("ten.oof") {
+		t.Errorf("read-only view does not reflect modifications")
+	}
+}
@@ -0,0 +1,92 @@
+package lwm
+
+import (
+	"sync"
+)
+
+// SafeWildcardTree is a WildcardTree that is safe for concurrent use. The
+// methods below acquire a read lock for look-ups and a write lock for
+// mutations. Other methods of the embedded tree are not synchronized, and must
+// be called with the embedded lock held.
+type SafeWildcardTree struct {
+	*WildcardTree
+	sync.RWMutex
+}
+
+// NewSafeWildcardTree outputs a concurrent-safe wrapper for wt, which must not
+// be used directly afterwards
+func NewSafeWildcardTree(wt *WildcardTree) *SafeWildcardTree {
+	wt.Snapshot() // look-ups only read the hash cache once it is populated
+	return &SafeWildcardTree{WildcardTree: wt}
+}
+
+// Get is like WildcardTree.Get
+func (st *SafeWildcardTree) Get(key string) (Answer, Proof) {
+	st.RLock()
+	defer st.RUnlock()
+	return st.WildcardTree.Get(key)
+}
+
+// GetExact is like WildcardTree.GetExact
+func (st *SafeWildcardTree) GetExact(key string) (Answer, Proof, bool) {
+	st.RLock()
+	defer st.RUnlock()
+	return st.WildcardTree.GetExact(key)
+}
+
+// Size is like WildcardTree.Size
+func (st *SafeWildcardTree) Size() int {
+	st.RLock()
+	defer st.RUnlock()
+	return st.WildcardTree.Size()
+}
+
+// Snapshot is like WildcardTree.Snapshot
+func (st *SafeWildcardTree) Snapshot() []byte {
+	st.RLock()
+	defer st.RUnlock()
+	return st.WildcardTree.Snapshot()
+}
+
+// Contains is like WildcardTree.Contains
+func (st *SafeWildcardTree) Contains(key string) bool {
+	st.RLock()
+	defer st.RUnlock()
+	return st.WildcardTree.Contains(key)
+}
+
+// ForEach is like WildcardTree.ForEach. The read lock is held while fn is
+// called, which means that fn must not mutate the tree.
+func (st *SafeWildcardTree) ForEach(fn func(key string, payload [][]byte) bool) {
+	st.RLock()
+	defer st.RUnlock()
+	st.WildcardTree.ForEach(fn)
+}
+
+// Add is like WildcardTree.Add
+func (st *SafeWildcardTree) Add(key string, payload [][]byte) error {
+	st.Lock()
+	defer st.Unlock()
+	return st.mutate(st.WildcardTree.Add(key, payload))
+}
+
+// Remove is like WildcardTree.Remove
+func (st *SafeWildcardTree) Remove(key string) error {
+	st.Lock()
+	defer st.Unlock()
+	return st.mutate(st.WildcardTree.Remove(key))
+}
+
+// Update is like WildcardTree.Update
+func (st *SafeWildcardTree) Update(key string, payload [][]byte) error {
+	st.Lock()
+	defer st.Unlock()
+	return st.mutate(st.WildcardTree.Update(key, payload))
+}
+
+// mutate populates the hash cache after a mutation, such that subsequent
+// look-ups with a read lock do not write to it. The write lock must be held.
+func (st *SafeWildcardTree) mutate(err error) error {
+	st.WildcardTree.Snapshot()
+	return err
+}
@@ -0,0 +1,58 @@
+package lwm
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSafeWildcardTree(t *testing.T) {
+	// run with -race to detect data races between readers and writers
+	st := NewSafeWildcardTree(MustNewWildcardTree(twc, hash, testData()))
+	var wg sync.WaitGroup
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				st.RLock() // snapshot and size must match the proof
+				answer, proof := st.WildcardTree.Get("moc.oof")
+				size, snapshot := st.WildcardTree.Size(), st.WildcardTree.Snapshot()
+				st.RUnlock()
+				if err := proof.Verify("moc.oof", answer, size, snapshot); err != nil {
+					t.Errorf("reader %d: valid proof rejected: %v", g, err)
+				}
+				st.Get("es")
+				st.GetExact("moc.oof")
+				st.Contains("vog")
+				st.ForEach(func(string, [][]byte) bool { return true })
+				st.Size()
+				st.Snapshot()
+			}
+		}(g)
+	}
+	for g := 0; g < 2; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("moc.oof.%d.%02d", g, i)
+				if err := st.Add(key, [][]byte{[]byte("a")}); err != nil {
+					t.Errorf("writer %d: add failed: %v", g, err)
+				}
+				if err := st.Update(key, [][]byte{[]byte("b")}); err != nil {
+					t.Errorf("writer %d: update failed: %v", g, err)
+				}
+				if i%2 == 0 {
+					if err := st.Remove(key); err != nil {
+						t.Errorf("writer %d: remove failed: %v", g, err)
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if got, want := st.Size(), len(testData())+50; got != want {
+		t.Errorf("size => got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Intersection outputs a new tree with the keys that are in both a and b,
+// using the payloads of a. The new tree has the same tree-wide constant, hash
+// function, and options as a. Keys of b are normalized and validated as by a.
+// An error is returned if a and b use different tree-wide constants, hash
+// functions, prefixes, hash lengths, leaf nonces, or payload blinding.
+func Intersection(a, b *WildcardTree) (*WildcardTree, error) {
+	return setOp(a, b, func(inA, inB bool) bool { return inA && inB })
+}
+
+// Union outputs a new tree with the keys that are in a or b, using the
+// payloads of a for keys that are in both, see Intersection
+func Union(a, b *WildcardTree) (*WildcardTree, error) {
+	return setOp(a, b, func(inA, inB bool) bool { return inA || inB })
+}
+
+// Difference outputs a new tree with the keys that are in a but not in b, see
+// Intersection
+func Difference(a, b *WildcardTree) (*WildcardTree, error) {
+	return setOp(a, b, func(inA, inB bool) bool { return inA && !inB })
+}
+
+// setOp outputs a new tree with the keys for which keep outputs true, where
+// inA and inB indicate if a key is in a and b
+func setOp(a, b *WildcardTree, keep func(inA, inB bool) bool) (*WildcardTree,
+	error) {
+	if err := compatible(a, b); err != nil {
+		return nil, err
+	}
+	am := a.toMap()
+	bm := make(map[string]interface{})
+	for key, v := range b.toMap() {
+		key = a.normalizeKey(key)
+		if err := a.validateKey(key); err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+		if _, ok := bm[key]; ok {
+			return nil, fmt.Errorf("keys of b collide as %q", key)
+		}
+		bm[key] = v
+	}
+
+	m := make(map[string]interface{})
+	for key, v := range am {
+		if _, inB := bm[key]; keep(true, inB) {
+			m[key] = v
+		}
+	}
+	for key, v := range bm {
+		if _, inA := am[key]; !inA && keep(false, true) {
+			m[key] = v
+		}
+	}
+	return a.rebuild(a.mt.twc, m)
+}
+
+// compatible outputs an error unless a and b hash the same entries to the same
+// leaves and interior nodes. Functions are compared by their output on a fixed
+// probe, since functions are not comparable.
+func compatible(a, b *WildcardTree) error {
+	probe := []byte("probe")
+	switch {
+	case !bytes.Equal(a.mt.twc, b.mt.twc):
+		return errors.New("trees use different tree-wide constants")
+	case !bytes.Equal(a.mt.hash(probe), b.mt.hash(probe)):
+		return errors.New("trees use different hash functions")
+	case a.hashLength != b.hashLength:
+		return errors.New("trees use different hash lengths")
+	case !bytes.Equal(a.mt.leafPrefix, b.mt.leafPrefix) ||
+		!bytes.Equal(a.mt.interiorPrefix, b.mt.interiorPrefix):
+		return errors.New("trees use different prefixes")
+	case (a.nonce == nil) != (b.nonce == nil) || (a.nonce != nil &&
+		!bytes.Equal(a.nonce(string(probe)), b.nonce(string(probe)))):
+		return errors.New("trees use different leaf nonces")
+	case a.blinded != b.blinded:
+		return errors.New("trees differ in payload blinding")
+	}
+	return nil
+}
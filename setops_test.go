@@ -0,0 +1,128 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"github.com/golang/example/stringutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetOperations(t *testing.T) {
+	am := testData()
+	bm := map[string]interface{}{
+		stringutil.Reverse("foo.com"):    [][]byte{[]byte("other foo.com cert")},
+		stringutil.Reverse("baz.gov"):    [][]byte{[]byte("baz.gov cert")},
+		stringutil.Reverse("new.org"):    [][]byte{[]byte("new.org cert")},
+		stringutil.Reverse("sub.new.se"): [][]byte{[]byte("sub.new.se cert")},
+	}
+	a := MustNewWildcardTree(twc, hash, am)
+	b := MustNewWildcardTree(twc, hash, bm)
+
+	intersection := map[string]interface{}{
+		stringutil.Reverse("foo.com"): am[stringutil.Reverse("foo.com")],
+		stringutil.Reverse("baz.gov"): am[stringutil.Reverse("baz.gov")],
+	}
+	union := testData()
+	union[stringutil.Reverse("new.org")] = bm[stringutil.Reverse("new.org")]
+	union[stringutil.Reverse("sub.new.se")] = bm[stringutil.Reverse("sub.new.se")]
+	difference := testData()
+	delete(difference, stringutil.Reverse("foo.com"))
+	delete(difference, stringutil.Reverse("baz.gov"))
+
+	for _, table := range []struct {
+		desc string
+		op   func(a, b *WildcardTree) (*WildcardTree, error)
+		want map[string]interface{}
+	}{
+		{"intersection", Intersection, intersection},
+		{"union", Union, union},
+		{"difference", Difference, difference},
+	} {
+		got, err := table.op(a, b)
+		if err != nil {
+			t.Errorf("%s => %v", table.desc, err)
+			continue
+		}
+		if m := got.toMap(); !reflect.DeepEqual(m, table.want) {
+			t.Errorf("%s => got %v, want %v", table.desc, m, table.want)
+		}
+		want := MustNewWildcardTree(twc, hash, table.want)
+		if !bytes.Equal(got.Snapshot(), want.Snapshot()) {
+			t.Errorf("%s => snapshot is not reproducible", table.desc)
+		}
+		again, _ := table.op(a, b)
+		if !bytes.Equal(got.Snapshot(), again.Snapshot()) {
+			t.Errorf("%s => snapshot changed between calls", table.desc)
+		}
+	}
+
+	nonce := func(key string) []byte { return hash([]byte(key)) }
+	for desc, other := range map[string]*WildcardTree{
+		"twc":  MustNewWildcardTree([]byte("other twc"), hash, bm),
+		"hash": MustNewWildcardTree(twc, HashFunctions["blake2b-256"], bm),
+		"leaf prefix": MustNewWildcardTree(twc, hash, bm,
+			WithLeafPrefix([]byte{0x02})),
+		"interior prefix": MustNewWildcardTree(twc, hash, bm,
+			WithInteriorPrefix([]byte{0x03})),
+		"hash length": MustNewWildcardTree(twc, hash, bm, WithHashLength(16)),
+		"nonce":       MustNewWildcardTree(twc, hash, bm, WithLeafNonce(nonce)),
+		"blinded":     MustNewWildcardTree(twc, hash, bm, WithBlindedPayloads()),
+	} {
+		for _, op := range []func(a, b *WildcardTree) (*WildcardTree, error){
+			Intersection, Union, Difference,
+		} {
+			if _, err := op(a, other); err == nil {
+				t.Errorf("%s => accepted incompatible trees", desc)
+			}
+			if _, err := op(other, a); err == nil {
+				t.Errorf("%s => accepted incompatible trees", desc)
+			}
+		}
+	}
+}
+
+func TestSetOperationsNormalize(t *testing.T) {
+	lower := MustNewWildcardTree(twc, hash, testData(),
+		WithKeyNormalizer(strings.ToLower))
+	upper := MustNewWildcardTree(twc, hash, map[string]interface{}{
+		"MOC.OOF": [][]byte{[]byte("other foo.com cert")},
+		"MOC.WEN": [][]byte{[]byte("new.com cert")},
+	})
+	union, err := Union(lower, upper)
+	if err != nil {
+		t.Fatalf("union => %v", err)
+	}
+	want := testData()
+	want["moc.wen"] = [][]byte{[]byte("new.com cert")}
+	if m := union.toMap(); !reflect.DeepEqual(m, want) {
+		t.Errorf("union => got %v, want %v", m, want)
+	}
+	intersection, err := Intersection(lower, upper)
+	if err != nil {
+		t.Fatalf("intersection => %v", err)
+	}
+	if got := intersection.Keys(); !reflect.DeepEqual(got,
+		[]string{"moc.oof"}) {
+		t.Errorf("intersection => got keys %v", got)
+	}
+
+	strict := MustNewWildcardTree(twc, hash, testData(),
+		WithKeyValidator(func(key string) error {
+			if strings.ToLower(key) != key {
+				return errors.New("key is not lowercase")
+			}
+			return nil
+		}))
+	if _, err := Union(strict, upper); err == nil {
+		t.Errorf("accepted keys that the validator rejects")
+	}
+	colliding := MustNewWildcardTree(twc, hash, map[string]interface{}{
+		"moc.wen": [][]byte{},
+		"MOC.WEN": [][]byte{},
+	})
+	if _, err := Union(lower, colliding); err == nil {
+		t.Errorf("accepted keys that collide after normalization")
+	}
+}
@@ -0,0 +1,73 @@
+package lwm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// SignedSnapshot is a snapshot and tree size that a tree operator signed, such
+// that clients can trust the snapshot that they pass to Proof.Verify
+type SignedSnapshot struct {
+	Snapshot  []byte
+	Size      int
+	Timestamp int64 // milliseconds since the Unix epoch
+	Sig       []byte
+}
+
+// Sign outputs a signed snapshot for the tree's current snapshot and size. The
+// signed message is the hash of the tree-wide constant, the snapshot, and the
+// size and timestamp as 8-byte big-endian integers. ECDSA and Ed25519 signers
+// are supported, and ECDSA signers sign the message's SHA-256 digest.
+func (wt *WildcardTree) Sign(signer crypto.Signer) (SignedSnapshot, error) {
+	ss := SignedSnapshot{
+		Snapshot:  cloneBytes(wt.Snapshot()),
+		Size:      wt.Size(),
+		Timestamp: time.Now().UnixMilli(),
+	}
+	msg := ss.message(wt.hash(), wt.mt.twc)
+	var err error
+	switch signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		ss.Sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case ed25519.PublicKey:
+		ss.Sig, err = signer.Sign(rand.Reader, msg, crypto.Hash(0))
+	default:
+		err = fmt.Errorf("unsupported public key type %T", signer.Public())
+	}
+	return ss, err
+}
+
+// VerifySignedSnapshot outputs true if the signed snapshot has a valid
+// signature from pub, which must be an ECDSA or Ed25519 public key. The hash
+// function and tree-wide constant must be the ones of the signed tree.
+func VerifySignedSnapshot(ss SignedSnapshot, pub crypto.PublicKey,
+	h func(...[]byte) []byte, twc []byte) bool {
+	if ss.Size < 0 {
+		return false
+	}
+	msg := ss.message(h, twc)
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		return ecdsa.VerifyASN1(pub, digest[:], ss.Sig)
+	case ed25519.PublicKey:
+		return len(pub) == ed25519.PublicKeySize &&
+			ed25519.Verify(pub, msg, ss.Sig)
+	default:
+		return false
+	}
+}
+
+// message outputs the hash that is signed
+func (ss SignedSnapshot) message(h func(...[]byte) []byte, twc []byte) []byte {
+	size := binary.BigEndian.AppendUint64(nil, uint64(ss.Size))
+	timestamp := binary.BigEndian.AppendUint64(nil, uint64(ss.Timestamp))
+	return h(twc, ss.Snapshot, size, timestamp)
+}
@@ -0,0 +1,73 @@
+package lwm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestSignedSnapshot(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt := MustNewWildcardTree(twc, hash, testData())
+	for _, signer := range []crypto.Signer{ecdsaKey, ed25519Key} {
+		ss, err := wt.Sign(signer)
+		if err != nil {
+			t.Errorf("%T => %v", signer, err)
+			continue
+		}
+		pub := signer.Public()
+		if !VerifySignedSnapshot(ss, pub, hash, twc) {
+			t.Errorf("%T => valid signature rejected", signer)
+		}
+
+		// the signed snapshot is what a verifier passes to Proof.Verify
+		answer, proof := wt.Get("moc.oof")
+		if err := proof.Verify("moc.oof", answer, ss.Size,
+			ss.Snapshot); err != nil {
+			t.Errorf("%T => %v", signer, err)
+		}
+
+		for _, table := range []struct {
+			desc   string
+			tamper func(ss *SignedSnapshot)
+		}{
+			{"snapshot", func(ss *SignedSnapshot) {
+				ss.Snapshot = append([]byte{}, ss.Snapshot...)
+				ss.Snapshot[0] ^= 1
+			}},
+			{"size", func(ss *SignedSnapshot) { ss.Size++ }},
+			{"timestamp", func(ss *SignedSnapshot) { ss.Timestamp++ }},
+		} {
+			tampered := ss
+			table.tamper(&tampered)
+			if VerifySignedSnapshot(tampered, pub, hash, twc) {
+				t.Errorf("%T => tampered %s accepted", signer, table.desc)
+			}
+		}
+		if VerifySignedSnapshot(ss, pub, hash, []byte("other twc")) {
+			t.Errorf("%T => other twc accepted", signer)
+		}
+	}
+	if VerifySignedSnapshot(SignedSnapshot{}, ecdsaKey.Public(), hash, twc) {
+		t.Errorf("empty signed snapshot accepted")
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Sign(rsaKey); err == nil {
+		t.Errorf("signed with unsupported key type")
+	}
+}
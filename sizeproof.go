@@ -0,0 +1,60 @@
+package lwm
+
+import (
+	"bytes"
+)
+
+// SizeProof proves the number of leaves in a tree with a given snapshot
+type SizeProof struct {
+	first, last     []byte   // first and last leaf data (nil->n/a)
+	firstAp, lastAp [][]byte // audit paths for the first and last leaves
+}
+
+// SizeProof outputs a proof of the tree's current size
+func (wt *WildcardTree) SizeProof() (proof SizeProof) {
+	if n := len(wt.mt.data); n > 0 {
+		proof.first, proof.firstAp = wt.mt.data[0], wt.mt.Ap(0)
+		proof.last, proof.lastAp = wt.mt.data[n-1], wt.mt.Ap(n-1)
+	}
+	return
+}
+
+// VerifySize outputs true if proof shows that snapshot is the root hash of a
+// tree with size leaves, given a tree-wide constant twc and a hash function h.
+// The first and last leaves must be at indices 0 and size-1 with audit paths of
+// the expected lengths. Note that a verifier cannot tell a leaf hash from an
+// interior hash in an audit path, which means that a tree may pass for a few
+// other sizes with the same audit path lengths (e.g., six and seven). Protocols
+// that rely on the exact size should authenticate it together with snapshot.
+func VerifySize(snapshot []byte, size int, proof SizeProof, twc []byte,
+	h func(data ...[]byte) []byte) bool {
+	if size < 0 {
+		return false
+	}
+	if size == 0 {
+		return proof.first == nil && proof.last == nil &&
+			bytes.Equal(snapshot, h(twc))
+	}
+	if proof.first == nil || proof.last == nil ||
+		len(proof.firstAp) != apLen(0, size) ||
+		len(proof.lastAp) != apLen(size-1, size) {
+		return false
+	}
+	mt := NewMerkleTree(twc, leafPrefix, interiorPrefix, h, nil)
+	return bytes.Equal(snapshot, mt.MthFromAp(proof.first, 0, size,
+		proof.firstAp)) && bytes.Equal(snapshot, mt.MthFromAp(proof.last,
+		size-1, size, proof.lastAp))
+}
+
+// apLen outputs the length of an audit path for the i:th leaf in a tree of
+// size n
+func apLen(i, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	k := lpow2s(n)
+	if i < k {
+		return 1 + apLen(i, k)
+	}
+	return 1 + apLen(i-k, n-k)
+}
@@ -0,0 +1,32 @@
+package lwm
+
+import (
+	"testing"
+)
+
+func TestSizeProof(t *testing.T) {
+	for n := 0; n <= 33; n++ {
+		wt := consistencyTree(n)
+		snapshot := wt.Snapshot()
+		proof := wt.SizeProof()
+		if !VerifySize(snapshot, n, proof, twc, hash) {
+			t.Errorf("size %d => valid proof rejected", n)
+		}
+		for size := 0; size <= 2*n+1; size++ {
+			if size == n || (size > 0 && n > 0 &&
+				apLen(0, size) == apLen(0, n) &&
+				apLen(size-1, size) == apLen(n-1, n)) {
+				continue // audit path lengths cannot tell these sizes apart
+			}
+			if VerifySize(snapshot, size, proof, twc, hash) {
+				t.Errorf("size %d => accepted bad size %d", n, size)
+			}
+		}
+		if VerifySize(hash([]byte("bad")), n, proof, twc, hash) {
+			t.Errorf("size %d => accepted bad snapshot", n)
+		}
+		if n > 0 && VerifySize(snapshot, n, proof, []byte("bad twc"), hash) {
+			t.Errorf("size %d => accepted bad twc", n)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package lwm
+
+import (
+	"fmt"
+)
+
+// NewWildcardTreeFromSorted is like NewWildcardTree, but takes entries that are
+// already in radix order, i.e., with strictly increasing keys. This avoids the
+// temporary radix tree that NewWildcardTree uses to order keys. Options are
+// applied as in NewWildcardTree, and an error is returned if the entries are
+// not strictly increasing after key normalization.
+func NewWildcardTreeFromSorted(twc []byte, h func(data ...[]byte) []byte,
+	entries []Entry, opts ...WildcardTreeOption) (*WildcardTree, error) {
+	wt, err := newWildcardTree(twc, h, opts)
+	if err != nil {
+		return nil, err
+	}
+	data := make([][]byte, 0, len(entries))
+	prev := ""
+	for i, e := range entries {
+		key := wt.normalizeKey(e.Key)
+		if err := wt.validateKey(key); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		if i > 0 && prev >= key {
+			return nil, fmt.Errorf("entry %d: key %q is not after %q", i, key, prev)
+		}
+		rv, leaf, err := wt.newEntry(key, e.Payload, i)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		wt.r.Insert(key, rv)
+		data = append(data, leaf)
+		prev = key
+	}
+	wt.mt = NewMerkleTree(twc, wt.mt.leafPrefix, wt.mt.interiorPrefix,
+		wt.mt.hash, data)
+	return wt, nil
+}
@@ -0,0 +1,140 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/golang/example/stringutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewWildcardTreeFromSorted(t *testing.T) {
+	m := testData()
+	want := MustNewWildcardTree(twc, hash, m)
+	var entries []Entry
+	want.ForEach(func(key string, payload [][]byte) bool {
+		entries = append(entries, Entry{key, payload})
+		return true
+	})
+	wt, err := NewWildcardTreeFromSorted(twc, hash, entries)
+	if err != nil {
+		t.Fatalf("construction failed: %v", err)
+	}
+	if !bytes.Equal(wt.Snapshot(), want.Snapshot()) {
+		t.Errorf("got snapshot %x, want %x", wt.Snapshot(), want.Snapshot())
+	}
+	if got := wt.toMap(); !reflect.DeepEqual(got, m) {
+		t.Errorf("got %v, want %v", got, m)
+	}
+	answer, proof := wt.Get("moc.oof")
+	if err := proof.Verify("moc.oof", answer, wt.Size(), wt.Snapshot()); err != nil {
+		t.Errorf("verify failed: %v", err)
+	}
+
+	if wt, err := NewWildcardTreeFromSorted(twc, hash, nil); err != nil ||
+		!bytes.Equal(wt.Snapshot(), hash(twc)) {
+		t.Errorf("empty tree => %v", err)
+	}
+	for _, bad := range [][]Entry{
+		{entries[1], entries[0]},
+		{entries[0], entries[0]},
+	} {
+		if _, err := NewWildcardTreeFromSorted(twc, hash, bad); err == nil {
+			t.Errorf("accepted unsorted entries")
+		}
+	}
+}
+
+func TestNewWildcardTreeFromSortedOptions(t *testing.T) {
+	m := testData()
+	var entries []Entry
+	MustNewWildcardTree(twc, hash, m).ForEach(func(key string,
+		payload [][]byte) bool {
+		entries = append(entries, Entry{strings.ToUpper(key), payload})
+		return true
+	})
+	nonce := func(key string) []byte { return hash([]byte("secret"), []byte(key)) }
+	lower := WithKeyNormalizer(strings.ToLower)
+	for _, table := range []struct {
+		desc string
+		opts []WildcardTreeOption
+	}{
+		{"normalizer", []WildcardTreeOption{lower}},
+		{"prefixes", []WildcardTreeOption{lower, WithLeafPrefix([]byte{0x10}),
+			WithInteriorPrefix([]byte{0x11})}},
+		{"truncated", []WildcardTreeOption{lower, WithHashLength(16)}},
+		{"nonce", []WildcardTreeOption{lower, WithLeafNonce(nonce)}},
+		{"blinded", []WildcardTreeOption{lower, WithBlindedPayloads()}},
+	} {
+		want := MustNewWildcardTree(twc, hash, m, table.opts...)
+		wt, err := NewWildcardTreeFromSorted(twc, hash, entries, table.opts...)
+		if err != nil {
+			t.Errorf("%s => construction failed: %v", table.desc, err)
+			continue
+		}
+		if !bytes.Equal(wt.Snapshot(), want.Snapshot()) {
+			t.Errorf("%s => got snapshot %x, want %x", table.desc, wt.Snapshot(),
+				want.Snapshot())
+		}
+		if got := wt.toMap(); !reflect.DeepEqual(got, want.toMap()) {
+			t.Errorf("%s => got %v, want %v", table.desc, got, want.toMap())
+		}
+	}
+
+	for _, table := range []struct {
+		desc string
+		opts []WildcardTreeOption
+	}{
+		{"validator", []WildcardTreeOption{WithKeyValidator(
+			func(key string) error {
+				if strings.ToLower(key) != key {
+					return errors.New("upper case")
+				}
+				return nil
+			})}},
+		{"limit", []WildcardTreeOption{lower, WithMaxKeyLength(8)}},
+		{"unsorted after normalization", []WildcardTreeOption{
+			WithKeyNormalizer(stringutil.Reverse)}},
+	} {
+		if _, err := NewWildcardTreeFromSorted(twc, hash, entries,
+			table.opts...); err == nil {
+			t.Errorf("%s => accepted bad entries", table.desc)
+		}
+	}
+}
+
+func BenchmarkNewWildcardTreeFromSorted(b *testing.B) {
+	const n = 100000
+	m := make(map[string]interface{}, n)
+	entries := make([]Entry, 0, n)
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("moc.%06d", i)
+		payload := [][]byte{[]byte(key + " cert")}
+		m[key] = payload
+		entries = append(entries, Entry{key, payload})
+	}
+	wt, err := NewWildcardTreeFromSorted(twc, hash, entries)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if want := MustNewWildcardTree(twc, hash, m).Snapshot(); !bytes.Equal(
+		wt.Snapshot(), want) {
+		b.Fatalf("got snapshot %x, want %x", wt.Snapshot(), want)
+	}
+	b.Run("NewWildcardTree", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			MustNewWildcardTree(twc, hash, m)
+		}
+	})
+	b.Run("NewWildcardTreeFromSorted", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := NewWildcardTreeFromSorted(twc, hash, entries); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
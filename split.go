@@ -0,0 +1,145 @@
+package lwm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Split outputs one proof per subject in a verified answer, such that the i:th
+// proof is a range proof for the i:th subject only, see Verify. Audit paths are
+// recomputed from the answer and the hashes in p, which means that the tree
+// is not queried again. The tree size is needed to place the subjects in the
+// Merkle tree, since it is not part of a proof.
+func (p Proof) Split(a Answer, fullSize int) ([]Proof, error) {
+	lindex, rindex := indices(&p, &a)
+	if len(a.subject) == 0 {
+		return nil, nil
+	}
+	if lindex < 0 || rindex >= fullSize {
+		return nil, fmt.Errorf("leaves [%d,%d] do not fit in a tree of size %d",
+			lindex, rindex, fullSize)
+	}
+	data, err := mkLeafData(&p, &a)
+	if err != nil {
+		return nil, err
+	}
+	lp, ip := p.prefixes()
+	rt := &rangeHasher{
+		mt:     NewMerkleTree(p.twc, lp, ip, p.hashFunc(), nil),
+		known:  make(map[[2]int][]byte),
+		data:   data,
+		lindex: lindex,
+	}
+	for _, path := range []struct {
+		m  int
+		ap [][]byte
+	}{
+		{lindex, p.lap},
+		{rindex, p.rap},
+	} {
+		if path.ap == nil {
+			continue
+		}
+		siblings := siblingRanges(path.m, 0, fullSize)
+		if len(siblings) != len(path.ap) {
+			return nil, errors.New("audit path does not fit the tree size")
+		}
+		for i, s := range siblings {
+			rt.known[s] = path.ap[i]
+		}
+	}
+
+	first := lindex
+	if p.ll != nil {
+		first++
+	}
+	proofs := make([]Proof, 0, len(a.subject))
+	for i := range a.subject {
+		m := first + i
+		q := p
+		q.index, q.ll, q.rl, q.lap, q.rap = m, nil, nil, nil, nil
+		if m+1 < fullSize {
+			if m+1 > rindex {
+				return nil, errors.New("expected right leaf")
+			}
+			q.rl = data[m+1-lindex]
+			if q.rap, err = rt.ap(m+1, fullSize); err != nil {
+				return nil, err
+			}
+		}
+		if m > 0 {
+			if m-1 < lindex {
+				return nil, errors.New("expected left leaf")
+			}
+			q.index = m - 1
+			q.ll = data[m-1-lindex]
+			if q.lap, err = rt.ap(m-1, fullSize); err != nil {
+				return nil, err
+			}
+		}
+		proofs = append(proofs, q)
+	}
+	return proofs, nil
+}
+
+// rangeHasher computes node hashes from a consecutive range of leaf data and
+// known hashes of subtrees that are outside of the range
+type rangeHasher struct {
+	mt     *MerkleTree
+	known  map[[2]int][]byte // subtree [lo,hi)->hash
+	data   [][]byte          // leaf data starting at index lindex
+	lindex int
+}
+
+// ap outputs the audit path of leaf m in a tree of size n
+func (rt *rangeHasher) ap(m, n int) ([][]byte, error) {
+	var ap [][]byte
+	for _, s := range siblingRanges(m, 0, n) {
+		h, err := rt.hash(s[0], s[1])
+		if err != nil {
+			return nil, err
+		}
+		ap = append(ap, h)
+	}
+	return ap, nil
+}
+
+// hash outputs the hash of the subtree that covers leaves [lo,hi)
+func (rt *rangeHasher) hash(lo, hi int) ([]byte, error) {
+	if h, ok := rt.known[[2]int{lo, hi}]; ok {
+		return h, nil
+	}
+	if hi <= rt.lindex || lo >= rt.lindex+len(rt.data) {
+		return nil, fmt.Errorf("missing hash for leaves [%d,%d)", lo, hi)
+	}
+	var h []byte
+	if hi-lo == 1 {
+		h = rt.mt.hash(rt.mt.twc, rt.mt.leafPrefix, rt.data[lo-rt.lindex])
+	} else {
+		k := lo + lpow2s(hi-lo)
+		left, err := rt.hash(lo, k)
+		if err != nil {
+			return nil, err
+		}
+		right, err := rt.hash(k, hi)
+		if err != nil {
+			return nil, err
+		}
+		h = rt.mt.hash(rt.mt.interiorPrefix, left, right)
+	}
+	rt.known[[2]int{lo, hi}] = h
+	return h, nil
+}
+
+// siblingRanges outputs the leaf ranges [lo,hi) of all siblings on the path
+// from leaf m to the root of the subtree [lo,hi), ordered like an audit path
+func siblingRanges(m, lo, hi int) [][2]int {
+	if hi-lo <= 1 {
+		return nil
+	}
+	k := lo + lpow2s(hi-lo)
+	if m < k {
+		return append(siblingRanges(m, lo, k), [2]int{k, hi})
+	}
+	return append(siblingRanges(m, k, hi), [2]int{lo, k})
+}
@@ -0,0 +1,63 @@
+package lwm
+
+import (
+	"github.com/golang/example/stringutil"
+	"testing"
+)
+
+func TestProofSplit(t *testing.T) {
+	for _, wt := range []*WildcardTree{
+		MustNewWildcardTree(twc, hash, testData()),
+		consistencyTree(37),
+	} {
+		for _, key := range []string{"", "e", "m", stringutil.Reverse("foo.com"),
+			stringutil.Reverse("sub1.foo.com"), "key0", "key01", "key036",
+			"zzz"} {
+			answer, proof := wt.Get(key)
+			proofs, err := proof.Split(answer, wt.Size())
+			if err != nil {
+				t.Errorf("%q => %v", key, err)
+				continue
+			}
+			if len(proofs) != len(answer.subject) {
+				t.Errorf("%q => got %d proofs, want %d", key, len(proofs),
+					len(answer.subject))
+				continue
+			}
+			original, err := proof.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			total := 0
+			for i, p := range proofs {
+				single := Answer{
+					subject: answer.subject[i : i+1],
+					payload: answer.payload[i : i+1],
+				}
+				if err := p.Verify(answer.subject[i], single, wt.Size(),
+					wt.Snapshot()); err != nil {
+					t.Errorf("%q => proof %d rejected: %v", key, i, err)
+				}
+				b, err := p.Marshal()
+				if err != nil {
+					t.Fatal(err)
+				}
+				total += len(b)
+			}
+			if len(proofs) > 1 && total <= len(original) {
+				t.Errorf("%q => split proofs have %d bytes, original %d", key,
+					total, len(original))
+			}
+		}
+	}
+
+	wt := MustNewWildcardTree(twc, hash, testData())
+	answer, proof := wt.Get("moc.oof")
+	if _, err := proof.Split(answer, wt.Size()-3); err == nil {
+		t.Errorf("accepted a tree size that is too small")
+	}
+	proof.rap = proof.rap[1:]
+	if _, err := proof.Split(answer, wt.Size()); err == nil {
+		t.Errorf("accepted an audit path that does not fit the tree size")
+	}
+}
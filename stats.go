@@ -0,0 +1,66 @@
+package lwm
+
+import (
+	"encoding/hex"
+	"math/bits"
+)
+
+// statsSamples is the maximum number of leaves that Stats samples audit path
+// lengths for
+const statsSamples = 100
+
+// TreeStats describes the shape of a tree
+type TreeStats struct {
+	LeafCount       int
+	MerkleTreeDepth int // ceil(log2(LeafCount)), i.e., the longest audit path
+	AuditPathMaxLen int
+	AuditPathMinLen int
+	AuditPathAvgLen float64
+	SnapshotHex     string
+}
+
+// Stats outputs statistics about the tree. Audit path lengths are computed for
+// every leaf in small trees, and for evenly spaced samples in larger trees.
+func (wt *WildcardTree) Stats() TreeStats {
+	n := wt.Size()
+	stats := TreeStats{
+		LeafCount:   n,
+		SnapshotHex: hex.EncodeToString(wt.Snapshot()),
+	}
+	stats.MerkleTreeDepth = wt.Height()
+	if n == 0 {
+		return stats
+	}
+
+	samples := min(n, statsSamples)
+	stats.AuditPathMinLen = stats.MerkleTreeDepth
+	sum := 0
+	for s := 0; s < samples; s++ {
+		i := s * (n - 1) / max(samples-1, 1) // includes the first and last leaf
+		l := apLen(i, n)
+		stats.AuditPathMaxLen = max(stats.AuditPathMaxLen, l)
+		stats.AuditPathMinLen = min(stats.AuditPathMinLen, l)
+		sum += l
+	}
+	stats.AuditPathAvgLen = float64(sum) / float64(samples)
+	return stats
+}
+
+// Height outputs the height of the Merkle tree, i.e., ceil(log2(max(n,1)))
+// for a tree of size n. This is the longest audit path of any leaf.
+func (wt *WildcardTree) Height() int {
+	if n := wt.Size(); n > 1 {
+		return bits.Len(uint(n - 1))
+	}
+	return 0
+}
+
+// DepthOf outputs the depth of key's leaf in the Merkle tree, i.e., the length
+// of its audit path. ErrKeyNotFound is returned if key is not present.
+func (wt *WildcardTree) DepthOf(key string) (int, error) {
+	v, ok := wt.r.Get(wt.normalizeKey(key))
+	if !ok {
+		return 0, ErrKeyNotFound
+	}
+	return apLen(v.(radixValue).index, wt.Size()), nil
+}
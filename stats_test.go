@@ -0,0 +1,89 @@
+package lwm
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	for _, table := range []struct {
+		size     int
+		depth    int
+		min, max int
+	}{
+		{0, 0, 0, 0},
+		{1, 0, 0, 0},
+		{2, 1, 1, 1},
+		{5, 3, 1, 3},
+		{7, 3, 2, 3},
+		{8, 3, 3, 3},
+		{1000, 10, 8, 10},
+	} {
+		wt := consistencyTree(table.size)
+		stats := wt.Stats()
+		if stats.LeafCount != table.size {
+			t.Errorf("size %d => got %d leaves", table.size, stats.LeafCount)
+		}
+		if stats.MerkleTreeDepth != table.depth {
+			t.Errorf("size %d => got depth %d, want %d", table.size,
+				stats.MerkleTreeDepth, table.depth)
+		}
+		if table.size > 0 && stats.MerkleTreeDepth != len(wt.mt.Ap(0)) {
+			t.Errorf("size %d => depth %d is not the audit path length of the "+
+				"first leaf", table.size, stats.MerkleTreeDepth)
+		}
+		if stats.AuditPathMinLen != table.min || stats.AuditPathMaxLen != table.max {
+			t.Errorf("size %d => got audit path lengths [%d,%d], want [%d,%d]",
+				table.size, stats.AuditPathMinLen, stats.AuditPathMaxLen, table.min,
+				table.max)
+		}
+		if avg := stats.AuditPathAvgLen; avg < float64(table.min) ||
+			avg > float64(table.max) {
+			t.Errorf("size %d => average %f is out of range", table.size, avg)
+		}
+		if stats.SnapshotHex != hex.EncodeToString(wt.Snapshot()) {
+			t.Errorf("size %d => got snapshot %s", table.size, stats.SnapshotHex)
+		}
+	}
+
+	// small trees are not sampled, so lengths match the audit paths exactly
+	wt := consistencyTree(7)
+	sum := 0
+	for i := 0; i < wt.Size(); i++ {
+		sum += len(wt.mt.Ap(i))
+	}
+	if got, want := wt.Stats().AuditPathAvgLen, float64(sum)/7; got != want {
+		t.Errorf("got average %f, want %f", got, want)
+	}
+}
+
+func TestHeightAndDepthOf(t *testing.T) {
+	for _, size := range []int{0, 1, 2, 3, 5, 8, 13, 100} {
+		wt := consistencyTree(size)
+		if size > 0 && wt.Height() != len(wt.mt.Ap(0)) {
+			t.Errorf("size %d => got height %d, want %d", size, wt.Height(),
+				len(wt.mt.Ap(0)))
+		}
+		for i := 0; i < size; i++ {
+			depth, err := wt.DepthOf(fmt.Sprintf("key%03d", i))
+			if err != nil {
+				t.Errorf("size %d, leaf %d => %v", size, i, err)
+			} else if want := len(wt.mt.Ap(i)); depth != want {
+				t.Errorf("size %d, leaf %d => got depth %d, want %d", size, i,
+					depth, want)
+			}
+			if depth > wt.Height() {
+				t.Errorf("size %d, leaf %d => depth %d exceeds height %d", size, i,
+					depth, wt.Height())
+			}
+		}
+		if _, err := wt.DepthOf("key"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("size %d => got %v for absent key", size, err)
+		}
+	}
+	if got := consistencyTree(0).Height(); got != 0 {
+		t.Errorf("empty tree => got height %d", got)
+	}
+}
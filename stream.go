@@ -0,0 +1,60 @@
+package lwm
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxLeafLineSize is the maximum line size of NewMerkleTreeFromReader
+const DefaultMaxLeafLineSize = 64 * 1024
+
+// NewMerkleTreeFromReader is like NewMerkleTree, but reads leaf data from r
+// with one hex-encoded leaf per line. Empty lines are skipped. An error is
+// returned for malformed hex and lines longer than DefaultMaxLeafLineSize.
+func NewMerkleTreeFromReader(twc, leafPrefix, interiorPrefix []byte,
+	hash func(data ...[]byte) []byte, r io.Reader) (*MerkleTree, error) {
+	return NewMerkleTreeFromReaderSize(twc, leafPrefix, interiorPrefix, hash, r,
+		DefaultMaxLeafLineSize)
+}
+
+// NewMerkleTreeFromReaderSize is like NewMerkleTreeFromReader, but with a
+// given maximum line size in bytes (excluding the newline)
+func NewMerkleTreeFromReaderSize(twc, leafPrefix, interiorPrefix []byte,
+	hash func(data ...[]byte) []byte, r io.Reader, maxLineSize int) (*MerkleTree,
+	error) {
+	if maxLineSize <= 0 {
+		return nil, fmt.Errorf("maximum line size %d is not positive",
+			maxLineSize)
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, min(maxLineSize+2, 4096)), maxLineSize+2) // "\r\n"
+	var data [][]byte
+	line := 0
+	for s.Scan() {
+		line++
+		b := s.Bytes() // without "\n" or "\r\n"
+		if len(b) == 0 {
+			continue
+		}
+		if len(b) > maxLineSize {
+			return nil, fmt.Errorf("line %d: longer than %d bytes", line,
+				maxLineSize)
+		}
+		leaf := make([]byte, hex.DecodedLen(len(b)))
+		if _, err := hex.Decode(leaf, b); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		data = append(data, leaf)
+	}
+	if err := s.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("line %d: longer than %d bytes", line+1,
+				maxLineSize)
+		}
+		return nil, err
+	}
+	return NewMerkleTree(twc, leafPrefix, interiorPrefix, hash, data), nil
+}
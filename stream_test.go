@@ -0,0 +1,71 @@
+package lwm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestNewMerkleTreeFromReader(t *testing.T) {
+	const n = 100000
+	var data [][]byte
+	buf := new(bytes.Buffer)
+	for i := 0; i < n; i++ {
+		leaf := hash(binary.BigEndian.AppendUint32(nil, uint32(i)))
+		data = append(data, leaf)
+		buf.WriteString(hex.EncodeToString(leaf))
+		buf.WriteString("\n")
+		if i%1000 == 0 {
+			buf.WriteString("\n") // skipped
+		}
+	}
+	mt, err := NewMerkleTreeFromReader(twc, leafPrefix, interiorPrefix, hash, buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if got := mt.Size(); got != n {
+		t.Errorf("got %d leaves, want %d", got, n)
+	}
+	want := NewMerkleTree(twc, leafPrefix, interiorPrefix, hash, data).Mth()
+	if got := mt.Mth(); !bytes.Equal(got, want) {
+		t.Errorf("got root %x, want %x", got, want)
+	}
+
+	mt, err = NewMerkleTreeFromReader(twc, leafPrefix, interiorPrefix, hash,
+		strings.NewReader(""))
+	if err != nil || mt.Size() != 0 {
+		t.Errorf("empty reader => got %v", err)
+	}
+}
+
+func TestNewMerkleTreeFromReaderErrors(t *testing.T) {
+	for _, table := range []struct {
+		desc    string
+		in      string
+		maxSize int
+		err     string
+	}{
+		{"bad hex", "00\nzz\n", 8, "line 2"},
+		{"odd length", "00\n\n012\n", 8, "line 3"},
+		{"line too long", "00\n0123456789\n", 8, "line 2"},
+		{"last line too long", "00\n001122334\n", 8, "line 2"},
+		{"bad max size", "00\n", 0, "not positive"},
+	} {
+		_, err := NewMerkleTreeFromReaderSize(twc, leafPrefix, interiorPrefix,
+			hash, strings.NewReader(table.in), table.maxSize)
+		if err == nil {
+			t.Errorf("%s => accepted", table.desc)
+		} else if !strings.Contains(err.Error(), table.err) {
+			t.Errorf("%s => got error %q, want %q", table.desc, err, table.err)
+		}
+	}
+
+	// lines of exactly the maximum size are accepted, also with "\r\n"
+	mt, err := NewMerkleTreeFromReaderSize(twc, leafPrefix, interiorPrefix, hash,
+		strings.NewReader("00112233\r\n44556677"), 8)
+	if err != nil || mt.Size() != 2 {
+		t.Errorf("maximum size lines => got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// SubsetProof proves that every entry of a tree is also in another tree, e.g.,
+// that a sub-CA's tree is a subset of the root CA's tree. Each range proof
+// covers entries that are adjacent in the other tree.
+type SubsetProof struct {
+	twc                        []byte                      // subset's twc
+	hash                       func(data ...[]byte) []byte // subset's hash
+	leafPrefix, interiorPrefix []byte                      // subset's prefixes
+	answers                    []Answer
+	proofs                     []Proof
+}
+
+// ProveSubset outputs a proof that every key of sub is in full with the same
+// payload. An error is returned if a key is absent from full, if a payload
+// differs, or if either tree uses leaf nonces or blinded payloads. The subset
+// must also use untruncated hashes, since its snapshot is recomputed from the
+// payloads when the proof is verified.
+func ProveSubset(sub, full *WildcardTree) (SubsetProof, error) {
+	sp := SubsetProof{
+		twc:            sub.mt.twc,
+		hash:           sub.hash(),
+		leafPrefix:     sub.mt.leafPrefix,
+		interiorPrefix: sub.mt.interiorPrefix,
+	}
+	for _, wt := range []*WildcardTree{sub, full} {
+		if wt.nonce != nil || wt.blinded {
+			return sp, errors.New("leaf nonces and blinded payloads are not " +
+				"supported")
+		}
+	}
+	if sub.hashLength > 0 {
+		return sp, errors.New("subset uses truncated hashes")
+	}
+
+	// find runs of keys that are adjacent in full
+	var runs [][2]string
+	var first, last string
+	var err error
+	prev := -1
+	sub.r.WalkPrefix("", func(key string, v interface{}) bool {
+		fv, ok := full.r.Get(key)
+		if !ok {
+			err = fmt.Errorf("key %q: %w", key, ErrKeyNotFound)
+			return true
+		}
+		index := fv.(radixValue).index
+		if !bytes.Equal(full.mt.hash(v.(radixValue).payload...),
+			full.payloadHash(full.mt.data[index])) {
+			err = fmt.Errorf("key %q has a different payload", key)
+			return true
+		}
+		if prev >= 0 && index == prev+1 {
+			last, prev = key, index
+			return false
+		}
+		if prev >= 0 {
+			runs = append(runs, [2]string{first, last})
+		}
+		first, last, prev = key, key, index
+		return false
+	})
+	if err != nil {
+		return sp, err
+	}
+	if prev >= 0 {
+		runs = append(runs, [2]string{first, last})
+	}
+
+	for _, run := range runs {
+		answer, proof, err := full.Range(run[0], run[1])
+		if err != nil {
+			return sp, err
+		}
+		sp.answers = append(sp.answers, answer)
+		sp.proofs = append(sp.proofs, proof)
+	}
+	return sp, nil
+}
+
+// Verify outputs true if the proof shows that every entry of the tree with
+// snapshot subSnapshot is in the tree with snapshot fullSnapshot and size
+// fullSize
+func (sp SubsetProof) Verify(subSnapshot, fullSnapshot []byte,
+	fullSize int) bool {
+	if sp.hash == nil || len(sp.answers) != len(sp.proofs) {
+		return false
+	}
+	var leaves [][]byte
+	var prev string
+	for i, a := range sp.answers {
+		n := len(a.subject)
+		if n == 0 || n != len(a.payload) {
+			return false
+		}
+		if i > 0 && a.subject[0] <= prev {
+			return false
+		}
+		if sp.proofs[i].VerifyRange(a.subject[0], a.subject[n-1], a, fullSize,
+			fullSnapshot) != nil {
+			return false
+		}
+		for j, subject := range a.subject {
+			leaves = append(leaves, append([]byte(subject),
+				sp.hash(a.payload[j]...)...))
+		}
+		prev = a.subject[n-1]
+	}
+	lp, ip := sp.leafPrefix, sp.interiorPrefix
+	if lp == nil {
+		lp = leafPrefix
+	}
+	if ip == nil {
+		ip = interiorPrefix
+	}
+	mt := NewMerkleTree(sp.twc, lp, ip, sp.hash, leaves)
+	return bytes.Equal(mt.Mth(), subSnapshot)
+}
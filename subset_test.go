@@ -0,0 +1,64 @@
+package lwm
+
+import (
+	"errors"
+	"github.com/golang/example/stringutil"
+	"testing"
+)
+
+func TestSubsetProof(t *testing.T) {
+	full := MustNewWildcardTree(twc, hash, testData())
+	m := testData()
+	for _, name := range []string{"sub2.foo.com", "baz.gov"} {
+		delete(m, stringutil.Reverse(name))
+	}
+	for _, table := range []struct {
+		desc   string
+		m      map[string]interface{}
+		ranges int
+	}{
+		{"subset", m, 2},
+		{"equal", testData(), 1},
+		{"empty", nil, 0},
+	} {
+		sub := MustNewWildcardTree(twc, hash, table.m)
+		sp, err := ProveSubset(sub, full)
+		if err != nil {
+			t.Errorf("%s => %v", table.desc, err)
+			continue
+		}
+		if len(sp.proofs) != table.ranges {
+			t.Errorf("%s => got %d range proofs, want %d", table.desc,
+				len(sp.proofs), table.ranges)
+		}
+		if !sp.Verify(sub.Snapshot(), full.Snapshot(), full.Size()) {
+			t.Errorf("%s => valid proof rejected", table.desc)
+		}
+		if len(sp.proofs) > 0 && sp.Verify(full.Snapshot(), sub.Snapshot(),
+			sub.Size()) && table.desc != "equal" {
+			t.Errorf("%s => swapped snapshots accepted", table.desc)
+		}
+		if sp.Verify(hash([]byte("bad")), full.Snapshot(), full.Size()) {
+			t.Errorf("%s => bad subset snapshot accepted", table.desc)
+		}
+		if len(sp.proofs) > 0 && sp.Verify(sub.Snapshot(), hash([]byte("bad")),
+			full.Size()) {
+			t.Errorf("%s => bad full snapshot accepted", table.desc)
+		}
+	}
+
+	sub := MustNewWildcardTree(twc, hash, m)
+	if err := sub.Add("ten.oof", [][]byte{[]byte("foo.net cert")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProveSubset(sub, full); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("key not in full => got error %v", err)
+	}
+	sub = MustNewWildcardTree(twc, hash, m)
+	if err := sub.Update("moc.oof", [][]byte{[]byte("other cert")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProveSubset(sub, full); err == nil {
+		t.Errorf("different payload => accepted")
+	}
+}
@@ -0,0 +1,84 @@
+package lwm
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// SubtreeAp computes an audit path for the subtree of subtreeSize leaves that
+// starts at leaf subtreeStart, i.e., the sibling hashes on the path from the
+// subtree's root to the tree's root, ordered from the subtree
+func (mt *MerkleTree) SubtreeAp(subtreeStart, subtreeSize int) ([][]byte,
+	error) {
+	if err := checkSubtree(subtreeStart, subtreeSize, len(mt.data)); err != nil {
+		return nil, err
+	}
+	mt.Mth() // subtreeAp relies on a populated cache
+	return mt.subtreeAp(subtreeStart, subtreeSize, mt.data, mt.cache), nil
+}
+
+func (mt *MerkleTree) subtreeAp(start, size int, data [][]byte,
+	c *hashCache) [][]byte {
+	if len(data) == size {
+		return nil
+	}
+	k := lpow2s(len(data))
+	if start < k {
+		return append(mt.subtreeAp(start, size, data[:k], c.left),
+			mt.mth(data[k:], c.right))
+	}
+	return append(mt.subtreeAp(start-k, size, data[k:], c.right),
+		mt.mth(data[:k], c.left))
+}
+
+// MthFromSubtreeAp builds a root hash for a tree of size fullSize from the root
+// hash of a subtree and its audit path, see SubtreeAp. The subtree has
+// subtreeSize leaves and starts at leaf subtreeStart. An error is returned if
+// there is no such subtree or if the audit path has the wrong length.
+func (mt *MerkleTree) MthFromSubtreeAp(subtreeRoot []byte, subtreeStart,
+	subtreeSize, fullSize int, ap [][]byte) ([]byte, error) {
+	if err := checkSubtree(subtreeStart, subtreeSize, fullSize); err != nil {
+		return nil, err
+	}
+
+	// same as MthFromAp, but the subtree is a node on a higher level
+	level := bits.TrailingZeros(uint(subtreeSize))
+	index, lastIndex := subtreeStart>>level, (fullSize-1)>>level
+	r := subtreeRoot
+	for lastIndex > 0 {
+		if index%2 == 1 || index < lastIndex {
+			if len(ap) == 0 {
+				return nil, errors.New("malformed proof: too few hashes")
+			}
+			if index%2 == 1 {
+				r = mt.hash(mt.interiorPrefix, ap[0], r)
+			} else {
+				r = mt.hash(mt.interiorPrefix, r, ap[0])
+			}
+			ap = ap[1:]
+		}
+		index, lastIndex = index/2, lastIndex/2
+	}
+	if len(ap) != 0 {
+		return nil, errors.New("malformed proof: too many hashes")
+	}
+	return r, nil
+}
+
+// checkSubtree outputs an error unless a tree of size n has a subtree with size
+// leaves that starts at leaf start. Such subtrees are complete and aligned.
+func checkSubtree(start, size, n int) error {
+	if size <= 0 || size&(size-1) != 0 {
+		return fmt.Errorf("subtree size %d is not a power of 2", size)
+	}
+	if start < 0 || start%size != 0 {
+		return fmt.Errorf("subtree start %d is not aligned to size %d", start,
+			size)
+	}
+	if start+size > n {
+		return fmt.Errorf("subtree [%d,%d) is not in a tree of size %d", start,
+			start+size, n)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package lwm
+
+import (
+	"bytes"
+	"math/bits"
+	"testing"
+)
+
+func TestMthFromSubtreeAp(t *testing.T) {
+	for n := 1; n <= 33; n++ {
+		data := leafData(n)
+		mt := NewMerkleTree(testTwc, lp, ip, hash, data)
+		root := mt.Mth()
+		for size := 1; size <= n; size *= 2 {
+			for start := 0; start+size <= n; start += size {
+				subtreeRoot := NewMerkleTree(testTwc, lp, ip, hash,
+					data[start:start+size]).Mth()
+				ap, err := mt.SubtreeAp(start, size)
+				if err != nil {
+					t.Errorf("n=%d [%d,%d) => %v", n, start, start+size, err)
+					continue
+				}
+				if maxLen := bits.Len(uint((n - 1) / size)); len(ap) > maxLen {
+					t.Errorf("n=%d [%d,%d) => got %d hashes, want at most %d", n,
+						start, start+size, len(ap), maxLen)
+				}
+				got, err := mt.MthFromSubtreeAp(subtreeRoot, start, size, n, ap)
+				if err != nil || !bytes.Equal(got, root) {
+					t.Errorf("n=%d [%d,%d) => got root %x (%v), want %x", n, start,
+						start+size, got, err, root)
+				}
+				if got, _ := mt.MthFromSubtreeAp(hash([]byte("bad")), start, size,
+					n, ap); bytes.Equal(got, root) {
+					t.Errorf("n=%d [%d,%d) => bad subtree root accepted", n, start,
+						start+size)
+				}
+				if _, err := mt.MthFromSubtreeAp(subtreeRoot, start, size, n,
+					append(ap, root)); err == nil {
+					t.Errorf("n=%d [%d,%d) => too many hashes accepted", n, start,
+						start+size)
+				}
+				if len(ap) > 0 {
+					if _, err := mt.MthFromSubtreeAp(subtreeRoot, start, size, n,
+						ap[1:]); err == nil {
+						t.Errorf("n=%d [%d,%d) => too few hashes accepted", n, start,
+							start+size)
+					}
+				}
+			}
+		}
+	}
+
+	mt := NewMerkleTree(testTwc, lp, ip, hash, leafData(8))
+	for _, table := range []struct {
+		desc                  string
+		start, size, fullSize int
+	}{
+		{"empty subtree", 0, 0, 8},
+		{"size is not a power of 2", 0, 3, 8},
+		{"unaligned start", 2, 4, 8},
+		{"negative start", -4, 4, 8},
+		{"outside of tree", 8, 1, 8},
+		{"larger than tree", 0, 8, 7},
+	} {
+		if _, err := mt.MthFromSubtreeAp(nil, table.start, table.size,
+			table.fullSize, nil); err == nil {
+			t.Errorf("%s => accepted", table.desc)
+		}
+		if table.fullSize == 8 {
+			if _, err := mt.SubtreeAp(table.start, table.size); err == nil {
+				t.Errorf("%s => audit path generated", table.desc)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Package testing checks wildcard trees against a reference implementation
+// that is simple enough to be obviously correct
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/rgdd/lwm"
+	"sort"
+	"strings"
+	gotesting "testing"
+)
+
+// ReferenceWildcardTree answers wildcard queries by linear search in a sorted
+// list of keys, i.e., without any radix tree or Merkle tree
+type ReferenceWildcardTree struct {
+	keys     []string
+	payloads map[string][][]byte
+}
+
+// NewReferenceWildcardTree outputs a reference tree for the same entries as
+// lwm.NewWildcardTree. Values that are not [][]byte have no payload.
+func NewReferenceWildcardTree(
+	entries map[string]interface{}) *ReferenceWildcardTree {
+	rt := &ReferenceWildcardTree{payloads: make(map[string][][]byte)}
+	for key, v := range entries {
+		rt.keys = append(rt.keys, key)
+		if payload, ok := v.([][]byte); ok {
+			rt.payloads[key] = payload
+		}
+	}
+	sort.Strings(rt.keys)
+	return rt
+}
+
+// Get outputs all keys that have key as a prefix in lexicographic order,
+// together with their payloads
+func (rt *ReferenceWildcardTree) Get(key string) ([]string, [][][]byte) {
+	var subjects []string
+	var payloads [][][]byte
+	for _, k := range rt.keys {
+		if strings.HasPrefix(k, key) {
+			subjects = append(subjects, k)
+			payloads = append(payloads, rt.payloads[k])
+		}
+	}
+	return subjects, payloads
+}
+
+// DifferentialTest builds a wildcard tree and a reference tree for entries,
+// and fails t unless every query has the same subjects in both trees. Payloads
+// are compared if they are [][]byte, and every proof must be valid.
+func DifferentialTest(t *gotesting.T, entries map[string]interface{},
+	queries []string) {
+	t.Helper()
+	wt, err := lwm.NewWildcardTree([]byte("differential test"),
+		lwm.HashFunctions["sha256"], entries)
+	if err != nil {
+		t.Fatalf("new wildcard tree: %v", err)
+	}
+	rt := NewReferenceWildcardTree(entries)
+	for _, query := range queries {
+		answer, proof := wt.Get(query)
+		subjects, payloads := rt.Get(query)
+		if got, want := fmt.Sprintf("%q", answer.Subjects()),
+			fmt.Sprintf("%q", subjects); got != want {
+			t.Errorf("%q => got subjects %s, want %s", query, got, want)
+			continue
+		}
+		for i, subject := range subjects {
+			if _, ok := entries[subject].([][]byte); ok &&
+				!equalPayloads(answer.Payloads()[i], payloads[i]) {
+				t.Errorf("%q => bad payload for subject %q", query, subject)
+			}
+		}
+		if err := proof.Verify(query, answer, wt.Size(),
+			wt.Snapshot()); err != nil {
+			t.Errorf("%q => %v", query, err)
+		}
+	}
+}
+
+// equalPayloads outputs true if two payloads have the same items
+func equalPayloads(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
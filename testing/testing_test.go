@@ -0,0 +1,55 @@
+package testing
+
+import (
+	"math/rand"
+	"reflect"
+	gotesting "testing"
+	"testing/quick"
+)
+
+func TestDifferentialTestData(t *gotesting.T) {
+	entries := map[string]interface{}{
+		"moc.oof": [][]byte{[]byte("foo.com cert1"),
+			[]byte("foo.com cert2")},
+		"moc.oof.1bus": [][]byte{[]byte("sub1.foo.com cert")},
+		"moc.oof.2bus": [][]byte{[]byte("sub2.foo.com cert")},
+		"ude.rab.bus":  [][]byte{[]byte("sub.bar.edu cert")},
+		"vog.zab":      [][]byte{[]byte("baz.gov cert")},
+		"es.xuq":       [][]byte{[]byte("qux.se cert")},
+		"es.xuq.bus":   [][]byte{[]byte("sub.qux.se cert")},
+	}
+	DifferentialTest(t, entries, append(prefixes(entries), "a", "es.xuq.",
+		"moc.oof.0", "moc.oof.3", "ten", "zzz"))
+}
+
+func TestDifferentialRandom(t *gotesting.T) {
+	rnd := rand.New(rand.NewSource(1))
+	typ := reflect.TypeOf(map[string][][]byte{})
+	for i := 0; i < 100; i++ {
+		v, ok := quick.Value(typ, rnd)
+		if !ok {
+			t.Fatalf("failed to generate a random map")
+		}
+		entries := make(map[string]interface{})
+		for key, payload := range v.Interface().(map[string][][]byte) {
+			entries[key] = payload
+		}
+		queries := prefixes(entries)
+		for j := 0; j < 10; j++ {
+			query, _ := quick.Value(reflect.TypeOf(""), rnd)
+			queries = append(queries, query.String())
+		}
+		DifferentialTest(t, entries, queries)
+	}
+}
+
+// prefixes outputs every prefix of every key, including the empty string
+func prefixes(entries map[string]interface{}) []string {
+	var queries []string
+	for key := range entries {
+		for i := 0; i <= len(key); i++ {
+			queries = append(queries, key[:i])
+		}
+	}
+	return queries
+}
@@ -0,0 +1,56 @@
+package lwm
+
+import (
+	"bytes"
+	"crypto/subtle"
+)
+
+// TranscriptEntry describes a single step of proof verification
+type TranscriptEntry struct {
+	Step   string   // "empty", "leaf", "interior", or "root comparison"
+	Input  [][]byte // hashed data, or the recomputed and expected roots
+	Output []byte   // hash output, or the recomputed root
+}
+
+// Transcript verifies a proof like Verify, but also outputs every hash that is
+// computed while recomputing the root from leaf data and audit paths. The last
+// entry compares the recomputed root with snapshot, unless verification failed
+// before a root was recomputed. The boolean is true if verification succeeded.
+func (p Proof) Transcript(key string, a Answer, size int,
+	snapshot []byte) ([]TranscriptEntry, bool) {
+	var t []TranscriptEntry
+	lp, ip := p.prefixes()
+	h := p.hashFunc()
+	mt := NewMerkleTree(p.twc, lp, ip, func(data ...[]byte) []byte {
+		out := h(data...)
+		t = append(t, TranscriptEntry{
+			Step:   transcriptStep(data, p.twc, lp),
+			Input:  cloneData(data),
+			Output: cloneBytes(out),
+		})
+		return out
+	}, nil)
+	root, err := p.recompute(mt, key, a, size)
+	if err != nil {
+		return t, false
+	}
+	t = append(t, TranscriptEntry{
+		Step:   "root comparison",
+		Input:  [][]byte{cloneBytes(root), cloneBytes(snapshot)},
+		Output: cloneBytes(root),
+	})
+	return t, subtle.ConstantTimeCompare(snapshot, root) == 1
+}
+
+// transcriptStep outputs the kind of node that a Merkle tree hashes data for
+func transcriptStep(data [][]byte, twc, leafPrefix []byte) string {
+	switch {
+	case len(data) == 1:
+		return "empty"
+	case len(data) == 3 && bytes.Equal(data[0], twc) &&
+		bytes.Equal(data[1], leafPrefix):
+		return "leaf"
+	default:
+		return "interior"
+	}
+}
@@ -0,0 +1,53 @@
+package lwm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTranscript(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, map[string]interface{}{
+		"a": [][]byte{[]byte("payload a")},
+		"b": [][]byte{[]byte("payload b")},
+	})
+	answer, proof := wt.Get("a")
+	transcript, ok := proof.Transcript("a", answer, wt.Size(), wt.Snapshot())
+	if !ok {
+		t.Fatalf("valid proof rejected")
+	}
+
+	// two leaves, their parent, and the root comparison
+	steps := []string{"leaf", "leaf", "interior", "root comparison"}
+	if len(transcript) != len(steps) {
+		t.Fatalf("got %d entries, want %d", len(transcript), len(steps))
+	}
+	for i, step := range steps {
+		if transcript[i].Step != step {
+			t.Errorf("entry %d => got step %q, want %q", i, transcript[i].Step,
+				step)
+		}
+	}
+	for i, leaf := range wt.mt.data {
+		if got := transcript[i].Input[2]; !bytes.Equal(got, leaf) {
+			t.Errorf("entry %d => got leaf data %x, want %x", i, got, leaf)
+		}
+	}
+	if got := transcript[len(transcript)-1].Output; !bytes.Equal(got,
+		wt.Snapshot()) {
+		t.Errorf("got root %x, want %x", got, wt.Snapshot())
+	}
+	if got := transcript[2].Output; !bytes.Equal(got, wt.Snapshot()) {
+		t.Errorf("interior hash %x is not the root", got)
+	}
+
+	transcript, ok = proof.Transcript("a", answer, wt.Size(),
+		hash([]byte("bad")))
+	if ok || len(transcript) != len(steps) {
+		t.Errorf("bad snapshot => got ok %v with %d entries", ok,
+			len(transcript))
+	}
+	if transcript, ok = proof.Transcript("a", answer, 1,
+		wt.Snapshot()); ok || len(transcript) != 0 {
+		t.Errorf("bad size => got ok %v with %d entries", ok, len(transcript))
+	}
+}
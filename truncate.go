@@ -0,0 +1,89 @@
+package lwm
+
+import (
+	"fmt"
+)
+
+// maxHashLength is the largest hash length that WithHashLength accepts
+const maxHashLength = 64
+
+// WithHashLength truncates every hash of the tree to n bytes, e.g., to reduce
+// proof sizes at the cost of security. Proofs carry the hash length, and it is
+// also part of their JSON encoding. An error is returned on construction if n
+// is not in [1,64] or larger than the hash function's output.
+func WithHashLength(n int) WildcardTreeOption {
+	return func(wt *WildcardTree) {
+		if n < 1 || n > maxHashLength {
+			wt.err = fmt.Errorf("hash length %d is not in [1,%d]", n,
+				maxHashLength)
+			return
+		}
+		wt.hashLength = n
+	}
+}
+
+// truncatedHash outputs a hash function that truncates the output of h to n
+// bytes, or h if n is zero
+func truncatedHash(h func(data ...[]byte) []byte,
+	n int) func(data ...[]byte) []byte {
+	if n == 0 {
+		return h
+	}
+	return func(data ...[]byte) []byte {
+		return h(data...)[:n:n]
+	}
+}
+
+// hash outputs the tree's hash function before truncation
+func (wt *WildcardTree) hash() func(data ...[]byte) []byte {
+	if wt.untruncated != nil {
+		return wt.untruncated
+	}
+	return wt.mt.hash
+}
+
+// hashLen outputs the length of payload hashes in the tree's leaves
+func (wt *WildcardTree) hashLen() int {
+	if wt.hashLength > 0 {
+		return wt.hashLength
+	}
+	return hashLen
+}
+
+// leafKey outputs the key of a leaf's data in the tree
+func (wt *WildcardTree) leafKey(data []byte) string {
+	key, _ := leafKey(data, wt.nonceLength, wt.hashLen())
+	return key
+}
+
+// payloadHash outputs the payload hash of a leaf's data in the tree
+func (wt *WildcardTree) payloadHash(data []byte) []byte {
+	h, _ := leafHash(data, wt.hashLen())
+	return h
+}
+
+// HashLength outputs the length of the proof's hashes if they are truncated,
+// see WithHashLength, and zero otherwise
+func (p Proof) HashLength() int {
+	return p.hashLength
+}
+
+// hashFunc outputs the proof's hash function after truncation
+func (p Proof) hashFunc() func(data ...[]byte) []byte {
+	return truncatedHash(p.hash, p.hashLength)
+}
+
+// hashLen outputs the length of payload hashes in the proof's leaves
+func (p Proof) hashLen() int {
+	if p.hashLength > 0 {
+		return p.hashLength
+	}
+	return hashLen
+}
+
+// leafKey outputs the key of a leaf's data in the proof, or an empty key if
+// data is invalid
+func (p Proof) leafKey(data []byte) string {
+	key, _ := leafKey(data, p.nonceLength, p.hashLen())
+	return key
+}
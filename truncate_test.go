@@ -0,0 +1,90 @@
+package lwm
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/golang/example/stringutil"
+	"testing"
+)
+
+func TestWithHashLength(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData(), WithHashLength(16))
+	if got := len(wt.Snapshot()); got != 16 {
+		t.Fatalf("got snapshot length %d, want 16", got)
+	}
+	for _, data := range wt.mt.data {
+		key := wt.leafKey(data)
+		if v, ok := testData()[key]; !ok ||
+			!bytes.Equal(data[len(key):], hash(v.([][]byte)...)[:16]) {
+			t.Errorf("bad leaf data %x", data)
+		}
+	}
+
+	for _, name := range []string{"foo.com", "sub1.foo.com", "bar.se", "zzz"} {
+		key := stringutil.Reverse(name)
+		answer, proof := wt.Get(key)
+		if proof.HashLength() != 16 {
+			t.Errorf("%s => got hash length %d", name, proof.HashLength())
+		}
+		for _, h := range append(proof.LeftAP(), proof.RightAP()...) {
+			if len(h) != 16 {
+				t.Errorf("%s => got audit path hash length %d", name, len(h))
+			}
+		}
+		if err := proof.Verify(key, answer, wt.Size(), wt.Snapshot()); err != nil {
+			t.Errorf("%s => %v", name, err)
+		}
+		if len(proof.LeftAP())+len(proof.RightAP()) > 0 {
+			untruncated := proof
+			untruncated.hashLength = 0
+			if err := untruncated.Verify(key, answer, wt.Size(),
+				wt.Snapshot()); err == nil {
+				t.Errorf("%s => verified without hash length", name)
+			}
+		}
+
+		b, err := json.Marshal(proof)
+		if err != nil {
+			t.Fatalf("%s => marshal failed: %v", name, err)
+		}
+		var pp Proof
+		if err := json.Unmarshal(b, &pp); err != nil {
+			t.Fatalf("%s => unmarshal failed: %v", name, err)
+		}
+		if err := pp.Verify(key, answer, wt.Size(), wt.Snapshot()); err != nil {
+			t.Errorf("%s => JSON round-trip: %v", name, err)
+		}
+		if _, err := proof.Marshal(); err == nil {
+			t.Errorf("%s => binary encoding of truncated proof", name)
+		}
+	}
+
+	np, err := wt.GetAbsenceProof(stringutil.Reverse("bar.se"))
+	if err != nil || !np.VerifyAbsence(stringutil.Reverse("bar.se"),
+		wt.Snapshot()) {
+		t.Errorf("absence proof failed: %v", err)
+	}
+	if got := wt.Compact().Snapshot(); !bytes.Equal(got, wt.Snapshot()) {
+		t.Errorf("compact => got snapshot %x, want %x", got, wt.Snapshot())
+	}
+	if _, err := wt.Marshal("sha256"); err == nil {
+		t.Errorf("binary encoding of truncated tree")
+	}
+
+	// no truncation if the length is that of the hash function
+	full := MustNewWildcardTree(twc, hash, testData(), WithHashLength(hashLen))
+	if want := MustNewWildcardTree(twc, hash, testData()).Snapshot(); !bytes.Equal(
+		full.Snapshot(), want) {
+		t.Errorf("full length => got snapshot %x, want %x", full.Snapshot(), want)
+	}
+	for _, n := range []int{-1, 0, 33, 65} {
+		if _, err := NewWildcardTree(twc, hash, testData(),
+			WithHashLength(n)); err == nil {
+			t.Errorf("accepted hash length %d", n)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"hash_algorithm":"sha256",`+
+		`"hash_length":32}`), new(Proof)); err == nil {
+		t.Errorf("accepted JSON with untruncated hash length")
+	}
+}
@@ -0,0 +1,129 @@
+package lwm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Transaction batches mutations of a WildcardTree, such that either all of
+// them take effect with a single rebuild of the Merkle tree or none of them.
+// A transaction is not safe for concurrent use.
+type Transaction struct {
+	wt   *WildcardTree
+	ops  []txOp
+	done bool // committed or rolled back
+}
+
+type txOp struct {
+	kind    txKind
+	key     string
+	payload [][]byte
+}
+
+type txKind int
+
+const (
+	txAdd txKind = iota
+	txRemove
+	txUpdate
+)
+
+func (k txKind) String() string {
+	switch k {
+	case txAdd:
+		return "add"
+	case txRemove:
+		return "remove"
+	}
+	return "update"
+}
+
+// event outputs the kind of change event that an operation emits
+func (k txKind) event() TreeEventKind {
+	switch k {
+	case txAdd:
+		return EventAdded
+	case txRemove:
+		return EventRemoved
+	}
+	return EventUpdated
+}
+
+// Begin starts a new transaction. Operations are checked against the tree when
+// the transaction is committed, not when they are added.
+func (wt *WildcardTree) Begin() *Transaction {
+	return &Transaction{wt: wt}
+}
+
+// Add is like WildcardTree.Add, but takes effect on commit
+func (tx *Transaction) Add(key string, payload [][]byte) {
+	tx.ops = append(tx.ops, txOp{txAdd, key, payload})
+}
+
+// Remove is like WildcardTree.Remove, but takes effect on commit
+func (tx *Transaction) Remove(key string) {
+	tx.ops = append(tx.ops, txOp{kind: txRemove, key: key})
+}
+
+// Update is like WildcardTree.Update, but takes effect on commit
+func (tx *Transaction) Update(key string, payload [][]byte) {
+	tx.ops = append(tx.ops, txOp{txUpdate, key, payload})
+}
+
+// Commit applies all operations in order and outputs the new snapshot. If an
+// operation fails, e.g., because it adds a key that an earlier operation added,
+// an error is returned and the tree is unchanged. The transaction is done
+// after Commit, even if it fails.
+func (tx *Transaction) Commit() ([]byte, error) {
+	if tx.done {
+		return nil, errors.New("transaction is done")
+	}
+	tx.done = true
+
+	wt := tx.wt
+	m := wt.toMap()
+	keys := make([]string, len(tx.ops))
+	for i, op := range tx.ops {
+		key := wt.normalizeKey(op.key)
+		keys[i] = key
+		_, ok := m[key]
+		var err error
+		switch {
+		case op.kind == txAdd && ok:
+			err = ErrKeyExists
+		case op.kind == txAdd:
+			if err = wt.validateKey(key); err == nil {
+				err = wt.checkLimits(key, op.payload)
+			}
+		case !ok:
+			err = ErrKeyNotFound
+		case op.kind == txUpdate:
+			err = wt.checkLimits(key, op.payload)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %v %q: %w", i, op.kind, key, err)
+		}
+		if op.kind == txRemove {
+			delete(m, key)
+		} else {
+			m[key] = op.payload
+		}
+	}
+
+	rebuilt, err := wt.rebuild(wt.mt.twc, m)
+	if err != nil {
+		return nil, err
+	}
+	old := wt.oldSnapshot()
+	wt.mt.Release()
+	wt.r, wt.mt, wt.snapshot = rebuilt.r, rebuilt.mt, nil
+	for i, op := range tx.ops {
+		wt.notify(op.kind.event(), keys[i], old)
+	}
+	return wt.Snapshot(), nil
+}
+
+// Rollback discards all operations
+func (tx *Transaction) Rollback() {
+	tx.ops, tx.done = nil, true
+}
@@ -0,0 +1,107 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	m := testData()
+	wt := MustNewWildcardTree(twc, hash, testData())
+	tx := wt.Begin()
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key%02d", i)
+		tx.Add(key, [][]byte{[]byte(key + " cert")})
+		m[key] = [][]byte{[]byte(key + " cert")}
+	}
+	snapshot, err := tx.Commit()
+	if err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	want := MustNewWildcardTree(twc, hash, m).Snapshot()
+	if !bytes.Equal(snapshot, want) || !bytes.Equal(wt.Snapshot(), want) {
+		t.Errorf("got snapshot %x, want %x", snapshot, want)
+	}
+	if _, err := tx.Commit(); err == nil {
+		t.Errorf("committed twice")
+	}
+
+	// operations apply in order
+	tx = wt.Begin()
+	tx.Add("new", [][]byte{[]byte("new cert")})
+	tx.Update("new", [][]byte{[]byte("updated cert")})
+	tx.Remove("key00")
+	if _, err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	m["new"] = [][]byte{[]byte("updated cert")}
+	delete(m, "key00")
+	if want := MustNewWildcardTree(twc, hash, m).Snapshot(); !bytes.Equal(
+		wt.Snapshot(), want) {
+		t.Errorf("got snapshot %x, want %x", wt.Snapshot(), want)
+	}
+
+	// failed commits and rollbacks leave the tree unchanged
+	snapshot = wt.Snapshot()
+	entries := fmt.Sprint(wt.Entries())
+	for _, table := range []struct {
+		desc string
+		ops  func(tx *Transaction)
+		want error
+	}{
+		{"add twice", func(tx *Transaction) {
+			tx.Add("other", nil)
+			tx.Add("other", nil)
+		}, ErrKeyExists},
+		{"remove missing", func(tx *Transaction) {
+			tx.Add("other", nil)
+			tx.Remove("missing")
+		}, ErrKeyNotFound},
+		{"update removed", func(tx *Transaction) {
+			tx.Remove("new")
+			tx.Update("new", nil)
+		}, ErrKeyNotFound},
+	} {
+		tx := wt.Begin()
+		table.ops(tx)
+		if _, err := tx.Commit(); !errors.Is(err, table.want) {
+			t.Errorf("%s => got error %v, want %v", table.desc, err, table.want)
+		}
+		if !bytes.Equal(wt.Snapshot(), snapshot) ||
+			fmt.Sprint(wt.Entries()) != entries {
+			t.Errorf("%s => tree changed", table.desc)
+		}
+	}
+	tx = wt.Begin()
+	tx.Remove("new")
+	tx.Rollback()
+	if _, err := tx.Commit(); err == nil {
+		t.Errorf("committed after rollback")
+	}
+	if !bytes.Equal(wt.Snapshot(), snapshot) {
+		t.Errorf("rollback => tree changed")
+	}
+
+	// keys are normalized and validated like in Add
+	wt = MustNewWildcardTree(twc, hash, nil,
+		WithKeyNormalizer(ReversedLowercaseDomain),
+		WithKeyValidator(func(key string) error {
+			if len(key) > 10 {
+				return errors.New("too long")
+			}
+			return nil
+		}))
+	tx = wt.Begin()
+	tx.Add("Foo.COM", nil)
+	tx.Add("much.too.long.example", nil)
+	if _, err := tx.Commit(); err == nil || wt.Size() != 0 {
+		t.Errorf("accepted invalid key")
+	}
+	tx = wt.Begin()
+	tx.Add("Foo.COM", nil)
+	if _, err := tx.Commit(); err != nil || !wt.ContainsExact("foo.com") {
+		t.Errorf("key was not normalized: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package lwm
+
+import (
+	"fmt"
+)
+
+// Codec converts values of type T to and from payloads
+type Codec[T any] interface {
+	Marshal(v T) ([][]byte, error)
+	Unmarshal(payload [][]byte) (T, error)
+}
+
+// TypedWildcardTree is a WildcardTree with payloads of type T. The embedded
+// tree can be used as is, e.g., to get snapshots and raw answers.
+type TypedWildcardTree[T any] struct {
+	*WildcardTree
+	codec Codec[T]
+}
+
+// TypedAnswer is an answer with decoded payloads. The embedded answer is what
+// a proof should be verified against.
+type TypedAnswer[T any] struct {
+	Answer
+	values []T
+}
+
+// Values outputs the decoded payload of each subject
+func (a TypedAnswer[T]) Values() []T {
+	return a.values
+}
+
+// NewTypedWildcardTree outputs a new TypedWildcardTree, see NewWildcardTree.
+// Every value is encoded as a payload using codec.
+func NewTypedWildcardTree[T any](twc []byte, h func(...[]byte) []byte,
+	entries map[string]T, codec Codec[T],
+	opts ...WildcardTreeOption) (*TypedWildcardTree[T], error) {
+	m := make(map[string]interface{}, len(entries))
+	for key, v := range entries {
+		payload, err := codec.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+		m[key] = payload
+	}
+	wt, err := NewWildcardTree(twc, h, m, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedWildcardTree[T]{wt, codec}, nil
+}
+
+// GetTyped is like Get, but also decodes the payload of every match. An error
+// is returned if a payload cannot be decoded.
+func (t *TypedWildcardTree[T]) GetTyped(key string) (TypedAnswer[T], Proof,
+	error) {
+	answer, proof := t.Get(key)
+	ta := TypedAnswer[T]{Answer: answer, values: make([]T, 0,
+		len(answer.payload))}
+	for i, payload := range answer.payload {
+		v, err := t.codec.Unmarshal(payload)
+		if err != nil {
+			return TypedAnswer[T]{}, Proof{}, fmt.Errorf("subject %q: %w",
+				answer.subject[i], err)
+		}
+		ta.values = append(ta.values, v)
+	}
+	return ta, proof, nil
+}
@@ -0,0 +1,103 @@
+package lwm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/golang/example/stringutil"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// derCodec encodes a certificate as a single DER payload
+type derCodec struct{}
+
+func (derCodec) Marshal(c x509.Certificate) ([][]byte, error) {
+	if c.Raw == nil {
+		return nil, errors.New("certificate is not parsed")
+	}
+	return [][]byte{c.Raw}, nil
+}
+
+func (derCodec) Unmarshal(payload [][]byte) (x509.Certificate, error) {
+	if len(payload) != 1 {
+		return x509.Certificate{}, errors.New("expected a single certificate")
+	}
+	c, err := x509.ParseCertificate(payload[0])
+	if err != nil {
+		return x509.Certificate{}, err
+	}
+	return *c, nil
+}
+
+// testCertificate outputs a self-signed certificate for a domain name
+func testCertificate(t *testing.T, name string) x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(0, 0).Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template,
+		&key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *c
+}
+
+func TestTypedWildcardTree(t *testing.T) {
+	entries := make(map[string]x509.Certificate)
+	for _, name := range []string{"foo.com", "sub1.foo.com", "bar.se"} {
+		entries[stringutil.Reverse(name)] = testCertificate(t, name)
+	}
+	wt, err := NewTypedWildcardTree(twc, hash, entries, derCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := stringutil.Reverse("foo.com")
+	answer, proof, err := wt.GetTyped(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proof.Verify(key, answer.Answer, wt.Size(),
+		wt.Snapshot()); err != nil {
+		t.Errorf("valid proof rejected: %v", err)
+	}
+	want := []string{"foo.com", "sub1.foo.com"}
+	if len(answer.Values()) != len(want) {
+		t.Fatalf("got %d values, want %d", len(answer.Values()), len(want))
+	}
+	for i, c := range answer.Values() {
+		if c.Subject.CommonName != want[i] {
+			t.Errorf("value %d => got %q, want %q", i, c.Subject.CommonName,
+				want[i])
+		}
+	}
+
+	if _, err := NewTypedWildcardTree(twc, hash, map[string]x509.Certificate{
+		"moc.oof": {},
+	}, derCodec{}); err == nil {
+		t.Errorf("accepted a value that cannot be encoded")
+	}
+	if err := wt.Update(key, [][]byte{[]byte("not a certificate")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := wt.GetTyped(key); err == nil {
+		t.Errorf("accepted a payload that cannot be decoded")
+	}
+}
@@ -2,8 +2,8 @@ package lwm
 
 import (
 	"crypto/sha256"
-	"math"
-	"math/big"
+	"fmt"
+	"math/bits"
 )
 
 const (
@@ -27,6 +27,23 @@ func min(a, b int) int {
 	return b
 }
 
+// max outputs the largest number
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// reverse outputs s with its runes in reverse order
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
 func head(data [][]byte) (h []byte, tail [][]byte) {
 	if n := len(data); n == 0 {
 		h, tail = nil, nil // capture nil to avoid error checking in caller
@@ -54,7 +71,29 @@ func next(data [][]byte) [][]byte {
 	return nil
 }
 
-// lpow2s outputs the largest power of 2 smaller than n
+// LargestPowerOf2Below outputs the largest power of 2 smaller than n, which is
+// where a Merkle tree with n leaves is split into a left and right subtree. The
+// output is zero if n is at most one, i.e., if there is no such split.
+func LargestPowerOf2Below(n int) int {
+	k, _ := safeLpow2s(n)
+	return k
+}
+
+// lpow2s outputs the largest power of 2 smaller than n, which must be larger
+// than one
 func lpow2s(n int) int {
-	return int(math.Pow(2, float64(big.NewInt(int64(n-1)).BitLen()-1)))
+	k, ok := safeLpow2s(n)
+	if !ok {
+		panic(fmt.Sprintf("lpow2s requires n > 1, got %d", n))
+	}
+	return k
+}
+
+// safeLpow2s outputs the largest power of 2 smaller than n, and false if no
+// such power exists, i.e., if n is at most one
+func safeLpow2s(n int) (int, bool) {
+	if n <= 1 {
+		return 0, false
+	}
+	return 1 << (bits.Len(uint(n-1)) - 1), true
 }
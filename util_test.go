@@ -0,0 +1,69 @@
+package lwm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLpow2s(t *testing.T) {
+	for n := 2; n <= 65; n++ {
+		want := int(math.Pow(2, math.Floor(math.Log2(float64(n-1)))))
+		if got := lpow2s(n); got != want {
+			t.Errorf("n=%d => got %d, want %d", n, got, want)
+		}
+		if got, ok := safeLpow2s(n); !ok || got != want {
+			t.Errorf("n=%d => got (%d, %v), want (%d, true)", n, got, ok, want)
+		}
+	}
+	if got, ok := safeLpow2s(math.MaxInt); !ok || got != 1<<62 {
+		t.Errorf("n=MaxInt => got (%d, %v), want (%d, true)", got, ok, 1<<62)
+	}
+
+	for _, n := range []int{math.MinInt, -1, 0, 1} {
+		if _, ok := safeLpow2s(n); ok {
+			t.Errorf("n=%d => accepted", n)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("n=%d => no panic", n)
+				}
+			}()
+			lpow2s(n)
+		}()
+	}
+}
+
+func TestLargestPowerOf2Below(t *testing.T) {
+	for _, table := range []struct{ n, want int }{
+		{-1, 0}, {0, 0}, {1, 0}, {2, 1}, {3, 2}, {4, 2}, {5, 4}, {8, 4}, {9, 8},
+	} {
+		if got := LargestPowerOf2Below(table.n); got != table.want {
+			t.Errorf("n=%d => got %d, want %d", table.n, got, table.want)
+		}
+	}
+}
+
+func TestSplitRange(t *testing.T) {
+	for _, table := range []struct {
+		desc                             string
+		splitPoint, rangeLen, start      int
+		leftCount, leftIndex, rightIndex int
+	}{
+		{"fully left", 4, 2, 1, 2, 1, 0},
+		{"fully left, ends at split", 4, 2, 2, 2, 2, 0},
+		{"fully right", 4, 2, 5, 0, 0, 1},
+		{"fully right, starts at split", 4, 3, 4, 0, 0, 0},
+		{"spanning", 4, 4, 2, 2, 2, 0},
+		{"spanning from the first leaf", 8, 9, 0, 8, 0, 0},
+	} {
+		leftCount, leftIndex, rightIndex := SplitRange(table.splitPoint,
+			table.rangeLen, table.start)
+		if leftCount != table.leftCount || leftIndex != table.leftIndex ||
+			rightIndex != table.rightIndex {
+			t.Errorf("%s => got (%d, %d, %d), want (%d, %d, %d)", table.desc,
+				leftCount, leftIndex, rightIndex, table.leftCount, table.leftIndex,
+				table.rightIndex)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package lwm
+
+import (
+	"fmt"
+)
+
+// VerifyOption configures optional checks of Proof.VerifyWithOptions
+type VerifyOption func(*verifyConfig)
+
+type verifyConfig struct {
+	normalize       func(key string) string      // nil->key is used as is
+	validatePayload func(payload [][]byte) error // nil->all payloads are valid
+	strict          bool                         // reject padded audit paths
+	nonce           func(key string) []byte      // nil->no leaf nonces
+}
+
+// WithVerifyKeyNormalizer makes a verifier normalize the query key, which
+// should be the normalizer of the tree, see WithKeyNormalizer. Subjects are
+// not normalized, because they are hashed as they were stored in the tree.
+func WithVerifyKeyNormalizer(fn func(key string) string) VerifyOption {
+	return func(cfg *verifyConfig) {
+		cfg.normalize = fn
+	}
+}
+
+// WithPayloadValidator makes a verifier reject answers if fn outputs an error
+// for any payload, e.g., because a certificate signature is invalid. The error
+// is wrapped by the returned *VerificationError.
+func WithPayloadValidator(fn func(payload [][]byte) error) VerifyOption {
+	return func(cfg *verifyConfig) {
+		cfg.validatePayload = fn
+	}
+}
+
+// WithStrictAuditPathLength makes a verifier reject audit paths that are longer
+// than necessary. Without it, extra hashes at the leaf end of an audit path are
+// ignored, which means that many encodings of the same proof are accepted.
+func WithStrictAuditPathLength(strict bool) VerifyOption {
+	return func(cfg *verifyConfig) {
+		cfg.strict = strict
+	}
+}
+
+// WithNonceProvider makes a verifier recompute leaf nonces with fn, which must
+// be the nonce function of the tree, see WithLeafNonce. Proofs from trees with
+// leaf nonces are rejected without it.
+func WithNonceProvider(fn func(key string) []byte) VerifyOption {
+	return func(cfg *verifyConfig) {
+		cfg.nonce = fn
+	}
+}
+
+// VerifyWithOptions is like Verify, but with optional checks
+func (p Proof) VerifyWithOptions(key string, a Answer, size int,
+	snapshot []byte, opts ...VerifyOption) error {
+	var cfg verifyConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.normalize != nil {
+		key = cfg.normalize(key)
+	}
+	if cfg.validatePayload != nil {
+		for i, payload := range a.payload {
+			if err := cfg.validatePayload(payload); err != nil {
+				return &VerificationError{Kind: KindInvalidPayload,
+					Detail: fmt.Sprintf("payload %d: %v", i, err), err: err}
+			}
+		}
+	}
+	if cfg.strict && size > 0 {
+		lindex, rindex := indices(&p, &a)
+		if (p.lap != nil && len(p.lap) != apLen(lindex, size)) ||
+			(p.rap != nil && len(p.rap) != apLen(rindex, size)) {
+			return verificationError(KindMalformedData,
+				"audit path has a bad length")
+		}
+	}
+	p.nonce = cfg.nonce
+	return p.Verify(key, a, size, snapshot)
+}
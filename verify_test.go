@@ -0,0 +1,104 @@
+package lwm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestVerifyWithKeyNormalizer(t *testing.T) {
+	m := make(map[string]interface{})
+	for _, domain := range []string{"abc.com", "foo.com", "sub.foo.com",
+		"bar.com"} {
+		m[domain] = [][]byte{[]byte(domain + " cert")}
+	}
+	wt := MustNewWildcardTree(twc, hash, m,
+		WithKeyNormalizer(ReversedLowercaseDomain))
+	a, p := wt.Get("Foo.COM.")
+	if len(a.Subjects()) != 2 {
+		t.Fatalf("got subjects %v, want two", a.Subjects())
+	}
+	if err := p.VerifyWithOptions("Foo.COM.", a, wt.Size(), wt.Snapshot(),
+		WithVerifyKeyNormalizer(ReversedLowercaseDomain)); err != nil {
+		t.Errorf("valid proof rejected: %v", err)
+	}
+	if err := p.VerifyWithOptions("Foo.COM.", a, wt.Size(),
+		wt.Snapshot()); err == nil {
+		t.Errorf("accepted key that is not normalized")
+	}
+}
+
+func TestVerifyWithPayloadValidator(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	a, p := wt.Get("moc.oof")
+	errBad := errors.New("bad certificate")
+	n := 0
+	validator := func(payload [][]byte) error {
+		n++
+		for _, item := range payload {
+			if bytes.Contains(item, []byte("sub2")) {
+				return errBad
+			}
+		}
+		return nil
+	}
+	if err := p.VerifyWithOptions("moc.oof.1bus", a.Slice(0, 2), wt.Size(),
+		wt.Snapshot(), WithPayloadValidator(validator)); err == nil {
+		t.Errorf("accepted a truncated answer")
+	}
+
+	n = 0
+	a, p = wt.Get("moc.oof.1bus")
+	if err := p.VerifyWithOptions("moc.oof.1bus", a, wt.Size(), wt.Snapshot(),
+		WithPayloadValidator(validator)); err != nil {
+		t.Errorf("valid payloads rejected: %v", err)
+	}
+	if n != len(a.Subjects()) {
+		t.Errorf("validator called %d times, want %d", n, len(a.Subjects()))
+	}
+
+	a, p = wt.Get("moc.oof")
+	err := p.VerifyWithOptions("moc.oof", a, wt.Size(), wt.Snapshot(),
+		WithPayloadValidator(validator))
+	var verr *VerificationError
+	if !errors.As(err, &verr) || verr.Kind != KindInvalidPayload {
+		t.Errorf("got error %v, want kind %v", err, KindInvalidPayload)
+	}
+	if !errors.Is(err, errBad) {
+		t.Errorf("validator error is not wrapped: %v", err)
+	}
+}
+
+func TestVerifyWithStrictAuditPathLength(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	strict := WithStrictAuditPathLength(true)
+	for _, key := range append(wt.Keys(), "", "a", "moc.rab", "zzz") {
+		a, p := wt.Get(key)
+		if err := p.VerifyWithOptions(key, a, wt.Size(), wt.Snapshot(),
+			strict); err != nil {
+			t.Errorf("key %q => valid proof rejected: %v", key, err)
+		}
+		if p.lap == nil && p.rap == nil {
+			continue
+		}
+
+		// extra hashes at the leaf end of an audit path are not used
+		padded := p
+		padded.lap = append([][]byte{hash([]byte("pad"))}, p.lap...)
+		padded.rap = append([][]byte{hash([]byte("pad"))}, p.rap...)
+		if p.lap == nil {
+			padded.lap = nil
+		}
+		if p.rap == nil {
+			padded.rap = nil
+		}
+		if err := padded.VerifyWithOptions(key, a, wt.Size(), wt.Snapshot(),
+			WithStrictAuditPathLength(false)); err != nil {
+			t.Errorf("key %q => padded proof rejected in lax mode: %v", key, err)
+		}
+		if err := padded.VerifyWithOptions(key, a, wt.Size(), wt.Snapshot(),
+			strict); err == nil {
+			t.Errorf("key %q => padded proof accepted in strict mode", key)
+		}
+	}
+}
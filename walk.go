@@ -0,0 +1,41 @@
+package lwm
+
+// WalkLeaves calls fn for every leaf's data in index order. The walk stops if
+// fn returns false. The data must not be modified.
+func (mt *MerkleTree) WalkLeaves(fn func(index int, data []byte) bool) {
+	for i, d := range mt.data {
+		if !fn(i, d) {
+			return
+		}
+	}
+}
+
+// WalkInteriorHashes calls fn for every interior node in pre-order, where the
+// root is at level zero and position is the node's index among all interior
+// nodes at the same level, from left to right. The walk stops if fn returns
+// false. The hash must not be modified.
+func (mt *MerkleTree) WalkInteriorHashes(fn func(level, position int,
+	hash []byte) bool) {
+	mt.Mth() // walk relies on a populated cache
+	var positions []int
+	mt.walk(mt.data, mt.cache, 0, &positions, fn)
+}
+
+// walk outputs false if the walk should stop
+func (mt *MerkleTree) walk(data [][]byte, c *hashCache, level int,
+	positions *[]int, fn func(level, position int, hash []byte) bool) bool {
+	if len(data) <= 1 {
+		return true
+	}
+	if level == len(*positions) {
+		*positions = append(*positions, 0)
+	}
+	position := (*positions)[level]
+	(*positions)[level]++
+	if !fn(level, position, c.this) {
+		return false
+	}
+	k := lpow2s(len(data))
+	return mt.walk(data[:k], c.left, level+1, positions, fn) &&
+		mt.walk(data[k:], c.right, level+1, positions, fn)
+}
@@ -0,0 +1,88 @@
+package lwm
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestWalkLeaves(t *testing.T) {
+	wt := MustNewWildcardTree(twc, hash, testData())
+	var data [][]byte
+	wt.mt.WalkLeaves(func(i int, d []byte) bool {
+		if i != len(data) {
+			t.Errorf("got index %d, want %d", i, len(data))
+		}
+		data = append(data, d)
+		return true
+	})
+	if fmt.Sprint(data) != fmt.Sprint(wt.mt.data) {
+		t.Errorf("got leaves %x, want %x", data, wt.mt.data)
+	}
+	for i, key := range wt.Keys() {
+		if got := mkKey(data[i]); got != key {
+			t.Errorf("leaf %d => got key %q, want %q", i, got, key)
+		}
+	}
+
+	n := 0
+	wt.mt.WalkLeaves(func(int, []byte) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("early termination => visited %d leaves, want 2", n)
+	}
+}
+
+func TestWalkInteriorHashes(t *testing.T) {
+	// the example tree in RFC 6962 (§2.1.3), where d[i:j] is the subtree
+	// with leaves i to j-1
+	wt := consistencyTree(7)
+	d := wt.mt.data
+	mth := func(data [][]byte) []byte {
+		return NewMerkleTree(twc, leafPrefix, interiorPrefix, hash, data).Mth()
+	}
+	want := []struct {
+		level, position int
+		hash            []byte
+	}{
+		{0, 0, mth(d)},
+		{1, 0, mth(d[0:4])},
+		{2, 0, mth(d[0:2])},
+		{2, 1, mth(d[2:4])},
+		{1, 1, mth(d[4:7])},
+		{2, 2, mth(d[4:6])},
+	}
+	i := 0
+	wt.mt.WalkInteriorHashes(func(level, position int, h []byte) bool {
+		if i >= len(want) {
+			t.Fatalf("too many nodes")
+		}
+		if w := want[i]; level != w.level || position != w.position ||
+			!bytes.Equal(h, w.hash) {
+			t.Errorf("node %d => got (%d, %d, %x), want (%d, %d, %x)", i, level,
+				position, h, w.level, w.position, w.hash)
+		}
+		i++
+		return true
+	})
+	if i != len(want) {
+		t.Errorf("visited %d nodes, want %d", i, len(want))
+	}
+
+	i = 0
+	wt.mt.WalkInteriorHashes(func(int, int, []byte) bool {
+		i++
+		return i < 3
+	})
+	if i != 3 {
+		t.Errorf("early termination => visited %d nodes, want 3", i)
+	}
+	for _, size := range []int{0, 1} {
+		consistencyTree(size).mt.WalkInteriorHashes(func(int, int, []byte) bool {
+			t.Errorf("size %d => visited an interior node", size)
+			return true
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package lwm
+
+import (
+	"context"
+)
+
+// warmCheckInterval is the number of audit paths that are computed between
+// checks for a cancelled context
+const warmCheckInterval = 1024
+
+// Warm populates the hash cache and precomputes the audit paths of all leaves,
+// such that proofs are generated without any hashing until the tree is
+// modified. Memory usage grows by one audit path per leaf. It must not be
+// called concurrently with other methods.
+func (wt *WildcardTree) Warm() {
+	wt.mt.warm(context.Background())
+}
+
+// WarmAsync is like Warm, but runs in the background. The tree must not be
+// used until an error is received on the returned channel, which is nil on
+// success and ctx.Err() if warm-up was cancelled. A cancelled warm-up leaves
+// the hash cache partially populated, but audit paths are not precomputed.
+func (wt *WildcardTree) WarmAsync(ctx context.Context) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- wt.mt.warm(ctx)
+		close(ch)
+	}()
+	return ch
+}
+
+// warm populates the hash cache and the audit path cache
+func (mt *MerkleTree) warm(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mt.Mth()
+	paths := make([][][]byte, len(mt.data))
+	for i := range paths {
+		if i%warmCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		paths[i] = mt.Ap(i)
+	}
+	mt.apCache = paths
+	return nil
+}
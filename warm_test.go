@@ -0,0 +1,96 @@
+package lwm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWarm(t *testing.T) {
+	wt := consistencyTree(100)
+	want := consistencyTree(100).mt.ApAll()
+	wt.Warm()
+	if len(wt.mt.apCache) != wt.Size() {
+		t.Fatalf("got %d cached audit paths, want %d", len(wt.mt.apCache),
+			wt.Size())
+	}
+	for i := range want {
+		if got := wt.mt.Ap(i); fmt.Sprint(got) != fmt.Sprint(want[i]) {
+			t.Errorf("leaf %d => bad audit path", i)
+		}
+	}
+	wt.mt.Ap(0)[0] = nil // callers must not be able to modify the cache
+	if fmt.Sprint(wt.mt.Ap(0)) != fmt.Sprint(want[0]) {
+		t.Errorf("cached audit path was modified")
+	}
+	for _, key := range []string{"", "key0", "key050", "key1", "zzz"} {
+		a, p := wt.Get(key)
+		if err := p.Verify(key, a, wt.Size(), wt.Snapshot()); err != nil {
+			t.Errorf("key %q => %v", key, err)
+		}
+	}
+
+	// warming must not change the audit path of a single leaf
+	single := consistencyTree(1)
+	single.Warm()
+	if ap := single.mt.Ap(0); ap != nil {
+		t.Errorf("got audit path %v for a single leaf, want nil", ap)
+	}
+	for _, key := range []string{"a", "key000", "zzz"} {
+		a, p := single.Get(key)
+		if err := p.Verify(key, a, single.Size(), single.Snapshot()); err != nil {
+			t.Errorf("single leaf: key %q => %v", key, err)
+		}
+	}
+
+	if err := wt.Add("key100", [][]byte{[]byte("key100 cert")}); err != nil {
+		t.Fatal(err)
+	}
+	if wt.mt.apCache != nil {
+		t.Errorf("audit paths are cached after modification")
+	}
+	a, p := wt.Get("key1")
+	if err := p.Verify("key1", a, wt.Size(), wt.Snapshot()); err != nil {
+		t.Errorf("modified tree => %v", err)
+	}
+}
+
+func TestWarmAsync(t *testing.T) {
+	wt := consistencyTree(100)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := <-wt.WarmAsync(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled => got error %v", err)
+	}
+	if wt.mt.apCache != nil {
+		t.Errorf("cancelled => audit paths are cached")
+	}
+	if err := <-wt.WarmAsync(context.Background()); err != nil {
+		t.Errorf("got error %v", err)
+	}
+	if len(wt.mt.apCache) != wt.Size() {
+		t.Errorf("got %d cached audit paths, want %d", len(wt.mt.apCache),
+			wt.Size())
+	}
+}
+
+func BenchmarkWarmGet(b *testing.B) {
+	keys := make([]string, 64)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%03d", i*4096/len(keys))
+	}
+	for _, warm := range []bool{false, true} {
+		b.Run(fmt.Sprintf("warm=%v", warm), func(b *testing.B) {
+			wt := consistencyTree(4096)
+			wt.Snapshot()
+			if warm {
+				wt.Warm()
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				wt.Get(keys[i%len(keys)])
+			}
+		})
+	}
+}